@@ -46,13 +46,32 @@ type Article struct {
 	Printer     `json:"-" bson:"-"`
 	TitlePath   string    `json:"titlePath" bson:"titlePath"`
 	Title       string    `json:"title" bson:"title"`
+	Author      string    `json:"author" bson:"author"`
 	Synopsis    string    `json:"synopsis" bson:"synopsis"`
 	Content     string    `json:"content" bson:"content"`
 	IsPublished bool      `json:"isPublished" bson:"isPublished"`
+	Pinned      bool      `json:"pinned" bson:"pinned"`
+	Featured    bool      `json:"featured" bson:"featured"`
 	Created     time.Time `json:"created" bson:"created"`
 	Modified    time.Time `json:"modified" bson:"modified"`
 	Img         Img       `json:"img" bson:"img"`
 	Imgs        []Img     `json:"imgs" bson:"imgs"`
+	Tags        []string  `json:"tags" bson:"tags"`
+	Meta        Meta      `json:"meta,omitempty" bson:"meta,omitempty"`
+	Related     []Related `json:"related,omitempty" bson:"-"`
+	Stats       `bson:",inline"`
+
+	// Rendered caches the Content, rendered from its format into HTML, and
+	// HTML is the rendering as it's shown, through the views
+	ContentFormat ContentFormat `json:"contentFormat" bson:"contentFormat"`
+	Rendered      string        `json:"rendered,omitempty" bson:"rendered"`
+	HTML          string        `json:"html,omitempty" bson:"-"`
+	TOC           []*Heading    `json:"toc,omitempty" bson:"-"`
+
+	// Translations of the Title, Synopsis and Content, from Lang
+	Lang         string        `json:"lang" bson:"lang"`
+	Translations []Translation `json:"translations,omitempty" bson:"translations"`
+	translation  string
 }
 
 type Articles struct {
@@ -62,16 +81,38 @@ type Articles struct {
 type Reader interface {
 	ByTitlePath(titlePath string, unPublished bool) (interface{}, error)
 	Recent(limit, page int, unPublished bool) (interface{}, error)
+	Find(q Query) (interface{}, error)
+	Featured(limit int) (interface{}, error)
+	Tags() ([]string, error)
+	Series(name string) (*Series, error)
+	SeriesOf(titlePath string) (*Series, error)
+	AllSeries() ([]*Series, error)
 }
 
 type Printer interface {
 	Print(article interface{}) error
+	Replace(titlePath string, article interface{}) error
 	UpdateSynopsis(titlePath, synopsis string, modified time.Time) error
-	UpdateContent(titlePath, content string, modified time.Time) error
+	UpdateContent(titlePath, content string, stats Stats, modified time.Time) error
+	UpdateContentFormat(titlePath string, format ContentFormat, modified time.Time) error
+	WriteRendered(titlePath, rendered string) error
+	WriteLang(titlePath, lang string) error
+	WriteTranslation(titlePath string, t Translation) error
+	WriteTranslationRendered(titlePath, lang, rendered string) error
+	RemoveTranslation(titlePath, lang string) error
 	Delete(titlePath string) error
 	Publish(titlePath string, publish bool) error
+	Pin(titlePath string, pin bool) error
+	Feature(titlePath string, feature bool) error
 	WriteImg(titlePath string, img interface{}) error
 	WriteImgs(titlePath string, imgs interface{}) error
+	WriteTags(titlePath string, tags []string) error
+	WriteAuthor(titlePath, author string) error
+	WriteMeta(titlePath string, meta Meta) error
+	AddToSeries(name, titlePath string) error
+	RemoveFromSeries(name, titlePath string) error
+	ReorderSeries(name string, titlePaths []string) error
+	WriteSeries(s *Series) error
 }
 
 const VERSION string = "0.0.2"
@@ -107,22 +148,33 @@ func NewArticle(title string) *Article {
 	return nil
 }
 
+// isNotFound reports if `err` is a backend's error for what doesn't exist,
+// rather than one of the backend itself.
+func isNotFound(err error) bool {
+	e, ok := err.(backends.Error)
+	return ok && e.Status() == backends.StatusNotFound
+}
+
 func titlePathTime(title string) (string, time.Time) {
 	// create a new article, based on the current time
 	t := time.Now()
-	titlePath := fmt.Sprintf("%d/%02d/%02d/%s/",
+	return TitlePath(t, title), t
+}
+
+// TitlePath is the titlePath of an article titled `title` created at `t`.
+func TitlePath(t time.Time, title string) string {
+	return fmt.Sprintf("%d/%02d/%02d/%s/",
 		t.Year(),
 		t.Month(),
 		t.Day(),
 		strings.Replace(title, " ", "-", -1))
-	return titlePath, t
 }
 
 func (a *Article) Print() error {
 	return a.Printer.Print(a)
 }
 func (a *Article) UpdateContent(content string) error {
-	return a.Printer.UpdateContent(a.TitlePath, content, time.Now())
+	return a.Printer.UpdateContent(a.TitlePath, content, ContentStats(a.ContentFormat, content), time.Now())
 }
 
 func (a *Article) SetSynopsis(synopsis string) (err error) {
@@ -136,13 +188,79 @@ func (a *Article) SetSynopsis(synopsis string) (err error) {
 
 func (a *Article) SetContent(content string) (err error) {
 	modified := time.Now()
-	if err = a.Printer.UpdateContent(a.TitlePath, content, modified); err == nil {
+	stats := ContentStats(a.ContentFormat, content)
+	if err = a.Printer.UpdateContent(a.TitlePath, content, stats, modified); err == nil {
 		a.Content = content
+		a.Stats = stats
+		a.clearRendered()
 		a.Modified = modified
 	}
 	return
 }
 
+// SetContentFormat changes the format of the content, along with its stats,
+// which are of its text.
+func (a *Article) SetContentFormat(format ContentFormat) (err error) {
+	modified := time.Now()
+	if err = a.Printer.UpdateContentFormat(a.TitlePath, format, modified); err != nil {
+		return
+	}
+	a.ContentFormat = format
+	a.clearRendered()
+	a.Modified = modified
+
+	stats := ContentStats(format, a.Content)
+	if err = a.Printer.UpdateContent(a.TitlePath, a.Content, stats, modified); err == nil {
+		a.Stats = stats
+	}
+	return
+}
+
+// Render returns the article's content as HTML, rendering it through `p` and
+// caching the result when it hasn't been rendered since the content was set.
+// A localized article caches the rendering of its translation. The views of
+// `p` are applied to what's returned, and kept in HTML, but never cached.
+func (a *Article) Render(p *Pipeline) (string, error) {
+	if a.Rendered != "" || a.Content == "" {
+		a.HTML = p.View(a, a.Rendered)
+		return a.HTML, nil
+	}
+
+	var err error
+	rendered := p.Render(a)
+	if a.translation != "" {
+		err = a.Printer.WriteTranslationRendered(a.TitlePath, a.translation, rendered)
+		if t, ok := a.Translation(a.translation); ok && err == nil {
+			t.Rendered = rendered
+		}
+	} else {
+		err = a.Printer.WriteRendered(a.TitlePath, rendered)
+	}
+	if err == nil {
+		a.Rendered = rendered
+	}
+	a.HTML = p.View(a, rendered)
+	return a.HTML, err
+}
+
+// clearRendered clears the cached renderings of the article, and of its
+// translations, for changes to what they're all rendered from.
+func (a *Article) clearRendered() {
+	a.Rendered = ""
+	for i := range a.Translations {
+		a.Translations[i].Rendered = ""
+	}
+}
+
+// Summary is the article's synopsis, or an excerpt of its content when the
+// synopsis is empty.
+func (a *Article) Summary() string {
+	if a.Synopsis != "" {
+		return a.Synopsis
+	}
+	return a.Excerpt
+}
+
 func (a *Article) Delete() error {
 	return a.Printer.Delete(a.TitlePath)
 }
@@ -154,9 +272,24 @@ func (a *Article) Publish(publish bool) (err error) {
 	return
 }
 
+func (a *Article) Pin(pin bool) (err error) {
+	if err = a.Printer.Pin(a.TitlePath, pin); err == nil {
+		a.Pinned = pin
+	}
+	return
+}
+
+func (a *Article) Feature(feature bool) (err error) {
+	if err = a.Printer.Feature(a.TitlePath, feature); err == nil {
+		a.Featured = feature
+	}
+	return
+}
+
 func (a *Article) SetImg(img Img) (err error) {
 	if err = a.Printer.WriteImg(a.TitlePath, img); err == nil {
 		a.Img = img
+		a.clearRendered()
 	}
 	return
 }
@@ -164,10 +297,53 @@ func (a *Article) SetImg(img Img) (err error) {
 func (a *Article) SetImgs(imgs []Img) (err error) {
 	if err = a.Printer.WriteImgs(a.TitlePath, imgs); err == nil {
 		a.Imgs = imgs
+		a.clearRendered()
 	}
 	return
 }
 
+func (a *Article) SetTags(tags []string) (err error) {
+	if err = a.Printer.WriteTags(a.TitlePath, tags); err == nil {
+		a.Tags = tags
+	}
+	return
+}
+
+func (a *Article) SetAuthor(author string) (err error) {
+	if err = a.Printer.WriteAuthor(a.TitlePath, author); err == nil {
+		a.Author = author
+	}
+	return
+}
+
+// SetMeta merges `changes` into the article's metadata, where a nil value
+// removes the field.
+func (a *Article) SetMeta(changes Meta) (err error) {
+	meta := make(Meta)
+	for k, v := range a.Meta {
+		meta[k] = v
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(meta, k)
+		} else {
+			meta[k] = v
+		}
+	}
+	if err = a.Printer.WriteMeta(a.TitlePath, meta); err == nil {
+		a.Meta = meta
+	}
+	return
+}
+
+func (a *Article) AddToSeries(name string) error {
+	return a.Printer.AddToSeries(name, a.TitlePath)
+}
+
+func (a *Article) RemoveFromSeries(name string) error {
+	return a.Printer.RemoveFromSeries(name, a.TitlePath)
+}
+
 /*func (a *Article) MarshalJSON() ([]byte, error) {
 	return []byte(`{ "titlePath": "bob" }`), nil
 }*/
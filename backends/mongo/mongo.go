@@ -1,7 +1,10 @@
 package mongo
 
 import (
+	"fmt"
 	"log"
+	"reflect"
+	"sort"
 	"time"
 
 	"labix.org/v2/mgo"
@@ -13,12 +16,12 @@ import (
 )
 
 type Backend struct {
-	database, collection string
-	session              *mgo.Session
-	NewArticle           ArticleFn
-	NewArticles          ArticlesFn
-	SetPrinter           PrinterFn
-	SetPrinters          PrintersFn
+	database, collection, series string
+	session                      *mgo.Session
+	NewArticle                   ArticleFn
+	NewArticles                  ArticlesFn
+	SetPrinter                   PrinterFn
+	SetPrinters                  PrintersFn
 }
 
 type QueryFunc func(c *mgo.Collection)
@@ -45,10 +48,15 @@ func init() {
 		col = "articles"
 	}
 
+	series, ok := config.GroupString("db", "mongoSeriesCol")
+	if !ok {
+		series = col + ".series"
+	}
+
 	// Mongo error
-	b, err := New(url, db, col)
+	b, err := NewWithSeries(url, db, col, series)
 	if err != nil {
-		log.Fatal("Failed to create backend: %v", err)
+		log.Fatalf("Failed to create backend: %v", err)
 	}
 
 	// Register
@@ -72,13 +80,65 @@ func setPrinter(o interface{}, p articles.Printer) {
 }
 func setPrinters(o interface{}, p articles.Printer) {
 	if s, ok := o.(*[]articles.Article); ok {
-		for _, a := range *s {
-			a.Printer = p
+		for i := range *s {
+			(*s)[i].Printer = p
 		}
 	}
 }
 
+// findQuery translates an article query into a mongo query.
+func findQuery(query articles.Query) bson.M {
+	q := bson.M{}
+	switch query.Published {
+	case articles.Published:
+		q["isPublished"] = true
+	case articles.UnPublished:
+		q["isPublished"] = false
+	}
+
+	created := bson.M{}
+	if !query.From.IsZero() {
+		created["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		created["$lt"] = query.To
+	}
+	if len(created) > 0 {
+		q["created"] = created
+	}
+
+	if query.Tag != "" {
+		q["tags"] = query.Tag
+	}
+	if query.Author != "" {
+		q["author"] = query.Author
+	}
+	if query.Featured {
+		q["featured"] = true
+	}
+	if query.Lang != "" {
+		or := []bson.M{{"lang": query.Lang}, {"translations.lang": query.Lang}}
+		if query.Lang == articles.DefaultLang {
+			// articles without a language
+			or = append(or, bson.M{"lang": bson.M{"$in": []interface{}{"", nil}}})
+		}
+		q["$or"] = or
+	}
+	if query.HasThumb {
+		q["img.src"] = bson.M{"$nin": []interface{}{"", nil}}
+	}
+	return q
+}
+
+// New returns the backend of the articles of `collection`, their series
+// being in `collection` + ".series".
 func New(url, database, collection string) (Backend, error) {
+	return NewWithSeries(url, database, collection, collection+".series")
+}
+
+// NewWithSeries returns the backend of the articles of `collection`, and
+// the series of `series`.
+func NewWithSeries(url, database, collection, series string) (Backend, error) {
 	var b Backend
 	session, err := mgo.Dial(url)
 	if err != nil {
@@ -86,7 +146,7 @@ func New(url, database, collection string) (Backend, error) {
 	}
 
 	session.SetMode(mgo.Monotonic, true)
-	b = Backend{database, collection, session, newArticle, newArticles, setPrinter, setPrinters}
+	b = Backend{database, collection, series, session, newArticle, newArticles, setPrinter, setPrinters}
 	return b, nil
 }
 
@@ -96,6 +156,10 @@ func (b Backend) Col() (*mgo.Session, *mgo.Collection) {
 	//return s, s.DB(db).C(b.collection)
 	return s, s.DB(b.database).C(b.collection)
 }
+func (b Backend) SeriesCol() (*mgo.Session, *mgo.Collection) {
+	s := b.session.New()
+	return s, s.DB(b.database).C(b.series)
+}
 func (b Backend) Query(fn QueryFunc) {
 	s, c := b.Col()
 	defer s.Close()
@@ -119,13 +183,33 @@ func (b Backend) ByTitlePath(titlePath string, unPublished bool) (interface{}, e
 	return c, nil
 }
 func (b Backend) Recent(limit, page int, unPublished bool) (interface{}, error) {
+	return b.Find(articles.RecentQuery(limit, page, unPublished))
+}
+func (b Backend) Featured(limit int) (interface{}, error) {
+	return b.Find(articles.FeaturedQuery(limit))
+}
+func (b Backend) Tags() ([]string, error) {
 	s, col := b.Col()
 	defer s.Close()
 
-	c := b.NewArticles(limit)
-	var q bson.M = nil
-	if !unPublished {
-		q = bson.M{"isPublished": true}
+	var tags []string
+	if err := col.Find(bson.M{"isPublished": true}).Distinct("tags", &tags); err != nil {
+		return nil, backends.NewError(backends.StatusDatastoreError, "Failed to find tags", err)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+func (b Backend) Find(query articles.Query) (interface{}, error) {
+	s, col := b.Col()
+	defer s.Close()
+
+	q := findQuery(query)
+	sort, desc := query.SortField()
+	if desc {
+		sort = "-" + sort
+	}
+	if query.PinnedFirst {
+		return b.findPinnedFirst(col, query, q, sort)
 	}
 
 	/*iter := col.Find(q).
@@ -147,18 +231,97 @@ func (b Backend) Recent(limit, page int, unPublished bool) (interface{}, error)
 	 *  according to `ab -c 35 -rn 1000 http://127.0.0.1:9991/articles/`
 	 *  the the below is about 450 req/s faster
 	 */
+	c := b.NewArticles(query.Limit)
 	if err := col.Find(q).
-		Sort("-created").
-		Skip(page * limit).
-		Limit(limit).
+		Sort(sort).
+		Skip(query.Page * query.Limit).
+		Limit(query.Limit).
 		All(c); err != nil {
 		return c, backends.NewError(backends.StatusDatastoreError, "Failed to query article list", err)
 	}
-	b.SetPrinter(c, b)
+	b.SetPrinters(c, b)
 
 	return c, nil
 }
 
+// findPinnedFirst finds the page of `query` with the pinned articles leading
+// the listing, and left out of the rest of it that follows them. Sorting on
+// `pinned` instead would order articles written before it existed, without
+// the field, after every other.
+func (b Backend) findPinnedFirst(col *mgo.Collection, query articles.Query, q bson.M, sort string) (interface{}, error) {
+	pinnedQ, restQ := bson.M{"pinned": true}, bson.M{"pinned": bson.M{"$ne": true}}
+	for k, v := range q {
+		pinnedQ[k], restQ[k] = v, v
+	}
+
+	// The pinned articles take the place of the first of the others
+	pinned, err := col.Find(pinnedQ).Count()
+	if err != nil {
+		return b.NewArticles(0), backends.NewError(backends.StatusDatastoreError, "Failed to query article list", err)
+	}
+	pinnedSkip, lead, skip, limit := query.PinnedPage(pinned)
+
+	c := b.NewArticles(query.Limit)
+	if lead > 0 {
+		if err := col.Find(pinnedQ).Sort(sort).Skip(pinnedSkip).Limit(lead).All(c); err != nil {
+			return c, backends.NewError(backends.StatusDatastoreError, "Failed to query article list", err)
+		}
+	}
+	if limit >= 0 {
+		rest := b.NewArticles(limit)
+		if err := col.Find(restQ).Sort(sort).Skip(skip).Limit(limit).All(rest); err != nil {
+			return c, backends.NewError(backends.StatusDatastoreError, "Failed to query article list", err)
+		}
+		appendArticles(c, rest)
+	}
+	b.SetPrinters(c, b)
+
+	return c, nil
+}
+
+// appendArticles appends the list `rest` to the list `c`, both created by
+// NewArticles, and so pointers to slices as `All` requires.
+func appendArticles(c, rest interface{}) {
+	v := reflect.ValueOf(c).Elem()
+	v.Set(reflect.AppendSlice(v, reflect.ValueOf(rest).Elem()))
+}
+
+func (b Backend) Series(name string) (*articles.Series, error) {
+	s, col := b.SeriesCol()
+	defer s.Close()
+
+	series := new(articles.Series)
+	if err := col.Find(bson.M{"name": name}).One(series); err != nil {
+		return nil, backends.NewError(backends.StatusNotFound, "Series not found", err)
+	}
+	series.Printer = b
+	return series, nil
+}
+func (b Backend) SeriesOf(titlePath string) (*articles.Series, error) {
+	s, col := b.SeriesCol()
+	defer s.Close()
+
+	series := new(articles.Series)
+	if err := col.Find(bson.M{"titlePaths": titlePath}).One(series); err != nil {
+		return nil, backends.NewError(backends.StatusNotFound, "Series not found", err)
+	}
+	series.Printer = b
+	return series, nil
+}
+func (b Backend) AllSeries() ([]*articles.Series, error) {
+	s, col := b.SeriesCol()
+	defer s.Close()
+
+	var all []*articles.Series
+	if err := col.Find(nil).Sort("name").All(&all); err != nil {
+		return nil, backends.NewError(backends.StatusDatastoreError, "Failed to find series", err)
+	}
+	for _, series := range all {
+		series.Printer = b
+	}
+	return all, nil
+}
+
 // Printer
 func (b Backend) Print(article interface{}) error {
 	s, col := b.Col()
@@ -170,6 +333,17 @@ func (b Backend) Print(article interface{}) error {
 
 	return nil
 }
+func (b Backend) Replace(titlePath string, article interface{}) error {
+	s, col := b.Col()
+	defer s.Close()
+
+	// the whole article, keeping its id
+	selector := bson.M{"titlePath": titlePath}
+	if err := col.Update(selector, article); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to replace article", err)
+	}
+	return nil
+}
 func (b Backend) UpdateSynopsis(titlePath, synopsis string, modified time.Time) error {
 	s, col := b.Col()
 	defer s.Close()
@@ -182,18 +356,71 @@ func (b Backend) UpdateSynopsis(titlePath, synopsis string, modified time.Time)
 	}
 	return nil
 }
-func (b Backend) UpdateContent(titlePath, content string, modified time.Time) error {
+func (b Backend) UpdateContent(titlePath, content string, stats articles.Stats, modified time.Time) error {
 	s, col := b.Col()
 	defer s.Close()
 
-	// update the article's content
+	// update the article's content, invalidating the rendered content, and
+	// translations, as Article.SetContent does
 	selector := bson.M{"titlePath": titlePath}
-	change := bson.M{"$set": bson.M{"content": &content, "modified": modified}}
-	if err := col.Update(selector, change); err != nil {
+	set := bson.M{"content": &content,
+		"wordCount":   stats.WordCount,
+		"readingTime": stats.ReadingTime,
+		"excerpt":     stats.Excerpt,
+		"modified":    modified}
+	if err := unrendered(col, titlePath, set); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update article's content", err)
+	}
+	if err := col.Update(selector, bson.M{"$set": set}); err != nil {
 		return backends.NewError(backends.StatusDatastoreError, "Failed to update article's content", err)
 	}
 	return nil
 }
+func (b Backend) UpdateContentFormat(titlePath string, format articles.ContentFormat, modified time.Time) error {
+	s, col := b.Col()
+	defer s.Close()
+
+	// a new format invalidates the rendered content, and translations
+	selector := bson.M{"titlePath": titlePath}
+	set := bson.M{"contentFormat": format, "modified": modified}
+	if err := unrendered(col, titlePath, set); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update article's content format", err)
+	}
+	if err := col.Update(selector, bson.M{"$set": set}); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update article's content format", err)
+	}
+	return nil
+}
+
+// unrendered adds the clearing of the rendered content of the article
+// `titlePath`, and of each of its translations, to the fields of a `$set`.
+func unrendered(col *mgo.Collection, titlePath string, set bson.M) error {
+	var a struct {
+		Translations []struct {
+			Lang string `bson:"lang"`
+		} `bson:"translations"`
+	}
+	selector := bson.M{"titlePath": titlePath}
+	if err := col.Find(selector).Select(bson.M{"translations.lang": 1}).One(&a); err != nil {
+		return err
+	}
+	set["rendered"] = ""
+	for i := range a.Translations {
+		set[fmt.Sprintf("translations.%d.rendered", i)] = ""
+	}
+	return nil
+}
+func (b Backend) WriteRendered(titlePath, rendered string) error {
+	s, col := b.Col()
+	defer s.Close()
+
+	selector := bson.M{"titlePath": titlePath}
+	change := bson.M{"$set": bson.M{"rendered": rendered}}
+	if err := col.Update(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to cache article's rendered content", err)
+	}
+	return nil
+}
 func (b Backend) Delete(titlePath string) error {
 	s, col := b.Col()
 	defer s.Close()
@@ -203,6 +430,15 @@ func (b Backend) Delete(titlePath string) error {
 	if err := col.Remove(selector); err != nil {
 		return backends.NewError(backends.StatusDatastoreError, "Failed to remove article", err)
 	}
+
+	// and the series it was part of
+	ss, series := b.SeriesCol()
+	defer ss.Close()
+	selector = bson.M{"titlePaths": titlePath}
+	change := bson.M{"$pull": bson.M{"titlePaths": titlePath}}
+	if _, err := series.UpdateAll(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to remove article from its series", err)
+	}
 	return nil
 }
 func (b Backend) Publish(titlePath string, publish bool) error {
@@ -222,8 +458,11 @@ func (b Backend) WriteImg(titlePath string, img interface{}) error {
 	defer session.Close()
 
 	selector := bson.M{"titlePath": titlePath}
-	change := bson.M{"$set": bson.M{"img": img}}
-	if err := col.Update(selector, change); err != nil {
+	set := bson.M{"img": img}
+	if err := unrendered(col, titlePath, set); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update image/thumb", err)
+	}
+	if err := col.Update(selector, bson.M{"$set": set}); err != nil {
 		return backends.NewError(backends.StatusDatastoreError, "Failed to update image/thumb", err)
 	}
 	return nil
@@ -233,9 +472,168 @@ func (b Backend) WriteImgs(titlePath string, imgs interface{}) error {
 	defer session.Close()
 
 	selector := bson.M{"titlePath": titlePath}
-	change := bson.M{"$set": bson.M{"imgs": imgs}} //&a.Imgs}}
-	if err := col.Update(selector, change); err != nil {
+	set := bson.M{"imgs": imgs}
+	if err := unrendered(col, titlePath, set); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update images", err)
+	}
+	if err := col.Update(selector, bson.M{"$set": set}); err != nil {
 		return backends.NewError(backends.StatusDatastoreError, "Failed to update images", err)
 	}
 	return nil
 }
+func (b Backend) WriteTags(titlePath string, tags []string) error {
+	session, col := b.Col()
+	defer session.Close()
+
+	selector := bson.M{"titlePath": titlePath}
+	change := bson.M{"$set": bson.M{"tags": tags}}
+	if err := col.Update(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update tags", err)
+	}
+	return nil
+}
+func (b Backend) AddToSeries(name, titlePath string) error {
+	session, col := b.SeriesCol()
+	defer session.Close()
+
+	// creates the series when it doesn't exist yet
+	selector := bson.M{"name": name}
+	change := bson.M{"$addToSet": bson.M{"titlePaths": titlePath}}
+	if _, err := col.Upsert(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to add article to series", err)
+	}
+	return nil
+}
+func (b Backend) RemoveFromSeries(name, titlePath string) error {
+	session, col := b.SeriesCol()
+	defer session.Close()
+
+	selector := bson.M{"name": name}
+	change := bson.M{"$pull": bson.M{"titlePaths": titlePath}}
+	if err := col.Update(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to remove article from series", err)
+	}
+	return nil
+}
+func (b Backend) ReorderSeries(name string, titlePaths []string) error {
+	session, col := b.SeriesCol()
+	defer session.Close()
+
+	selector := bson.M{"name": name}
+	change := bson.M{"$set": bson.M{"titlePaths": titlePaths}}
+	if err := col.Update(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to reorder series", err)
+	}
+	return nil
+}
+func (b Backend) WriteSeries(series *articles.Series) error {
+	session, col := b.SeriesCol()
+	defer session.Close()
+
+	// the whole series, replacing any of the same name
+	titlePaths := series.TitlePaths
+	if titlePaths == nil {
+		titlePaths = []string{}
+	}
+	selector := bson.M{"name": series.Name}
+	change := bson.M{"name": series.Name, "titlePaths": titlePaths}
+	if _, err := col.Upsert(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to write series", err)
+	}
+	return nil
+}
+func (b Backend) WriteAuthor(titlePath, author string) error {
+	session, col := b.Col()
+	defer session.Close()
+
+	selector := bson.M{"titlePath": titlePath}
+	change := bson.M{"$set": bson.M{"author": author}}
+	if err := col.Update(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update author", err)
+	}
+	return nil
+}
+func (b Backend) WriteMeta(titlePath string, meta articles.Meta) error {
+	session, col := b.Col()
+	defer session.Close()
+
+	selector := bson.M{"titlePath": titlePath}
+	change := bson.M{"$set": bson.M{"meta": meta}}
+	if err := col.Update(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update meta", err)
+	}
+	return nil
+}
+func (b Backend) Pin(titlePath string, pin bool) error {
+	session, col := b.Col()
+	defer session.Close()
+
+	selector := bson.M{"titlePath": titlePath}
+	change := bson.M{"$set": bson.M{"pinned": pin}}
+	if err := col.Update(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update pinned status", err)
+	}
+	return nil
+}
+func (b Backend) Feature(titlePath string, feature bool) error {
+	session, col := b.Col()
+	defer session.Close()
+
+	selector := bson.M{"titlePath": titlePath}
+	change := bson.M{"$set": bson.M{"featured": feature}}
+	if err := col.Update(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update featured status", err)
+	}
+	return nil
+}
+func (b Backend) WriteLang(titlePath, lang string) error {
+	session, col := b.Col()
+	defer session.Close()
+
+	selector := bson.M{"titlePath": titlePath}
+	change := bson.M{"$set": bson.M{"lang": lang}}
+	if err := col.Update(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update language", err)
+	}
+	return nil
+}
+func (b Backend) WriteTranslation(titlePath string, t articles.Translation) error {
+	session, col := b.Col()
+	defer session.Close()
+
+	// replace the existing translation, or add it
+	selector := bson.M{"titlePath": titlePath, "translations.lang": t.Lang}
+	change := bson.M{"$set": bson.M{"translations.$": t}}
+	err := col.Update(selector, change)
+	if err == mgo.ErrNotFound {
+		selector = bson.M{"titlePath": titlePath}
+		change = bson.M{"$push": bson.M{"translations": t}}
+		err = col.Update(selector, change)
+	}
+	if err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to update translation", err)
+	}
+	return nil
+}
+func (b Backend) WriteTranslationRendered(titlePath, lang, rendered string) error {
+	session, col := b.Col()
+	defer session.Close()
+
+	selector := bson.M{"titlePath": titlePath, "translations.lang": lang}
+	change := bson.M{"$set": bson.M{"translations.$.rendered": rendered}}
+	if err := col.Update(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to cache translation's rendered content", err)
+	}
+	return nil
+}
+func (b Backend) RemoveTranslation(titlePath, lang string) error {
+	session, col := b.Col()
+	defer session.Close()
+
+	selector := bson.M{"titlePath": titlePath}
+	change := bson.M{"$pull": bson.M{"translations": bson.M{"lang": lang}}}
+	if err := col.Update(selector, change); err != nil {
+		return backends.NewError(backends.StatusDatastoreError, "Failed to remove translation", err)
+	}
+	return nil
+}
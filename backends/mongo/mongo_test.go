@@ -0,0 +1,56 @@
+package mongo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"labix.org/v2/mgo/bson"
+
+	articles "code.minty.io/wombat-articles"
+)
+
+func TestFindQuery(t *testing.T) {
+	from := time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2013, 7, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query articles.Query
+		want  bson.M
+	}{
+		{"published", articles.Query{}, bson.M{"isPublished": true}},
+		{"unpublished", articles.Query{Published: articles.UnPublished}, bson.M{"isPublished": false}},
+		{"any", articles.Query{Published: articles.AnyPublishState}, bson.M{}},
+
+		// Created, from inclusive, to exclusive
+		{"from", articles.Query{Published: articles.AnyPublishState, From: from},
+			bson.M{"created": bson.M{"$gte": from}}},
+		{"to", articles.Query{Published: articles.AnyPublishState, To: to},
+			bson.M{"created": bson.M{"$lt": to}}},
+		{"range", articles.Query{Published: articles.AnyPublishState, From: from, To: to},
+			bson.M{"created": bson.M{"$gte": from, "$lt": to}}},
+
+		{"tag", articles.Query{Published: articles.AnyPublishState, Tag: "go"}, bson.M{"tags": "go"}},
+		{"author", articles.Query{Published: articles.AnyPublishState, Author: "Ann"}, bson.M{"author": "Ann"}},
+		{"featured", articles.Query{Published: articles.AnyPublishState, Featured: true}, bson.M{"featured": true}},
+		{"thumb", articles.Query{Published: articles.AnyPublishState, HasThumb: true},
+			bson.M{"img.src": bson.M{"$nin": []interface{}{"", nil}}}},
+
+		// Languages, of the article or a translation, the default being
+		// that of articles without one
+		{"lang", articles.Query{Published: articles.AnyPublishState, Lang: "fr"},
+			bson.M{"$or": []bson.M{{"lang": "fr"}, {"translations.lang": "fr"}}}},
+		{"default lang", articles.Query{Published: articles.AnyPublishState, Lang: articles.DefaultLang},
+			bson.M{"$or": []bson.M{{"lang": articles.DefaultLang}, {"translations.lang": articles.DefaultLang},
+				{"lang": bson.M{"$in": []interface{}{"", nil}}}}}},
+
+		// Paging, sorting and pinning aren't part of the query
+		{"combined", articles.Query{Limit: 10, Page: 2, Sort: "title", PinnedFirst: true, Tag: "go", Featured: true},
+			bson.M{"isPublished": true, "tags": "go", "featured": true}},
+	}
+	for _, test := range tests {
+		if got := findQuery(test.query); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: findQuery(%+v)\n got %v\nwant %v", test.name, test.query, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// A backup is a tar.gz of:
+//
+//	backup.json                        BackupInfo, first
+//	articles/<titlePath>article.json   every article, published or not
+//	series/<name>.json                 every series
+//	media/...                          the tree under ImagePath
+const (
+	BackupVersion = 1
+	backupInfo    = "backup.json"
+	backupArticle = "article.json"
+)
+
+var (
+	ErrBackupVersion = errors.New("Unsupported backup version")
+	ErrNotBackup     = errors.New("Not a backup of articles")
+)
+
+// BackupInfo describes a backup. The number of articles, and series, are
+// only known once they're written, so aren't part of the archive.
+type BackupInfo struct {
+	Version  int       `json:"version"`
+	Created  time.Time `json:"created"`
+	Articles int       `json:"-"`
+	Series   int       `json:"-"`
+}
+
+// Backup streams a tar.gz of every article, and series, read by `r`, along
+// with the media under `imagePath`, into `w`. `itoArticles` converts the
+// lists of `r` into articles, as ToArticles does those of this package.
+func Backup(w io.Writer, r Reader, itoArticles func(o interface{}) []*Article, imagePath string) (*BackupInfo, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	info := &BackupInfo{Version: BackupVersion, Created: time.Now()}
+	writeJSON := func(name string, v interface{}) error {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), ModTime: info.Created}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = tw.Write(b)
+		return err
+	}
+	if err := writeJSON(backupInfo, info); err != nil {
+		return info, err
+	}
+
+	// Articles
+	q := Query{Limit: 100, Sort: SortCreated, Published: AnyPublishState}
+	for ; ; q.Page++ {
+		o, err := r.Find(q)
+		if err != nil {
+			return info, err
+		}
+		list := itoArticles(o)
+		if list == nil && listLen(o) != 0 {
+			return info, fmt.Errorf("Unknown list of articles %T", o)
+		}
+		for _, a := range list {
+			if err = writeJSON("articles/"+a.TitlePath+backupArticle, a); err != nil {
+				return info, err
+			}
+			info.Articles++
+		}
+		if len(list) < q.Limit {
+			break
+		}
+	}
+
+	// Series, including those without any articles
+	all, err := r.AllSeries()
+	if err != nil {
+		return info, err
+	}
+	for _, s := range all {
+		if err = writeJSON("series/"+s.Name+".json", s); err != nil {
+			return info, err
+		}
+		info.Series++
+	}
+
+	// Media
+	err = filepath.Walk(imagePath, func(name string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			// The file linked to, when it's one, as its content is what's copied
+			if fi, err = os.Stat(name); err != nil || !fi.Mode().IsRegular() {
+				return nil
+			}
+		}
+		rel, err := filepath.Rel(imagePath, name)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = "media/" + filepath.ToSlash(rel)
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return info, err
+	}
+
+	if err = tw.Close(); err != nil {
+		return info, err
+	}
+	return info, gz.Close()
+}
+
+// listLen returns the length of the list `o` of a Reader, a slice or a
+// pointer to one, and -1 for anything else.
+func listLen(o interface{}) int {
+	if o == nil {
+		return 0
+	}
+	v := reflect.Indirect(reflect.ValueOf(o))
+	if v.Kind() != reflect.Slice {
+		return -1
+	}
+	return v.Len()
+}
+
+// RestoreReport lists what a restore did.
+type RestoreReport struct {
+	Restored      []string
+	Skipped       []string // articles that already exist
+	Series        []string // restored whole, or merged into existing ones
+	SkippedSeries []string // series that already hold all of their articles
+	Media         int
+	SkippedMedia  int // of skipped articles, or that already exist
+}
+
+// Restorer replays backups into any Printer. Articles that already exist,
+// when Reader is set, are skipped, along with their media, unless Overwrite
+// is set, as are media files that already exist. Series that already exist
+// have their missing articles added back, in the backup's order, or are
+// replaced when Overwrite is set.
+type Restorer struct {
+	Printer   Printer
+	Reader    Reader
+	ImagePath string
+	Overwrite bool
+}
+
+func NewRestorer(p Printer, r Reader, imagePath string) *Restorer {
+	return &Restorer{p, r, imagePath, false}
+}
+
+// backupPath returns the clean path of a file of a backup, and false for
+// those escaping it.
+func backupPath(name string) (string, bool) {
+	p := path.Clean("/" + name)
+	return p[1:], p != "/" && !strings.Contains(name, "..")
+}
+
+// mediaTitlePath returns the titlePath of the article a file of media, of
+// path `rel` under ImagePath, belongs to, if any.
+func mediaTitlePath(rel string) string {
+	parts := strings.SplitN(rel, "/", 5)
+	if len(parts) < 5 {
+		return ""
+	}
+	return strings.Join(parts[:4], "/") + "/"
+}
+
+// Restore reads the backup `r`, writing its articles, series and media.
+func (rs *Restorer) Restore(r io.Reader) (*RestoreReport, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	report := new(RestoreReport)
+	var series []*Series
+	skipped := make(map[string]bool)
+	tr := tar.NewReader(gz)
+	for first := true; ; first = false {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+		name, ok := backupPath(hdr.Name)
+		if !ok {
+			return report, fmt.Errorf("Invalid backup path %q", hdr.Name)
+		}
+		if first != (name == backupInfo) {
+			return report, ErrNotBackup
+		}
+
+		switch {
+		case name == backupInfo:
+			var info BackupInfo
+			if err = json.NewDecoder(tr).Decode(&info); err != nil {
+				return report, err
+			}
+			if info.Version != BackupVersion {
+				return report, ErrBackupVersion
+			}
+
+		case strings.HasPrefix(name, "articles/") && strings.HasSuffix(name, "/"+backupArticle):
+			a := new(Article)
+			if err = json.NewDecoder(tr).Decode(a); err != nil {
+				return report, fmt.Errorf("%s: %v", name, err)
+			}
+			n := len(report.Skipped)
+			if err = rs.restoreArticle(a, report); err != nil {
+				return report, err
+			}
+			if len(report.Skipped) > n {
+				skipped[a.TitlePath] = true
+			}
+
+		case strings.HasPrefix(name, "series/"):
+			s := new(Series)
+			if err = json.NewDecoder(tr).Decode(s); err != nil {
+				return report, fmt.Errorf("%s: %v", name, err)
+			}
+			if !ValidSeriesName(s.Name) {
+				return report, fmt.Errorf("%s: Invalid series name %q", name, s.Name)
+			}
+			series = append(series, s)
+
+		case strings.HasPrefix(name, "media/") && hdr.Typeflag != tar.TypeDir:
+			// Media follow the articles, so those of skipped articles are
+			// known, and are left as they are
+			rel := strings.TrimPrefix(name, "media/")
+			if skipped[mediaTitlePath(rel)] {
+				report.SkippedMedia++
+				continue
+			}
+			dst := filepath.Join(rs.ImagePath, filepath.FromSlash(rel))
+			if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return report, err
+			}
+			flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+			if rs.Overwrite {
+				flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			}
+			f, err := os.OpenFile(dst, flag, 0644)
+			if os.IsExist(err) {
+				report.SkippedMedia++
+				continue
+			}
+			if err != nil {
+				return report, err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return report, err
+			}
+			os.Chtimes(dst, hdr.ModTime, hdr.ModTime)
+			report.Media++
+		}
+	}
+
+	// Series, once their articles are restored
+	for _, s := range series {
+		if err := rs.restoreSeries(s, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// restoreSeries writes the series `s` whole, unless it exists, when the
+// articles missing from it, such as those deleted since the backup, are
+// added back in its order.
+func (rs *Restorer) restoreSeries(s *Series, report *RestoreReport) error {
+	var existing *Series
+	if rs.Reader != nil && !rs.Overwrite {
+		if e, err := rs.Reader.Series(s.Name); err == nil {
+			existing = e
+		}
+	}
+	if existing == nil {
+		if err := rs.Printer.WriteSeries(s); err != nil {
+			return err
+		}
+		report.Series = append(report.Series, s.Name)
+		return nil
+	}
+
+	has := make(map[string]bool, len(existing.TitlePaths))
+	for _, tp := range existing.TitlePaths {
+		has[tp] = true
+	}
+	added := false
+	for _, tp := range s.TitlePaths {
+		if !has[tp] {
+			if err := rs.Printer.AddToSeries(s.Name, tp); err != nil {
+				return err
+			}
+			added = true
+		}
+	}
+	if !added {
+		report.SkippedSeries = append(report.SkippedSeries, s.Name)
+		return nil
+	}
+
+	// The backup's order, followed by the articles added since
+	order := append([]string(nil), s.TitlePaths...)
+	backedUp := make(map[string]bool, len(s.TitlePaths))
+	for _, tp := range s.TitlePaths {
+		backedUp[tp] = true
+	}
+	for _, tp := range existing.TitlePaths {
+		if !backedUp[tp] {
+			order = append(order, tp)
+		}
+	}
+	if err := rs.Printer.ReorderSeries(s.Name, order); err != nil {
+		return err
+	}
+	report.Series = append(report.Series, s.Name)
+	return nil
+}
+
+func (rs *Restorer) restoreArticle(a *Article, report *RestoreReport) error {
+	if !ValidTitlePath(a.TitlePath) {
+		return fmt.Errorf("Invalid titlePath %q", a.TitlePath)
+	}
+	exists := false
+	if rs.Reader != nil {
+		_, err := rs.Reader.ByTitlePath(a.TitlePath, true)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+		exists = err == nil
+	}
+	if exists && !rs.Overwrite {
+		report.Skipped = append(report.Skipped, a.TitlePath)
+		return nil
+	}
+
+	// Replaced in place, rather than deleted, which would take it out of
+	// series that aren't part of the backup
+	a.Printer = rs.Printer
+	var err error
+	if exists {
+		err = rs.Printer.Replace(a.TitlePath, a)
+	} else {
+		err = a.Print()
+	}
+	if err != nil {
+		return err
+	}
+	report.Restored = append(report.Restored, a.TitlePath)
+	return nil
+}
@@ -0,0 +1,298 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackupRestore(t *testing.T) {
+	day := time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)
+	b := newMemBackend(
+		&Article{TitlePath: "2013/06/01/a/", Title: "A", IsPublished: true, Created: day},
+		&Article{TitlePath: "2013/06/02/b/", Title: "B", Created: day.AddDate(0, 0, 1)},
+		&Article{TitlePath: "2013/06/03/c/", Title: "C", IsPublished: true, Created: day.AddDate(0, 0, 2)})
+	b.WriteSeries(&Series{Name: "Go", TitlePaths: []string{"2013/06/03/c/", "2013/06/01/a/"}})
+	b.WriteSeries(&Series{Name: "Also", TitlePaths: []string{"2013/06/01/a/"}})
+	b.WriteSeries(&Series{Name: "Empty"})
+	b.WriteSeries(&Series{Name: "Orphaned", TitlePaths: []string{"2012/01/01/gone/"}})
+
+	src, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	os.MkdirAll(filepath.Join(src, "2013", "06", "01", "a"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "2013", "06", "01", "a", "photo.png"), []byte("png"), 0644)
+
+	var buf bytes.Buffer
+	info, err := Backup(&buf, b, ToArticles, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Articles != 3 || info.Series != 4 {
+		t.Errorf("backed up %d articles, %d series, want 3 and 4", info.Articles, info.Series)
+	}
+
+	// Into an empty backend
+	dst, err := ioutil.TempDir("", "restore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+	restored := newMemBackend()
+	report, err := NewRestorer(restored, restored, dst).Restore(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Restored) != 3 || len(report.Series) != 4 || report.Media != 1 {
+		t.Errorf("restored %+v", report)
+	}
+	for tp, a := range b.articles {
+		if r, ok := restored.articles[tp]; !ok || r.Title != a.Title || r.IsPublished != a.IsPublished {
+			t.Errorf("%s restored as %+v, want %+v", tp, r, a)
+		}
+	}
+	want, _ := b.AllSeries()
+	got, _ := restored.AllSeries()
+	if len(got) != len(want) {
+		t.Fatalf("restored %d series, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || !reflect.DeepEqual(got[i].TitlePaths, want[i].TitlePaths) {
+			t.Errorf("series %s restored as %v, want %v", want[i].Name, got[i].TitlePaths, want[i].TitlePaths)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dst, "2013", "06", "01", "a", "photo.png")); err != nil {
+		t.Error(err)
+	}
+
+	// Again, skipping what exists, media included, unless overwriting
+	photo := filepath.Join(dst, "2013", "06", "01", "a", "photo.png")
+	restored.ReorderSeries("Go", []string{"2013/06/01/a/", "2013/06/03/c/"})
+	ioutil.WriteFile(photo, []byte("edited"), 0644)
+	rs := NewRestorer(restored, restored, dst)
+	if report, err = rs.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Skipped) != 3 || len(report.SkippedSeries) != 4 || report.Media != 0 || report.SkippedMedia != 1 {
+		t.Errorf("restored again %+v, want everything skipped", report)
+	}
+	if s, _ := restored.Series("Go"); s.TitlePaths[0] != "2013/06/01/a/" {
+		t.Errorf("skipped series reordered to %v", s.TitlePaths)
+	}
+	if b, _ := ioutil.ReadFile(photo); string(b) != "edited" {
+		t.Errorf("image of a skipped article restored as %q", b)
+	}
+	rs.Overwrite = true
+	if report, err = rs.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := restored.Series("Go"); s.TitlePaths[0] != "2013/06/03/c/" {
+		t.Errorf("overwritten series ordered %v, want as backed up", s.TitlePaths)
+	}
+	if b, _ := ioutil.ReadFile(photo); string(b) != "png" || report.Media != 1 {
+		t.Errorf("overwritten image restored as %q, %d images", b, report.Media)
+	}
+}
+
+func TestRestoreMedia(t *testing.T) {
+	b := newMemBackend(&Article{TitlePath: "2013/06/01/a/", Title: "A"})
+	src, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	os.MkdirAll(filepath.Join(src, "2013", "06", "01", "a"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "2013", "06", "01", "a", "a.png"), []byte("png"), 0644)
+	ioutil.WriteFile(filepath.Join(src, "site.png"), []byte("png"), 0644)
+	var buf bytes.Buffer
+	if _, err := Backup(&buf, b, ToArticles, src); err != nil {
+		t.Fatal(err)
+	}
+
+	// Media of a restored article, and of none, are written unless they
+	// already exist
+	dst, err := ioutil.TempDir("", "restore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+	ioutil.WriteFile(filepath.Join(dst, "site.png"), []byte("edited"), 0644)
+	restored := newMemBackend()
+	report, err := NewRestorer(restored, restored, dst).Restore(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Restored) != 1 || report.Media != 1 || report.SkippedMedia != 1 {
+		t.Errorf("restored %+v", report)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dst, "site.png")); string(b) != "edited" {
+		t.Errorf("existing image overwritten with %q", b)
+	}
+}
+
+func TestRestoreSeriesName(t *testing.T) {
+	b := newMemBackend(&Article{TitlePath: "2013/06/01/a/", Title: "A"})
+	b.WriteSeries(&Series{Name: "../x", TitlePaths: []string{"2013/06/01/a/"}})
+	var buf bytes.Buffer
+	if _, err := Backup(&buf, b, ToArticles, ""); err != nil {
+		t.Fatal(err)
+	}
+	restored := newMemBackend()
+	if _, err := NewRestorer(restored, restored, "").Restore(&buf); err == nil {
+		t.Error("restored a series of an invalid name")
+	}
+	if all, _ := restored.AllSeries(); len(all) != 0 {
+		t.Errorf("restored %d series", len(all))
+	}
+}
+
+func TestRestoreDeleted(t *testing.T) {
+	b := newMemBackend(
+		&Article{TitlePath: "2013/06/01/a/", Title: "A"},
+		&Article{TitlePath: "2013/06/02/b/", Title: "B"},
+		&Article{TitlePath: "2013/06/03/c/", Title: "C"})
+	b.WriteSeries(&Series{Name: "Go", TitlePaths: []string{"2013/06/03/c/", "2013/06/01/a/", "2013/06/02/b/"}})
+	dir, err := ioutil.TempDir("", "restore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var buf bytes.Buffer
+	if _, err := Backup(&buf, b, ToArticles, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// An accidental delete, after which an article joins the series
+	b.Delete("2013/06/01/a/")
+	b.Print(&Article{TitlePath: "2013/06/04/d/", Title: "D"})
+	b.AddToSeries("Go", "2013/06/04/d/")
+
+	for _, overwrite := range []bool{false, true} {
+		rs := NewRestorer(b, b, dir)
+		rs.Overwrite = overwrite
+		report, err := rs.Restore(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"2013/06/03/c/", "2013/06/01/a/", "2013/06/02/b/", "2013/06/04/d/"}
+		if overwrite {
+			want = want[:3]
+		}
+		if s, _ := b.Series("Go"); !reflect.DeepEqual(s.TitlePaths, want) {
+			t.Errorf("overwrite %v: series restored as %v, want %v", overwrite, s.TitlePaths, want)
+		}
+		if len(report.Series) != 1 || len(report.SkippedSeries) != 0 {
+			t.Errorf("overwrite %v: restored %+v", overwrite, report)
+		}
+		if _, err := b.article("2013/06/01/a/"); err != nil {
+			t.Errorf("overwrite %v: %v", overwrite, err)
+		}
+	}
+}
+
+func TestBackupMedia(t *testing.T) {
+	b := newMemBackend(&Article{TitlePath: "2013/06/01/a/", Title: "A"})
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "2013", "06", "01", "a")
+	os.MkdirAll(a, 0755)
+	ioutil.WriteFile(filepath.Join(a, "photo.png"), []byte("png"), 0644)
+	if err = os.Symlink(filepath.Join(a, "photo.png"), filepath.Join(a, "linked.png")); err != nil {
+		t.Skip(err)
+	}
+	os.Symlink(filepath.Join(dir, "missing.png"), filepath.Join(a, "broken.png"))
+
+	// Links are backed up as the file they link to, broken ones skipped
+	var buf bytes.Buffer
+	if _, err := Backup(&buf, b, ToArticles, dir); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := ioutil.TempDir("", "restore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+	restored := newMemBackend()
+	report, err := NewRestorer(restored, restored, dst).Restore(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Media != 2 {
+		t.Errorf("restored %d images, want 2", report.Media)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dst, "2013", "06", "01", "a", "linked.png")); string(b) != "png" {
+		t.Errorf("linked image restored as %q", b)
+	}
+}
+
+func TestBackupUnknownList(t *testing.T) {
+	b := newMemBackend(&Article{TitlePath: "2013/06/01/a/", Title: "A"})
+	none := func(o interface{}) []*Article { return nil }
+	if _, err := Backup(ioutil.Discard, b, none, ""); err == nil {
+		t.Error("backed up a list of articles that didn't convert")
+	}
+
+	// An empty list is of no articles, whatever its type
+	empty := newMemBackend()
+	if info, err := Backup(ioutil.Discard, empty, none, ""); err != nil || info.Articles != 0 {
+		t.Errorf("backup of no articles = %+v, %v", info, err)
+	}
+}
+
+// failingReader fails to read any article, as a backend that's down.
+type failingReader struct {
+	*memBackend
+}
+
+func (r failingReader) ByTitlePath(titlePath string, unPublished bool) (interface{}, error) {
+	return nil, errors.New("Connection refused")
+}
+
+func TestRestoreExisting(t *testing.T) {
+	b := newMemBackend(&Article{TitlePath: "2013/06/01/a/", Title: "A"})
+	var buf bytes.Buffer
+	if _, err := Backup(&buf, b, ToArticles, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// A series created since the backup, and a change since
+	b.AddToSeries("Later", "2013/06/01/a/")
+	b.UpdateSynopsis("2013/06/01/a/", "changed", time.Now())
+
+	// Errors reading articles aren't taken for them not existing
+	rs := NewRestorer(b, failingReader{b}, "")
+	rs.Overwrite = true
+	if _, err := rs.Restore(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("restored without knowing if articles exist")
+	}
+
+	// Overwriting replaces the article, leaving it in its series
+	rs.Reader = b
+	report, err := rs.Restore(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Restored) != 1 {
+		t.Errorf("restored %+v", report)
+	}
+	if a, _ := b.article("2013/06/01/a/"); a.Synopsis != "" {
+		t.Errorf("synopsis %q kept, want it replaced", a.Synopsis)
+	}
+	if s, _ := b.Series("Later"); !reflect.DeepEqual(s.TitlePaths, []string{"2013/06/01/a/"}) {
+		t.Errorf("series created since the backup holds %v", s.TitlePaths)
+	}
+}
@@ -2,5 +2,9 @@
 
 go build ./\
 	./backends/mongo \
-	./handlers
+	./handlers \
+	./importers \
+	./cmd/articles-backup \
+	./cmd/articles-export \
+	./cmd/articles-import
 
@@ -0,0 +1,91 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command articles-backup writes a tar.gz of every article, and the images,
+// or restores one into the configured backend.
+//
+//	articles-backup [-out backup.tar.gz]
+//	articles-backup -restore backup.tar.gz [-overwrite]
+//
+// Without -out the backup is written to stdout. As a restore only needs a
+// Printer, a backup of one backend may be restored into another. A running
+// server shows the restored articles in its sitemap, and related articles,
+// once their caches expire, after the `cacheMaxAge` config.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"code.minty.io/config"
+	articles "code.minty.io/wombat-articles"
+	_ "code.minty.io/wombat-articles/backends/mongo"
+	"code.minty.io/wombat/backends"
+)
+
+var (
+	out       = flag.String("out", "", "file to write the backup to, rather than stdout")
+	restore   = flag.String("restore", "", "backup to restore")
+	overwrite = flag.Bool("overwrite", false, "replace existing articles, and series, when restoring, rather than skipping articles and merging series")
+)
+
+func main() {
+	flag.Parse()
+	reader := articles.New().Reader
+	imagePath := config.RequiredGroupString("articles", "imagePath")
+
+	if *restore == "" {
+		var w io.Writer = os.Stdout
+		if *out != "" {
+			f, err := os.Create(*out)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			w = f
+		}
+		info, err := articles.Backup(w, reader, articles.ToArticles, imagePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%d articles, %d series backed up\n", info.Articles, info.Series)
+		return
+	}
+
+	o, err := backends.Open("wombat:apps:article-printer")
+	if err != nil {
+		log.Fatal("No 'article' printer available")
+	}
+	printer, ok := o.(articles.Printer)
+	if !ok {
+		log.Fatal("Invalid 'article' printer")
+	}
+
+	f, err := os.Open(*restore)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	r := articles.NewRestorer(printer, reader, imagePath)
+	r.Overwrite = *overwrite
+	report, err := r.Restore(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, tp := range report.Skipped {
+		fmt.Printf("skip  %s: already exists\n", tp)
+	}
+	for _, name := range report.SkippedSeries {
+		fmt.Printf("skip  series %s: unchanged\n", name)
+	}
+	if report.SkippedMedia > 0 {
+		fmt.Printf("skip  %d images: already exist\n", report.SkippedMedia)
+	}
+	fmt.Printf("%d articles, %d series, %d images restored\n",
+		len(report.Restored), len(report.Series), report.Media)
+}
@@ -0,0 +1,42 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command articles-export writes the published articles as a static site.
+//
+//	articles-export -out ./public -site https://example.com [-incremental]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	_ "code.minty.io/wombat-articles/backends/mongo"
+	"code.minty.io/wombat-articles/handlers"
+)
+
+var (
+	out         = flag.String("out", "", "directory of the static site")
+	site        = flag.String("site", "", "URL of the site, overriding the configured siteURL")
+	incremental = flag.Bool("incremental", false, "only write the files changed since the last export")
+)
+
+func main() {
+	flag.Parse()
+	if *out == "" {
+		log.Fatal("Missing -out directory")
+	}
+
+	h := handlers.New()
+	if *site != "" {
+		h.SiteURL = *site
+	}
+
+	report, err := h.Export(*out, *incremental)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d files written, %d unchanged, %d removed\n",
+		len(report.Written), len(report.Skipped), len(report.Removed))
+}
@@ -0,0 +1,92 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command articles-import imports the posts of other blogging platforms.
+//
+//	articles-import -wxr export.xml [-uploads ./wp-content/uploads] [-dry-run]
+//	articles-import -markdown ./_posts [-root .] [-dry-run]
+//
+// WordPress attachments are downloaded, unless a local copy of the uploads
+// directory is given. Markdown posts, such as Jekyll's `_posts` or Hugo's
+// `content/posts`, may be imported again, updating the articles.
+//
+// A running server shows the imported articles in its sitemap, and related
+// articles, once their caches expire, after the `cacheMaxAge` config.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"code.minty.io/config"
+	articles "code.minty.io/wombat-articles"
+	_ "code.minty.io/wombat-articles/backends/mongo"
+	"code.minty.io/wombat-articles/importers"
+	"code.minty.io/wombat/backends"
+)
+
+var (
+	wxr      = flag.String("wxr", "", "WordPress export (WXR) file to import")
+	markdown = flag.String("markdown", "", "directory of Markdown posts to import")
+	root     = flag.String("root", ".", "site of the Markdown posts, for root relative images")
+	uploads  = flag.String("uploads", "", "local copy of wp-content/uploads, rather than downloading attachments")
+	dryRun   = flag.Bool("dry-run", false, "report what would be imported, without writing anything")
+	sanitize = flag.Bool("sanitize", true, "sanitize the imported content")
+)
+
+func main() {
+	flag.Parse()
+	if *wxr == "" && *markdown == "" {
+		log.Fatal("Missing -wxr file, or -markdown directory")
+	}
+
+	o, err := backends.Open("wombat:apps:article-printer")
+	if err != nil {
+		log.Fatal("No 'article' printer available")
+	}
+	printer, ok := o.(articles.Printer)
+	if !ok {
+		log.Fatal("Invalid 'article' printer")
+	}
+
+	reader := articles.New().Reader
+	imagePath := config.RequiredGroupString("articles", "imagePath")
+	mediaURL := config.RequiredGroupString("articles", "mediaURL")
+
+	if *markdown != "" {
+		m := importers.NewMarkdown(printer, reader, *root, imagePath, mediaURL)
+		m.DryRun = *dryRun
+		report, err := m.Import(*markdown)
+		if err != nil {
+			log.Fatal(err)
+		}
+		report.Print(os.Stdout)
+		return
+	}
+
+	var fetcher importers.Fetcher = importers.HTTPFetcher{}
+	if *uploads != "" {
+		fetcher = importers.DirFetcher{Dir: *uploads, Prefix: "/wp-content/uploads/"}
+	}
+
+	w := importers.NewWXR(printer, fetcher, imagePath, mediaURL)
+	w.Reader = reader
+	w.DryRun = *dryRun
+	if *sanitize {
+		w.Policy = articles.DefaultPolicy()
+	}
+
+	f, err := os.Open(*wxr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	report, err := w.Import(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	report.Print(os.Stdout)
+}
@@ -0,0 +1,453 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Image types every EPUB reader supports.
+var epubImageTypes = map[string]bool{
+	"image/gif": true, "image/jpeg": true, "image/png": true,
+	"image/svg+xml": true, "image/webp": true,
+}
+
+// closesPara are the tags ending an open paragraph.
+var closesPara = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "div": true,
+	"dl": true, "figure": true, "footer": true, "form": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"nav": true, "ol": true, "p": true, "pre": true, "section": true, "table": true,
+	"ul": true,
+}
+
+// xhtmlDrop are the elements dropped from XHTML, along with their content.
+// The case of SVG's, and MathML's, attributes, and their namespaces, are
+// lost to tokenizing. Iframes, such as embedded videos, are replaced by a
+// link to their source.
+var xhtmlDrop = map[string]bool{"script": true, "svg": true, "math": true, "iframe": true}
+
+// remoteMedia are the elements whose source may be outside of the book,
+// needing the remote-resources property of their chapter.
+var remoteMedia = map[string]bool{"audio": true, "video": true, "source": true, "track": true}
+
+// tableEnds are the open table elements ended by a start tag, within the
+// same table, as cells and rows needn't be closed.
+var tableEnds = map[string]map[string]bool{
+	"td":    {"td": true, "th": true},
+	"th":    {"td": true, "th": true},
+	"tr":    {"tr": true},
+	"thead": {"thead": true, "tbody": true, "tfoot": true},
+	"tbody": {"thead": true, "tbody": true, "tfoot": true},
+	"tfoot": {"thead": true, "tbody": true, "tfoot": true},
+}
+
+// xmlName matches the attribute names kept in XHTML, those with a namespace
+// prefix needing a declaration.
+var xmlName = regexp.MustCompile(`^[a-z_][-a-z0-9_.]*$`)
+
+// EPUB is an EPUB 3 book of articles, one chapter each, oldest first.
+type EPUB struct {
+	Title     string
+	Author    string // of articles without one
+	Lang      string
+	ID        string // unique identifier, such as the URL of what it's of
+	MediaURL  string // URL of the articles' images, as their content links to them
+	ImagePath string
+	Articles  []*Article
+}
+
+// NewEPUB returns the book of `list`, sorted by creation, in the language
+// most of its articles are localized to.
+func NewEPUB(title, id, mediaURL, imagePath string, list []*Article) *EPUB {
+	e := &EPUB{Title: title, Lang: DefaultLang, ID: id,
+		MediaURL: mediaURL, ImagePath: imagePath, Articles: list}
+	sort.Sort(sort.Reverse(byCreated(e.Articles)))
+
+	counts := make(map[string]int)
+	for _, a := range e.Articles {
+		lang := a.Localized()
+		if counts[lang]++; counts[lang] > counts[e.Lang] {
+			e.Lang = lang
+		}
+	}
+	return e
+}
+
+// Render renders the content of the articles, as they're shown on their own.
+func (e *EPUB) Render(p *Pipeline) {
+	for _, a := range e.Articles {
+		if _, err := a.Render(p); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// Modified is when an article of the book was last modified.
+func (e *EPUB) Modified() time.Time {
+	return (&Feed{Articles: e.Articles}).Updated()
+}
+
+func (e *EPUB) chapter(i int) string {
+	return fmt.Sprintf("chapter-%d.xhtml", i+1)
+}
+
+// epubImg is an image file of the book.
+type epubImg struct {
+	ID, Href, Type, File string
+	Cover                bool
+}
+
+// images returns the images of the articles found under ImagePath, keyed by
+// the URL their content links to them with.
+func (e *EPUB) images() (map[string]*epubImg, []*epubImg) {
+	byURL := make(map[string]*epubImg)
+	var list []*epubImg
+	for _, a := range e.Articles {
+		imgs := a.Imgs
+		if a.Img.Src != "" {
+			imgs = append([]Img{a.Img}, imgs...)
+		}
+		for _, img := range imgs {
+			u := e.MediaURL + a.TitlePath + img.Src
+			if _, ok := byURL[u]; ok || strings.Contains(img.Src, "..") {
+				continue
+			}
+			t := mime.TypeByExtension(path.Ext(img.Src))
+			if i := strings.IndexByte(t, ';'); i >= 0 {
+				t = t[:i]
+			}
+			file := filepath.Join(e.ImagePath, filepath.FromSlash(a.TitlePath), img.Src)
+			if _, err := os.Stat(file); err != nil || !epubImageTypes[t] {
+				continue
+			}
+			ei := &epubImg{ID: fmt.Sprintf("img-%d", len(list)+1),
+				Href: "images/" + a.TitlePath + img.Src, Type: t, File: file,
+				Cover: len(list) == 0 && a == e.Articles[0] && img == a.Img}
+			byURL[u] = ei
+			list = append(list, ei)
+		}
+	}
+	return byURL, list
+}
+
+// Write writes the book, as a zip, to `w`.
+func (e *EPUB) Write(w io.Writer) error {
+	z := zip.NewWriter(w)
+	modified := e.Modified()
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+	add := func(name string, method uint16, b []byte) error {
+		hdr := &zip.FileHeader{Name: name, Method: method}
+		hdr.SetModTime(modified)
+		f, err := z.CreateHeader(hdr)
+		if err == nil {
+			_, err = f.Write(b)
+		}
+		return err
+	}
+
+	// The mimetype comes first, uncompressed, identifying the zip as a book
+	if err := add("mimetype", zip.Store, []byte("application/epub+zip")); err != nil {
+		return err
+	}
+	if err := add("META-INF/container.xml", zip.Deflate, []byte(epubContainer)); err != nil {
+		return err
+	}
+
+	byURL, imgs := e.images()
+	var opf, nav bytes.Buffer
+	fmt.Fprintf(&opf, `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid" xml:lang="%s">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="uid">%s</dc:identifier>
+<dc:title>%s</dc:title>
+<dc:language>%s</dc:language>
+`, xmlEscape(e.Lang), xmlEscape(e.ID), xmlEscape(e.Title), xmlEscape(e.Lang))
+	for _, author := range e.authors() {
+		fmt.Fprintf(&opf, "<dc:creator>%s</dc:creator>\n", xmlEscape(author))
+	}
+	fmt.Fprintf(&opf, `<meta property="dcterms:modified">%s</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+`, modified.UTC().Format("2006-01-02T15:04:05Z"))
+	chapters := make([]string, len(e.Articles))
+	for i, a := range e.Articles {
+		chapters[i] = XHTML(a.HTML, func(src string) (string, bool) {
+			img, ok := byURL[src]
+			if !ok {
+				img, ok = byURL[e.MediaURL+a.TitlePath+src]
+			}
+			if !ok {
+				return "", false
+			}
+			return img.Href, true
+		})
+		props := ""
+		if hasRemoteMedia(chapters[i]) {
+			props = ` properties="remote-resources"`
+		}
+		fmt.Fprintf(&opf, `<item id="c%d" href="%s" media-type="application/xhtml+xml"%s/>`+"\n", i+1, e.chapter(i), props)
+	}
+	for _, img := range imgs {
+		props := ""
+		if img.Cover {
+			props = ` properties="cover-image"`
+		}
+		fmt.Fprintf(&opf, `<item id="%s" href="%s" media-type="%s"%s/>`+"\n",
+			img.ID, xmlEscape(img.Href), img.Type, props)
+	}
+	opf.WriteString("</manifest>\n<spine>\n")
+	for i := range e.Articles {
+		fmt.Fprintf(&opf, `<itemref idref="c%d"/>`+"\n", i+1)
+	}
+	opf.WriteString("</spine>\n</package>\n")
+	if err := add("OEBPS/content.opf", zip.Deflate, opf.Bytes()); err != nil {
+		return err
+	}
+
+	// Navigation, of the articles and their headings
+	nav.WriteString(xhtmlHead(e.Lang, e.Title))
+	nav.WriteString(`<nav epub:type="toc" id="toc"><h1>` + xmlEscape(e.Title) + "</h1>\n<ol>\n")
+	for i, a := range e.Articles {
+		fmt.Fprintf(&nav, `<li><a href="%s">%s</a>`, e.chapter(i), xmlEscape(a.Title))
+		navHeadings(&nav, e.chapter(i), TOC(a.HTML))
+		nav.WriteString("</li>\n")
+	}
+	nav.WriteString("</ol>\n</nav>\n</body>\n</html>\n")
+	if err := add("OEBPS/nav.xhtml", zip.Deflate, nav.Bytes()); err != nil {
+		return err
+	}
+
+	// Chapters, linking to the images within the book
+	for i, a := range e.Articles {
+		var b bytes.Buffer
+		b.WriteString(xhtmlHead(a.Localized(), a.Title))
+		b.WriteString("<h1>" + xmlEscape(a.Title) + "</h1>\n")
+		if author := e.author(a); author != "" {
+			b.WriteString(`<p class="byline">` + xmlEscape(author) + "</p>\n")
+		}
+		b.WriteString(chapters[i])
+		b.WriteString("\n</body>\n</html>\n")
+		if err := add("OEBPS/"+e.chapter(i), zip.Deflate, b.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	// Images, already compressed
+	for _, img := range imgs {
+		b, err := ioutil.ReadFile(img.File)
+		if err != nil {
+			return err
+		}
+		if err = add("OEBPS/"+img.Href, zip.Store, b); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+func (e *EPUB) author(a *Article) string {
+	if a.Author != "" {
+		return a.Author
+	}
+	return e.Author
+}
+
+// authors returns the distinct authors of the articles, in order.
+func (e *EPUB) authors() []string {
+	var authors []string
+	seen := make(map[string]bool)
+	for _, a := range e.Articles {
+		if author := e.author(a); author != "" && !seen[author] {
+			seen[author] = true
+			authors = append(authors, author)
+		}
+	}
+	return authors
+}
+
+// hasRemoteMedia reports if the XHTML `s` has audio, or video, from outside
+// of the book.
+func hasRemoteMedia(s string) bool {
+	for _, t := range tokenize(s) {
+		if t.Type != startToken || !remoteMedia[t.Name] {
+			continue
+		}
+		for _, key := range []string{"src", "poster"} {
+			if u, _ := t.Attr(key); strings.Contains(u, "//") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func navHeadings(b *bytes.Buffer, chapter string, headings []*Heading) {
+	if len(headings) == 0 {
+		return
+	}
+	b.WriteString("\n<ol>\n")
+	for _, h := range headings {
+		fmt.Fprintf(b, `<li><a href="%s#%s">%s</a>`, chapter, xmlEscape(h.ID), xmlEscape(h.Text))
+		navHeadings(b, chapter, h.Children)
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ol>\n")
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+
+func xhtmlHead(lang, title string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%s" lang="%s">
+<head>
+<meta charset="UTF-8"/>
+<title>%s</title>
+</head>
+<body>
+`, xmlEscape(lang), xmlEscape(lang), xmlEscape(title))
+}
+
+// xmlEscape escapes `s` for XML, dropping the characters XML can't contain.
+func xmlEscape(s string) string {
+	return html.EscapeString(strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xfffe || r == 0xffff {
+			return -1
+		}
+		return r
+	}, s))
+}
+
+// XHTML converts the HTML `s` into well formed XHTML, as EPUB requires.
+// Scripts, SVG, MathML and comments are dropped, iframes replaced by a link
+// to their source, void elements closed, and unbalanced tags closed or
+// dropped. `src` maps the source of each image to a file of the book, images
+// it rejects being replaced by their alternative text.
+func XHTML(s string, src func(string) (string, bool)) string {
+	var b bytes.Buffer
+	var open []string
+	drop := 0 // depth within dropped elements
+	closeTo := func(i int) {
+		for len(open) > i {
+			b.WriteString("</" + open[len(open)-1] + ">")
+			open = open[:len(open)-1]
+		}
+	}
+
+	for _, t := range tokenize(s) {
+		switch t.Type {
+		case textToken:
+			if drop == 0 {
+				b.WriteString(xmlEscape(html.UnescapeString(t.Data)))
+			}
+
+		case endToken:
+			if drop > 0 {
+				if xhtmlDrop[t.Name] {
+					drop--
+				}
+				continue
+			}
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j] == t.Name {
+					closeTo(j)
+					break
+				}
+			}
+
+		case startToken:
+			if drop > 0 || xhtmlDrop[t.Name] {
+				if u, _ := t.Attr("src"); drop == 0 && t.Name == "iframe" && u != "" {
+					b.WriteString(`<a href="` + xmlEscape(u) + `">` + xmlEscape(u) + "</a>")
+				}
+				if xhtmlDrop[t.Name] && !t.SelfClosing {
+					drop++
+				}
+				continue
+			}
+			if !xmlName.MatchString(t.Name) {
+				continue
+			}
+			// Blocks end paragraphs, and list items those before them
+			if n := len(open); n > 0 && (open[n-1] == "p" && closesPara[t.Name] || open[n-1] == "li" && t.Name == "li") {
+				closeTo(n - 1)
+			}
+			// Cells end the cell before them, and rows the row
+			if ends, ok := tableEnds[t.Name]; ok {
+				for j := len(open) - 1; j >= 0 && open[j] != "table"; j-- {
+					if ends[open[j]] {
+						closeTo(j)
+						break
+					}
+				}
+			}
+
+			var attrs []attr
+			seen := make(map[string]bool)
+			for _, a := range t.Attrs {
+				if seen[a.Key] || !xmlName.MatchString(a.Key) ||
+					strings.HasPrefix(a.Key, "on") || a.Key == "srcset" {
+					continue
+				}
+				seen[a.Key] = true
+				attrs = append(attrs, a)
+			}
+			if t.Name == "img" {
+				alt, _ := t.Attr("alt")
+				u, _ := t.Attr("src")
+				if u, ok := src(u); ok {
+					for i, a := range attrs {
+						if a.Key == "src" {
+							attrs[i].Val = u
+						}
+					}
+					if !seen["alt"] {
+						attrs = append(attrs, attr{"alt", ""})
+					}
+				} else {
+					b.WriteString(xmlEscape(alt))
+					continue
+				}
+			}
+
+			b.WriteString("<" + t.Name)
+			for _, a := range attrs {
+				b.WriteString(" " + a.Key + `="` + xmlEscape(a.Val) + `"`)
+			}
+			if voidTags[t.Name] {
+				b.WriteString("/>")
+			} else {
+				b.WriteString(">")
+				open = append(open, t.Name)
+			}
+		}
+	}
+	closeTo(0)
+	return b.String()
+}
@@ -0,0 +1,312 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestXHTML(t *testing.T) {
+	src := func(u string) (string, bool) {
+		if u == "/media/a.png" {
+			return "images/a.png", true
+		}
+		return "", false
+	}
+	tests := []struct {
+		name, in, out string
+	}{
+		{"void", `a<br>b<hr><img src="/media/a.png" alt="A">`, `a<br/>b<hr/><img src="images/a.png" alt="A"/>`},
+		{"entities", `<p>&nbsp;&lt;&amp;&quot;&#169;</p>`, "<p>\u00a0&lt;&amp;&#34;\u00a9</p>"},
+		{"control", "<p>a\x00b\x0bc\td</p>", "<p>abc\td</p>"},
+		{"comment", `a<!-- <b> -->b`, `ab`},
+
+		// Dropped, along with their content
+		{"script", `<p>a<script>if (a < b) { x("</p>") }</script>b</p>`, `<p>ab</p>`},
+		{"svg", `<p>a<svg viewBox="0 0 1 1"><circle r="1"/><text>t</text></svg>b</p>`, `<p>ab</p>`},
+		{"nested svg", `<svg><svg><g/></svg>x</svg>y`, `y`},
+		{"self-closed svg", `<svg/>a`, `a`},
+		{"math", `<math><mi>x</mi><mo>=</mo><mn>1</mn></math>!`, `!`},
+
+		// Iframes, replaced by a link to their source
+		{"iframe", `<div><iframe src="https://example.com/embed/a?b=1&amp;c=2" width="560">x</iframe></div>`,
+			`<div><a href="https://example.com/embed/a?b=1&amp;c=2">https://example.com/embed/a?b=1&amp;c=2</a></div>`},
+		{"iframe no source", `<p>a<iframe></iframe>b</p>`, `<p>ab</p>`},
+		{"iframe in svg", `<svg><iframe src="x"></iframe></svg>a`, `a`},
+
+		// Attributes
+		{"attributes", `<p ONCLICK="x" Class='c' class="d" xlink:href="x" srcset="a 2x" data-x="<&>">t</p>`,
+			`<p class="c" data-x="&lt;&amp;&gt;">t</p>`},
+		{"img no alt", `<img src="/media/a.png">`, `<img src="images/a.png" alt=""/>`},
+		{"img fallback", `<p><img src="http://example.com/b.png" alt="A &lt;b&gt;"></p>`, `<p>A &lt;b&gt;</p>`},
+		{"img no fallback", `<p>x<img src="b.png"></p>`, `<p>x</p>`},
+
+		// Unbalanced tags
+		{"unclosed", `<div><b>a`, `<div><b>a</b></div>`},
+		{"stray end", `a</b></div>b`, `ab`},
+		{"misnested", `<b><i>a</b>b</i>`, `<b><i>a</i></b>b`},
+		{"paragraphs", `<p>a<p>b<div>c</div>`, `<p>a</p><p>b</p><div>c</div>`},
+		{"list items", `<ul><li>a<li>b<ul><li>c</ul></ul>`, `<ul><li>a</li><li>b<ul><li>c</li></ul></li></ul>`},
+		{"cells", `<table><tr><td>a<td>b<th>c</table>`, `<table><tr><td>a</td><td>b</td><th>c</th></tr></table>`},
+		{"rows", `<table><tr><td>a<tr><td><p>b<tr><th>c</table>`,
+			`<table><tr><td>a</td></tr><tr><td><p>b</p></td></tr><tr><th>c</th></tr></table>`},
+		{"sections", `<table><thead><tr><th>a<tbody><tr><td>b<tfoot><tr><td>c</table>`,
+			`<table><thead><tr><th>a</th></tr></thead><tbody><tr><td>b</td></tr></tbody><tfoot><tr><td>c</td></tr></tfoot></table>`},
+		{"nested table", `<table><tr><td><table><tr><td>a<td>b</table><td>c</table>`,
+			`<table><tr><td><table><tr><td>a</td><td>b</td></tr></table></td><td>c</td></tr></table>`},
+	}
+	for _, test := range tests {
+		if got := XHTML(test.in, src); got != test.out {
+			t.Errorf("%s: XHTML(%q)\n got %q\nwant %q", test.name, test.in, got, test.out)
+		}
+	}
+}
+
+func readZipFile(t *testing.T, f *zip.File) []byte {
+	r, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// wellFormed reports whether `b` parses as XML, strictly.
+func wellFormed(b []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.Strict = true
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func TestEPUBWrite(t *testing.T) {
+	// The oldest article has a 5 byte thumbnail
+	imagePath, err := ioutil.TempDir("", "epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(imagePath)
+	dir := filepath.Join(imagePath, "2013", "06", "01", "a")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.png"), []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEPUB("Fish & Chips", "http://example.com/articles", "/media/", imagePath, []*Article{
+		{TitlePath: "2013/06/02/b/", Title: "B", Lang: "fr",
+			HTML:    `<p>B<table><tr><td>1<td>2</table><svg viewBox="0 0 1 1"></svg>`,
+			Created: time.Date(2013, 6, 2, 10, 0, 0, 0, time.UTC)},
+		{TitlePath: "2013/06/01/a/", Title: "A < B", Author: "Ann",
+			Img:  Img{Src: "a.png"},
+			Imgs: []Img{{Src: "a.png"}, {Src: "missing.png"}},
+			HTML: `<h2 id="intro">Intro</h2><p><img src="a.png" alt="A"><img src="/media/2013/06/01/a/missing.png" alt="Gone">` +
+				`<h3 id="more">More &amp; more</h3><p>text<br><video src="https://example.com/a.mp4" controls></video>`,
+			Created: time.Date(2013, 6, 1, 10, 0, 0, 0, time.UTC)},
+	})
+	e.Author = "Bob"
+
+	var buf bytes.Buffer
+	if err := e.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	var names []string
+	for _, f := range z.File {
+		names = append(names, f.Name)
+		files[f.Name] = readZipFile(t, f)
+	}
+
+	// The mimetype first, uncompressed
+	want := []string{"mimetype", "META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml",
+		"OEBPS/chapter-1.xhtml", "OEBPS/chapter-2.xhtml", "OEBPS/images/2013/06/01/a/a.png"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Fatalf("entries %v, want %v", names, want)
+	}
+	if f := z.File[0]; f.Method != zip.Store || string(files["mimetype"]) != "application/epub+zip" {
+		t.Errorf("mimetype %q, compressed by %d", files["mimetype"], f.Method)
+	}
+	if f := z.File[6]; f.Method != zip.Store || string(files[f.Name]) != "image" {
+		t.Errorf("image %q, compressed by %d", files[f.Name], f.Method)
+	}
+	for _, name := range names[1:6] {
+		if err := wellFormed(files[name]); err != nil {
+			t.Errorf("%s isn't well formed: %v\n%s", name, err, files[name])
+		}
+	}
+
+	// Manifest, and spine, oldest first
+	var opf struct {
+		Lang     string   `xml:"lang,attr"`
+		Title    string   `xml:"metadata>title"`
+		ID       string   `xml:"metadata>identifier"`
+		Creators []string `xml:"metadata>creator"`
+		Modified string   `xml:"metadata>meta"`
+		Items    []struct {
+			ID    string `xml:"id,attr"`
+			Href  string `xml:"href,attr"`
+			Type  string `xml:"media-type,attr"`
+			Props string `xml:"properties,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := xml.Unmarshal(files["OEBPS/content.opf"], &opf); err != nil {
+		t.Fatal(err)
+	}
+	if opf.Lang != "en" || opf.Title != "Fish & Chips" || opf.ID != "http://example.com/articles" ||
+		strings.Join(opf.Creators, ",") != "Ann,Bob" || opf.Modified != "2013-06-02T10:00:00Z" {
+		t.Errorf("metadata %+v", opf)
+	}
+	var items []string
+	for _, item := range opf.Items {
+		items = append(items, item.ID+" "+item.Href+" "+item.Type+" "+item.Props)
+	}
+	wantItems := []string{
+		"nav nav.xhtml application/xhtml+xml nav",
+		"c1 chapter-1.xhtml application/xhtml+xml remote-resources",
+		"c2 chapter-2.xhtml application/xhtml+xml ",
+		"img-1 images/2013/06/01/a/a.png image/png cover-image",
+	}
+	if strings.Join(items, "\n") != strings.Join(wantItems, "\n") {
+		t.Errorf("manifest\n%s\nwant\n%s", strings.Join(items, "\n"), strings.Join(wantItems, "\n"))
+	}
+	if len(opf.Spine) != 2 || opf.Spine[0].IDRef != "c1" || opf.Spine[1].IDRef != "c2" {
+		t.Errorf("spine %+v", opf.Spine)
+	}
+
+	// Navigation, of the articles and their headings
+	nav := string(files["OEBPS/nav.xhtml"])
+	for _, s := range []string{
+		`<li><a href="chapter-1.xhtml">A &lt; B</a>` + "\n<ol>\n" +
+			`<li><a href="chapter-1.xhtml#intro">Intro</a>` + "\n<ol>\n" +
+			`<li><a href="chapter-1.xhtml#more">More &amp; more</a></li>` + "\n</ol>\n</li>\n</ol>\n</li>",
+		`<li><a href="chapter-2.xhtml">B</a></li>`,
+	} {
+		if !strings.Contains(nav, s) {
+			t.Errorf("navigation without %s:\n%s", s, nav)
+		}
+	}
+
+	// Chapters, with the images of the book, or their alternative text
+	a := string(files["OEBPS/chapter-1.xhtml"])
+	for _, s := range []string{
+		`xml:lang="en"`,
+		`<h1>A &lt; B</h1>`,
+		`<p class="byline">Ann</p>`,
+		`<img src="images/2013/06/01/a/a.png" alt="A"/>Gone`,
+		`<p>text<br/><video src="https://example.com/a.mp4" controls=""></video></p>`,
+	} {
+		if !strings.Contains(a, s) {
+			t.Errorf("chapter 1 without %s:\n%s", s, a)
+		}
+	}
+	b := string(files["OEBPS/chapter-2.xhtml"])
+	for _, s := range []string{
+		`xml:lang="fr"`,
+		`<p class="byline">Bob</p>`,
+		`<p>B</p><table><tr><td>1</td><td>2</td></tr></table>`,
+	} {
+		if !strings.Contains(b, s) {
+			t.Errorf("chapter 2 without %s:\n%s", s, b)
+		}
+	}
+	if strings.Contains(b, "svg") {
+		t.Errorf("chapter 2 with SVG:\n%s", b)
+	}
+}
+
+func TestEPUBLang(t *testing.T) {
+	translated := func(lang string) *Article {
+		return &Article{Title: "A", Lang: lang, Translations: []Translation{
+			{Lang: "fr", Title: "Un"}, {Lang: "de", Title: "Ein"}}}
+	}
+	tests := []struct {
+		name     string
+		langs    []string // the article's own languages
+		localize string
+		want     string
+	}{
+		{"default", []string{""}, "", "en"},
+		{"own", []string{"fr", "fr", "en"}, "", "fr"},
+		{"localized", []string{"en", ""}, "de", "de"},
+		{"partly localized", []string{"en", "", "fr"}, "fr", "fr"},
+		{"tie, to the oldest", []string{"de", "fr"}, "", "de"},
+	}
+	for _, test := range tests {
+		var list []*Article
+		for i, lang := range test.langs {
+			a := translated(lang)
+			a.Created = time.Date(2013, 6, i+1, 0, 0, 0, 0, time.UTC)
+			if test.localize != "" {
+				a.Localize(test.localize)
+			}
+			list = append(list, a)
+		}
+		e := NewEPUB("Book", "id", "/media/", "", list)
+		if e.Lang != test.want {
+			t.Errorf("%s: language %q, want %q", test.name, e.Lang, test.want)
+		}
+
+		// Each chapter is in the language it's localized to
+		var buf bytes.Buffer
+		if err := e.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, a := range e.Articles {
+			b := readZipFile(t, z.File[4+i])
+			if want := `xml:lang="` + a.Localized() + `"`; !strings.Contains(string(b), want) {
+				t.Errorf("%s: %s without %s:\n%s", test.name, z.File[4+i].Name, want, b)
+			}
+		}
+	}
+}
+
+func TestHasRemoteMedia(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{`<p>a</p>`, false},
+		{`<video src="/media/a.mp4"></video>`, false},
+		{`<video src="https://example.com/a.mp4"></video>`, true},
+		{`<video src="a.mp4" poster="http://example.com/a.jpg"></video>`, true},
+		{`<audio><source src="//example.com/a.ogg"/></audio>`, true},
+		{`<a href="https://example.com/a.mp4">a</a>`, false},
+	}
+	for _, test := range tests {
+		if got := hasRemoteMedia(test.in); got != test.want {
+			t.Errorf("hasRemoteMedia(%q) = %t, want %t", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Feed is a syndication feed of articles, newest first.
+type Feed struct {
+	Title       string
+	Description string
+	Lang        string
+	SiteURL     string // absolute URL of the articles, their base path included
+	MediaURL    string // absolute URL of the articles' images
+	ImagePath   string
+	Articles    []*Article
+
+	Self   string // absolute URL of the feed itself
+	Author string // of articles without one
+	Full   bool   // full rendered content, rather than summaries
+}
+
+// NewFeed returns the feed of `list`, sorted by creation.
+func NewFeed(title, description, siteURL, mediaURL, imagePath string, list []*Article) *Feed {
+	f := &Feed{Title: title, Description: description, Lang: DefaultLang,
+		SiteURL: siteURL, MediaURL: mediaURL, ImagePath: imagePath, Articles: list}
+	sort.Sort(byCreated(f.Articles))
+	return f
+}
+
+type byCreated []*Article
+
+func (s byCreated) Len() int           { return len(s) }
+func (s byCreated) Less(i, j int) bool { return s[i].Created.After(s[j].Created) }
+func (s byCreated) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// URL is the permalink of an article.
+func (f *Feed) URL(a *Article) string {
+	return f.SiteURL + "/" + a.TitlePath
+}
+
+// Render renders the content of the articles, as they're shown on their own.
+func (f *Feed) Render(p *Pipeline) {
+	for _, a := range f.Articles {
+		if _, err := a.Render(p); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// Updated is when an article of the feed was last modified.
+func (f *Feed) Updated() time.Time {
+	var updated time.Time
+	for _, a := range f.Articles {
+		if a.Modified.After(updated) {
+			updated = a.Modified
+		}
+		if a.Created.After(updated) {
+			updated = a.Created
+		}
+	}
+	return updated
+}
+
+// Enclosure is an article's thumbnail, as a media file of the feed.
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int64
+}
+
+// Enclosure returns the thumbnail of `a`, if it has one.
+func (f *Feed) Enclosure(a *Article) (Enclosure, bool) {
+	if a.Img.Src == "" {
+		return Enclosure{}, false
+	}
+	e := Enclosure{URL: f.MediaURL + a.TitlePath + a.Img.Src}
+	e.Type = mime.TypeByExtension(path.Ext(a.Img.Src))
+	if e.Type == "" {
+		e.Type = "application/octet-stream"
+	}
+	if fi, err := os.Stat(filepath.Join(f.ImagePath, filepath.FromSlash(a.TitlePath+a.Img.Src))); err == nil {
+		e.Length = fi.Size()
+	}
+	return e, true
+}
+
+/*-----------RSS 2.0----------*/
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	PubDate     string        `xml:"pubDate"`
+	GUID        rssGUID       `xml:"guid"`
+	Category    []string      `xml:"category,omitempty"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// RSS encodes the feed as RSS 2.0, where the guid of an item is the
+// titlePath of its article.
+func (f *Feed) RSS() ([]byte, error) {
+	c := rssChannel{Title: f.Title, Link: f.SiteURL + "/", Description: f.Description, Language: f.Lang}
+	if updated := f.Updated(); !updated.IsZero() {
+		c.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+	for _, a := range f.Articles {
+		item := rssItem{
+			Title:       a.Title,
+			Link:        f.URL(a),
+			Description: a.Summary(),
+			PubDate:     a.Created.Format(time.RFC1123Z),
+			GUID:        rssGUID{false, a.TitlePath},
+			Category:    a.Tags,
+		}
+		if e, ok := f.Enclosure(a); ok {
+			item.Enclosure = &rssEnclosure{e.URL, e.Length, e.Type}
+		}
+		c.Items = append(c.Items, item)
+	}
+
+	b, err := xml.MarshalIndent(rss{Version: "2.0", Channel: c}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+/*-----------Atom 1.0---------*/
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang    string      `xml:"xml:lang,attr,omitempty"`
+	Base    string      `xml:"xml:base,attr,omitempty"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Sub     string      `xml:"subtitle,omitempty"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  *atomAuthor `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID        string         `xml:"id"`
+	Title     string         `xml:"title"`
+	Updated   string         `xml:"updated"`
+	Published string         `xml:"published"`
+	Links     []atomLink     `xml:"link"`
+	Author    *atomAuthor    `xml:"author"`
+	Category  []atomCategory `xml:"category"`
+	Summary   *atomText      `xml:"summary"`
+	Content   *atomText      `xml:"content"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ID returns the `tag:` URI identifying an article, which unlike its URL
+// survives moving the site.
+func (f *Feed) ID(a *Article) string {
+	host := f.SiteURL
+	if u, err := url.Parse(f.SiteURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return "tag:" + host + "," + a.Created.Format("2006-01-02") + ":" + a.TitlePath
+}
+
+// Atom encodes the feed as Atom 1.0, with either the summaries, or the
+// rendered content, of the articles.
+func (f *Feed) Atom() ([]byte, error) {
+	updated := f.Updated()
+	feed := atomFeed{
+		Lang:    f.Lang,
+		Base:    f.SiteURL + "/",
+		ID:      f.SiteURL + "/",
+		Title:   f.Title,
+		Sub:     f.Description,
+		Updated: updated.Format(time.RFC3339),
+		Links:   []atomLink{{Rel: "alternate", Href: f.SiteURL + "/", Type: "text/html"}},
+	}
+	if f.Self != "" {
+		feed.ID = f.Self
+		feed.Links = append(feed.Links, atomLink{Rel: "self", Href: f.Self, Type: "application/atom+xml"})
+	}
+	if f.Author != "" {
+		feed.Author = &atomAuthor{f.Author}
+	}
+
+	for _, a := range f.Articles {
+		modified := a.Modified
+		if modified.IsZero() {
+			modified = a.Created
+		}
+		e := atomEntry{
+			ID:        f.ID(a),
+			Title:     a.Title,
+			Updated:   modified.Format(time.RFC3339),
+			Published: a.Created.Format(time.RFC3339),
+			Links:     []atomLink{{Rel: "alternate", Href: f.URL(a), Type: "text/html"}},
+			Summary:   &atomText{"text", a.Summary()},
+		}
+		if a.Author != "" {
+			e.Author = &atomAuthor{a.Author}
+		} else if f.Author == "" {
+			// Atom requires an author, of the feed or every entry
+			e.Author = &atomAuthor{f.Title}
+		}
+		for _, t := range a.Tags {
+			e.Category = append(e.Category, atomCategory{t})
+		}
+		if enc, ok := f.Enclosure(a); ok {
+			e.Links = append(e.Links, atomLink{"enclosure", enc.URL, enc.Type, enc.Length})
+		}
+		if f.Full && a.HTML != "" {
+			e.Content = &atomText{"html", a.HTML}
+		}
+		feed.Entries = append(feed.Entries, e)
+	}
+
+	b, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+/*---------JSON Feed 1.1-------*/
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Language    string         `json:"language,omitempty"`
+	Authors     []jsonAuthor   `json:"authors,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	Title         string       `json:"title"`
+	ContentHTML   string       `json:"content_html"`
+	Summary       string       `json:"summary,omitempty"`
+	Image         string       `json:"image,omitempty"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified,omitempty"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+// absHTML resolves the relative `href`, `src` and `poster` URLs of the HTML
+// `s` against the absolute URL `base`, for readers that have no base of
+// their own to resolve them against.
+func absHTML(s, base string) string {
+	u, err := url.Parse(base)
+	if err != nil || !u.IsAbs() {
+		return s
+	}
+	tokens := tokenize(s)
+	for i, t := range tokens {
+		if t.Type != startToken {
+			continue
+		}
+		for j, a := range t.Attrs {
+			if a.Key != "href" && a.Key != "src" && a.Key != "poster" {
+				continue
+			}
+			if ref, err := url.Parse(a.Val); err == nil && !ref.IsAbs() {
+				tokens[i].Attrs[j].Val = u.ResolveReference(ref).String()
+			}
+		}
+	}
+	return render(tokens)
+}
+
+// JSON encodes the feed as JSON Feed 1.1, which always has the rendered
+// content of the articles, with its links made absolute.
+func (f *Feed) JSON() ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.SiteURL + "/",
+		FeedURL:     f.Self,
+		Description: f.Description,
+		Language:    f.Lang,
+		Items:       []jsonFeedItem{},
+	}
+	if f.Author != "" {
+		feed.Authors = []jsonAuthor{{f.Author}}
+	}
+
+	for _, a := range f.Articles {
+		item := jsonFeedItem{
+			ID:            a.TitlePath,
+			URL:           f.URL(a),
+			Title:         a.Title,
+			ContentHTML:   absHTML(a.HTML, f.URL(a)),
+			Summary:       a.Summary(),
+			DatePublished: a.Created.Format(time.RFC3339),
+			Tags:          a.Tags,
+		}
+		if !a.Modified.IsZero() {
+			item.DateModified = a.Modified.Format(time.RFC3339)
+		}
+		if a.Author != "" {
+			item.Authors = []jsonAuthor{{a.Author}}
+		}
+		if e, ok := f.Enclosure(a); ok {
+			item.Image = e.URL
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return json.MarshalIndent(feed, "", "  ")
+}
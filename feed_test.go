@@ -0,0 +1,252 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+// feedArticles are those of the test feeds, the newest having a 5 byte
+// thumbnail within testdata/media.
+var feedArticles = []*Article{
+	{TitlePath: "2013/06/01/a/", Title: "A < B", Synopsis: "a & b",
+		Created: time.Date(2013, 6, 1, 10, 0, 0, 0, time.UTC), Tags: []string{"x&y", "z"}},
+	{TitlePath: "2013/06/02/b/", Title: "B", Excerpt: "<p>b</p>", Author: "Ann",
+		HTML: "<p>b &amp; c</p>", Img: Img{Src: "b.png"},
+		Created:  time.Date(2013, 6, 2, 10, 0, 0, 0, time.UTC),
+		Modified: time.Date(2013, 6, 3, 10, 0, 0, 0, time.UTC)},
+}
+
+func TestFeedEnclosure(t *testing.T) {
+	f := NewFeed("Fish & <Chips>", "All about \"food\"", "http://example.com/articles",
+		"http://example.com/media/", "testdata/media", feedArticles)
+
+	e, ok := f.Enclosure(f.Articles[0])
+	if !ok || e.URL != "http://example.com/media/2013/06/02/b/b.png" || e.Type != "image/png" || e.Length != 5 {
+		t.Errorf("Enclosure = %+v, %v", e, ok)
+	}
+	if _, ok := f.Enclosure(f.Articles[1]); ok {
+		t.Error("enclosure of an article without a thumbnail")
+	}
+
+	// Unknown types, and missing files, are still enclosed
+	e, ok = f.Enclosure(&Article{TitlePath: "2013/06/01/a/", Img: Img{Src: "a.xyz"}})
+	if !ok || e.Type != "application/octet-stream" || e.Length != 0 {
+		t.Errorf("Enclosure = %+v, %v", e, ok)
+	}
+}
+
+func TestFeedRSS(t *testing.T) {
+	f := NewFeed("Fish & <Chips>", "All about \"food\"", "http://example.com/articles",
+		"http://example.com/media/", "testdata/media", feedArticles)
+
+	b, err := f.RSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	if !strings.HasPrefix(s, xml.Header) {
+		t.Errorf("RSS without an XML header:\n%s", s)
+	}
+	for _, want := range []string{
+		`<rss version="2.0">`,
+		`<title>Fish &amp; &lt;Chips&gt;</title>`,
+		`<description>All about &#34;food&#34;</description>`,
+		`<language>en</language>`,
+		`<lastBuildDate>Mon, 03 Jun 2013 10:00:00 +0000</lastBuildDate>`,
+		`<title>A &lt; B</title>`,
+		`<description>&lt;p&gt;b&lt;/p&gt;</description>`,
+		`<category>x&amp;y</category>`,
+		`<enclosure url="http://example.com/media/2013/06/02/b/b.png" length="5" type="image/png"></enclosure>`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("RSS without %s:\n%s", want, s)
+		}
+	}
+
+	var feed rss
+	if err := xml.Unmarshal(b, &feed); err != nil {
+		t.Fatal(err)
+	}
+	items := feed.Channel.Items
+	if len(items) != 2 {
+		t.Fatalf("%d items, want 2", len(items))
+	}
+	b0, a1 := items[0], items[1]
+	if b0.Link != "http://example.com/articles/2013/06/02/b/" || b0.GUID.Value != "2013/06/02/b/" || b0.GUID.IsPermaLink ||
+		b0.PubDate != "Sun, 02 Jun 2013 10:00:00 +0000" {
+		t.Errorf("newest item %+v", b0)
+	}
+	if a1.Title != "A < B" || a1.Description != "a & b" || a1.Enclosure != nil || strings.Join(a1.Category, ",") != "x&y,z" {
+		t.Errorf("oldest item %+v", a1)
+	}
+
+	// Without articles
+	f.Articles = nil
+	if b, err = f.RSS(); err != nil || strings.Contains(string(b), "lastBuildDate") {
+		t.Errorf("empty RSS %v:\n%s", err, b)
+	}
+}
+
+func TestFeedAtom(t *testing.T) {
+	f := NewFeed("Fish & <Chips>", "All about \"food\"", "http://example.com/articles",
+		"http://example.com/media/", "testdata/media", feedArticles)
+	f.Lang = "fr"
+
+	b, err := f.Atom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	for _, want := range []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="fr" xml:base="http://example.com/articles/">`,
+		`<title>Fish &amp; &lt;Chips&gt;</title>`,
+		`<updated>2013-06-03T10:00:00Z</updated>`,
+		`<link rel="enclosure" href="http://example.com/media/2013/06/02/b/b.png" type="image/png" length="5"></link>`,
+		`<category term="x&amp;y"></category>`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Atom without %s:\n%s", want, s)
+		}
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(b, &feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.ID != "http://example.com/articles/" || feed.Author != nil || len(feed.Entries) != 2 {
+		t.Fatalf("feed %+v", feed)
+	}
+	b0, a1 := feed.Entries[0], feed.Entries[1]
+	if b0.ID != "tag:example.com,2013-06-02:2013/06/02/b/" || b0.Updated != "2013-06-03T10:00:00Z" ||
+		b0.Published != "2013-06-02T10:00:00Z" || b0.Author == nil || b0.Author.Name != "Ann" ||
+		b0.Summary.Value != "<p>b</p>" || b0.Content != nil {
+		t.Errorf("newest entry %+v", b0)
+	}
+	// Without a feed author, every entry has one
+	if a1.Updated != "2013-06-01T10:00:00Z" || a1.Author == nil || a1.Author.Name != "Fish & <Chips>" ||
+		len(a1.Links) != 1 || a1.Title != "A < B" {
+		t.Errorf("oldest entry %+v", a1)
+	}
+
+	// Full content, and a feed author and self link
+	f.Full, f.Author, f.Self = true, "Bob", "http://example.com/articles/feed.atom"
+	if b, err = f.Atom(); err != nil {
+		t.Fatal(err)
+	}
+	feed = atomFeed{}
+	if err := xml.Unmarshal(b, &feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.ID != f.Self || feed.Author == nil || feed.Author.Name != "Bob" || len(feed.Links) != 2 ||
+		feed.Links[1].Rel != "self" || feed.Links[1].Href != f.Self {
+		t.Errorf("feed %+v", feed)
+	}
+	if e := feed.Entries[0]; e.Content == nil || e.Content.Type != "html" || e.Content.Value != "<p>b &amp; c</p>" {
+		t.Errorf("content %+v", e.Content)
+	}
+	if e := feed.Entries[1]; e.Author != nil || e.Content != nil {
+		t.Errorf("entry without its own author, or content, %+v", e)
+	}
+	if !strings.Contains(string(b), `<content type="html">&lt;p&gt;b &amp;amp; c&lt;/p&gt;</content>`) {
+		t.Errorf("content isn't escaped:\n%s", b)
+	}
+}
+
+func TestFeedJSON(t *testing.T) {
+	f := NewFeed("Fish & <Chips>", "All about \"food\"", "http://example.com/articles",
+		"http://example.com/media/", "testdata/media", feedArticles)
+
+	b, err := f.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var feed jsonFeed
+	if err := json.Unmarshal(b, &feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.Version != "https://jsonfeed.org/version/1.1" || feed.Title != "Fish & <Chips>" ||
+		feed.HomePageURL != "http://example.com/articles/" || feed.Language != "en" ||
+		feed.FeedURL != "" || feed.Authors != nil || len(feed.Items) != 2 {
+		t.Fatalf("feed %+v", feed)
+	}
+	b0, a1 := feed.Items[0], feed.Items[1]
+	if b0.ID != "2013/06/02/b/" || b0.URL != "http://example.com/articles/2013/06/02/b/" ||
+		b0.ContentHTML != "<p>b &amp; c</p>" || b0.Image != "http://example.com/media/2013/06/02/b/b.png" ||
+		b0.DatePublished != "2013-06-02T10:00:00Z" || b0.DateModified != "2013-06-03T10:00:00Z" ||
+		len(b0.Authors) != 1 || b0.Authors[0].Name != "Ann" {
+		t.Errorf("newest item %+v", b0)
+	}
+	if a1.Summary != "a & b" || a1.Image != "" || a1.DateModified != "" || a1.Authors != nil ||
+		strings.Join(a1.Tags, ",") != "x&y,z" {
+		t.Errorf("oldest item %+v", a1)
+	}
+
+	// Links of the content are absolute
+	f.Articles = []*Article{{TitlePath: "2013/06/03/c/", Title: "C",
+		HTML: `<a href="/articles/2013/06/02/b/">b</a><img src="/media/2013/06/03/c/c.png">`}}
+	if b, err = f.JSON(); err != nil {
+		t.Fatal(err)
+	}
+	feed = jsonFeed{}
+	if err := json.Unmarshal(b, &feed); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<a href="http://example.com/articles/2013/06/02/b/">b</a><img src="http://example.com/media/2013/06/03/c/c.png">`; feed.Items[0].ContentHTML != want {
+		t.Errorf("content %s, want %s", feed.Items[0].ContentHTML, want)
+	}
+
+	// Optional fields are omitted, but items never are
+	f.Articles = nil
+	if b, err = f.JSON(); err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	if !strings.Contains(s, `"items": []`) || strings.Contains(s, "feed_url") || strings.Contains(s, "authors") {
+		t.Errorf("empty JSON feed:\n%s", s)
+	}
+
+	f.Self, f.Author = "http://example.com/articles/feed.json", "Bob"
+	if b, err = f.JSON(); err != nil {
+		t.Fatal(err)
+	}
+	feed = jsonFeed{}
+	if err := json.Unmarshal(b, &feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.FeedURL != f.Self || len(feed.Authors) != 1 || feed.Authors[0].Name != "Bob" {
+		t.Errorf("feed %+v", feed)
+	}
+}
+
+func TestAbsHTML(t *testing.T) {
+	const base = "http://example.com/articles/2013/06/01/a/"
+	tests := []struct {
+		in, want string
+	}{
+		{`<a href="/articles/2013/06/02/b/">b</a>`, `<a href="http://example.com/articles/2013/06/02/b/">b</a>`},
+		{`<img src="/media/2013/06/01/a/a.png" alt="a">`, `<img src="http://example.com/media/2013/06/01/a/a.png" alt="a">`},
+		{`<video poster="p.png" src="v.mp4"></video>`, `<video poster="` + base + `p.png" src="` + base + `v.mp4"></video>`},
+		{`<a href="#s">s</a>`, `<a href="` + base + `#s">s</a>`},
+		{`<a href="//example.org/x">x</a>`, `<a href="http://example.org/x">x</a>`},
+
+		// Absolute URLs, and text, are left as they are
+		{`<a href="https://example.org/">x</a>`, `<a href="https://example.org/">x</a>`},
+		{`<a href="mailto:a@example.com">a</a>`, `<a href="mailto:a@example.com">a</a>`},
+		{`<p>/articles/ &amp; src="/x"</p>`, `<p>/articles/ &amp; src="/x"</p>`},
+	}
+	for _, test := range tests {
+		if got := absHTML(test.in, base); got != test.want {
+			t.Errorf("absHTML(%s)\n got %s\nwant %s", test.in, got, test.want)
+		}
+	}
+	if got := absHTML(`<a href="/x">x</a>`, "/articles/"); got != `<a href="/x">x</a>` {
+		t.Errorf("resolved against a relative base: %s", got)
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.minty.io/dingo"
+	"code.minty.io/dingo/views"
+	"code.minty.io/wombat"
+	articles "code.minty.io/wombat-articles"
+	"code.minty.io/wombat/template/data"
+)
+
+// ExportManifest is the name of the file, within an exported site, recording
+// the hash of each file exported, so incremental exports leave those that
+// are the same as they were, and those no longer exported are removed.
+const ExportManifest = ".articles-export.json"
+
+type exportManifest struct {
+	Files map[string]string `json:"files"` // hash, by path
+}
+
+// ExportReport lists the files written, the files left as they were, and
+// the files removed, by an export.
+type ExportReport struct {
+	Written []string
+	Skipped []string
+	Removed []string
+}
+
+// pageWriter is the http.ResponseWriter pages are exported through.
+type pageWriter struct {
+	bytes.Buffer
+	header http.Header
+	status int
+}
+
+func (w *pageWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pageWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+// execute renders the view `name` of `data`, as the handlers do.
+var execute = views.Execute
+
+// exportContext returns the context of a request for `p`, by an anonymous
+// visitor, the response being written to `w`.
+func (h Handler) exportContext(siteURL, p string, w *pageWriter) (wombat.Context, error) {
+	r, err := http.NewRequest("GET", siteURL+p, nil)
+	if err != nil {
+		return wombat.Context{}, err
+	}
+	return wombat.Context{Context: dingo.Context{Request: r, Response: w}, User: new(wombat.User)}, nil
+}
+
+// Export writes every published article, their paginated lists, series,
+// feeds, sitemap and media as a static site within `dir`, files being at the
+// path of their URL. Lists are paginated at `page/N/`, and translations of
+// an article are at `lang/` within its path, as the pages link to them. An
+// incremental export only writes the files that differ from those of the
+// last one, whatever changed them, be it an article, another it lists, or a
+// template. Files of the last export that aren't exported again, such as of
+// articles no longer published, are removed.
+func (h Handler) Export(dir string, incremental bool) (*ExportReport, error) {
+	h.exporting = true
+	report := new(ExportReport)
+	siteURL := strings.TrimSuffix(h.SiteURL, "/")
+	if siteURL == "" {
+		return report, errors.New("Missing siteURL, needed for absolute links")
+	}
+	mediaURL := AbsURL(wombat.Context{}, siteURL, h.MediaURL)
+
+	// Files last exported, and their hashes
+	var manifest exportManifest
+	manifestPath := filepath.Join(dir, ExportManifest)
+	if b, err := ioutil.ReadFile(manifestPath); err == nil {
+		json.Unmarshal(b, &manifest)
+	}
+
+	// fileName is the file of `p`, `index.html` for directories
+	fileName := func(p string) string {
+		name := filepath.Join(dir, filepath.FromSlash(p))
+		if strings.HasSuffix(p, "/") {
+			name = filepath.Join(name, "index.html")
+		}
+		return name
+	}
+
+	// write renders `p` into its file, unless, when incremental, it's the
+	// same as was last exported
+	files := make(map[string]string)
+	write := func(p string, render func(ctx wombat.Context) error) error {
+		w := &pageWriter{header: make(http.Header), status: http.StatusOK}
+		ctx, err := h.exportContext(siteURL, p, w)
+		if err != nil {
+			return err
+		}
+		if err = render(ctx); err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+		if w.status != http.StatusOK {
+			return fmt.Errorf("%s: %s", p, http.StatusText(w.status))
+		}
+		name := fileName(p)
+		sum := fmt.Sprintf("%x", sha1.Sum(w.Bytes()))
+		files[p] = sum
+		if incremental && manifest.Files[p] == sum {
+			if _, err := os.Stat(name); err == nil {
+				report.Skipped = append(report.Skipped, p)
+				return nil
+			}
+		}
+		if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		report.Written = append(report.Written, p)
+		return ioutil.WriteFile(name, w.Bytes(), 0644)
+	}
+
+	// Articles
+	tags := make(map[string]string) // by their feed's path
+	q := articles.Query{Limit: 100, Sort: "-" + articles.SortCreated, Published: articles.Published}
+	for ; ; q.Page++ {
+		o, err := h.articles.Find(q)
+		if err != nil {
+			return report, err
+		}
+		list := h.ItoArticles(o)
+		for _, a := range list {
+			for _, t := range a.Tags {
+				// Tags of the same slug share the feed GetTagAtom serves
+				if p := h.TagFeedPath(t); p != "" && (tags[p] == "" || t < tags[p]) {
+					tags[p] = t
+				}
+			}
+
+			// The article, and its translations, as GetArticle would
+			langs := a.Langs()
+			a.Related = h.Related(a)
+			for i, l := range langs {
+				localized := *a
+				if i > 0 {
+					localized.Localize(l)
+				}
+				if _, err := localized.Render(h.Pipeline); err != nil {
+					log.Println(err)
+				}
+				localized.TOC = articles.TOC(localized.HTML)
+				err := write(h.articlePath(a.TitlePath, l, langs[0]), func(ctx wombat.Context) error {
+					return execute(ctx.Context, h.Templates["view"], h.Data(ctx, &localized, a.TitlePath))
+				})
+				if err != nil {
+					return report, err
+				}
+			}
+		}
+		if len(list) < q.Limit {
+			break
+		}
+	}
+
+	// Paginated lists, as GetArticles would list them, up to the first page
+	// that isn't full, as a full page links to the next
+	for page := 0; ; page++ {
+		p := h.BasePath + "/"
+		if page > 0 {
+			p += fmt.Sprintf("page/%d/", page)
+		}
+		n := 0
+		err := write(p, func(ctx wombat.Context) error {
+			if page > 0 {
+				ctx.Request.URL.RawQuery = url.Values{"page": {strconv.Itoa(page)}}.Encode()
+			}
+			o, err := h.articles.Find(ParseQuery(ctx, h.PageCount))
+			if err != nil {
+				return err
+			}
+			n = len(h.ItoArticles(o))
+			return execute(ctx.Context, h.Templates["list"], h.Data(ctx, o, ""))
+		})
+		if err != nil {
+			return report, err
+		}
+		if h.PageCount <= 0 || n < h.PageCount {
+			break
+		}
+	}
+
+	// Series, of their published articles
+	all, err := h.articles.AllSeries()
+	if err != nil {
+		return report, err
+	}
+	for _, series := range all {
+		if !articles.ValidSeriesName(series.Name) {
+			// Not served either, and not a path to write to
+			continue
+		}
+		var list []interface{}
+		for _, tp := range series.TitlePaths {
+			if o, ok := h.Article(tp, false); ok {
+				list = append(list, o)
+			}
+		}
+		err := write(h.BasePath+"/series/"+series.Name+"/", func(ctx wombat.Context) error {
+			return execute(ctx.Context, h.Templates["series"], &SeriesData{data.New(ctx), series, list, h.MediaURL})
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// Feeds
+	for _, p := range []string{"/feed.rss", "/feed.atom", "/feed.json"} {
+		if err := write(h.BasePath+p, func(ctx wombat.Context) error { return h.exportFeed(ctx, p, "") }); err != nil {
+			return report, err
+		}
+	}
+	for p, t := range tags {
+		if err := write(p, func(ctx wombat.Context) error { return h.exportFeed(ctx, "/feed.atom", t) }); err != nil {
+			return report, err
+		}
+	}
+
+	// Sitemap, or index of sitemaps
+	h.Sitemap.Invalidate()
+	for n := 0; ; n++ {
+		b, _, err := h.Sitemap.Page(n, siteURL+h.BasePath, mediaURL)
+		if err != nil {
+			return report, err
+		}
+		if b == nil {
+			break
+		}
+		p := h.BasePath + "/sitemap.xml"
+		if n > 0 {
+			p = h.BasePath + fmt.Sprintf("/sitemap-%d.xml", n)
+		}
+		if err = write(p, func(ctx wombat.Context) error { _, err := ctx.Response.Write(b); return err }); err != nil {
+			return report, err
+		}
+	}
+
+	// Stylesheet of highlighted code
+	if style, ok := articles.HighlightStyles[h.Highlight]; ok {
+		err := write(h.BasePath+"/highlight.css", func(ctx wombat.Context) error {
+			_, err := ctx.Response.Write([]byte(style))
+			return err
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// Media, at the path of its URL
+	mediaPath := h.MediaURL
+	if u, err := url.Parse(h.MediaURL); err == nil {
+		mediaPath = u.Path
+	}
+	if err := copyTree(h.ImagePath, filepath.Join(dir, filepath.FromSlash(mediaPath)), incremental); err != nil {
+		return report, err
+	}
+
+	// Files no longer exported, such as of articles no longer published, or
+	// tags no longer used, and the directories they leave empty
+	for p := range manifest.Files {
+		if _, ok := files[p]; ok {
+			continue
+		}
+		name := fileName(p)
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return report, err
+		}
+		for d := filepath.Dir(name); d != filepath.Clean(dir); d = filepath.Dir(d) {
+			if os.Remove(d) != nil {
+				break
+			}
+		}
+		report.Removed = append(report.Removed, p)
+	}
+
+	sort.Strings(report.Removed)
+
+	// Manifest, for the next export
+	b, err := json.Marshal(exportManifest{files})
+	if err != nil {
+		return report, err
+	}
+	return report, ioutil.WriteFile(manifestPath, b, 0644)
+}
+
+// exportFeed writes the feed `p`, of the articles tagged `tag` when it
+// isn't empty.
+func (h Handler) exportFeed(ctx wombat.Context, p, tag string) error {
+	f, err := h.Feed(ctx, tag)
+	if err != nil {
+		return err
+	}
+	var b []byte
+	switch path.Ext(p) {
+	case ".rss":
+		b, err = f.RSS()
+	case ".atom":
+		b, err = f.Atom()
+	case ".json":
+		if !f.Full {
+			f.Render(h.Pipeline)
+		}
+		b, err = f.JSON()
+	}
+	if err != nil {
+		return err
+	}
+	_, err = ctx.Response.Write(b)
+	return err
+}
+
+// copyTree copies the files within `src` into `dst`, except, when
+// `incremental`, those already there of the same size and modified time.
+func copyTree(src, dst string, incremental bool) error {
+	return filepath.Walk(src, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if fi.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if incremental {
+			if t, err := os.Stat(target); err == nil && t.Size() == fi.Size() && t.ModTime().Equal(fi.ModTime()) {
+				return nil
+			}
+		}
+		return copyFile(name, target, fi)
+	})
+}
+
+func copyFile(src, dst string, fi os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+}
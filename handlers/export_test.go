@@ -0,0 +1,241 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"html"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"code.minty.io/dingo"
+	articles "code.minty.io/wombat-articles"
+	"code.minty.io/wombat/backends"
+)
+
+// listReader is a Reader of a list of articles, newest first, and of series.
+type listReader struct {
+	list   []*articles.Article
+	series []*articles.Series
+}
+
+var errNotFound = backends.NewError(backends.StatusNotFound, "Not found", nil)
+
+func (r *listReader) ByTitlePath(titlePath string, unPublished bool) (interface{}, error) {
+	for _, a := range r.list {
+		if a.TitlePath == titlePath && (a.IsPublished || unPublished) {
+			return a, nil
+		}
+	}
+	return nil, errNotFound
+}
+
+func (r *listReader) Recent(limit, page int, unPublished bool) (interface{}, error) {
+	return r.Find(articles.RecentQuery(limit, page, unPublished))
+}
+
+func (r *listReader) Find(q articles.Query) (interface{}, error) {
+	var list []*articles.Article
+	for _, a := range r.list {
+		if q.Published == articles.Published && !a.IsPublished ||
+			q.Published == articles.UnPublished && a.IsPublished {
+			continue
+		}
+		list = append(list, a)
+	}
+	if q.Limit > 0 {
+		start := q.Page * q.Limit
+		if start > len(list) {
+			start = len(list)
+		}
+		end := start + q.Limit
+		if end > len(list) {
+			end = len(list)
+		}
+		list = list[start:end]
+	}
+	return list, nil
+}
+
+func (r *listReader) Featured(limit int) (interface{}, error) {
+	return []*articles.Article{}, nil
+}
+
+func (r *listReader) Tags() ([]string, error) {
+	return nil, nil
+}
+
+func (r *listReader) Series(name string) (*articles.Series, error) {
+	for _, s := range r.series {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return nil, errNotFound
+}
+
+func (r *listReader) SeriesOf(titlePath string) (*articles.Series, error) {
+	for _, s := range r.series {
+		for _, tp := range s.TitlePaths {
+			if tp == titlePath {
+				return s, nil
+			}
+		}
+	}
+	return nil, errNotFound
+}
+
+func (r *listReader) AllSeries() ([]*articles.Series, error) {
+	return r.series, nil
+}
+
+// exportTemplates link to everything a site's templates would.
+var exportTemplates = map[string]*template.Template{
+	"list": template.Must(template.New("list").Parse(
+		`{{range .Articles}}<a href="/articles/{{.TitlePath}}">{{.Title}}</a>{{end}}` +
+			`{{with .PrevURL}}<a href="{{.}}">Newer</a>{{end}}{{with .NextURL}}<a href="{{.}}">Older</a>{{end}}` +
+			`<a href="/articles/feed.atom">Feed</a>`)),
+	"view": template.Must(template.New("view").Parse(
+		`{{range .Alternates}}<link rel="alternate" hreflang="{{.Lang}}" href="{{.URL}}">{{end}}` +
+			`<a href="/articles/">Articles</a>{{.HTML}}` +
+			`{{with .Series}}<a href="/articles/series/{{.Name}}/">{{.Name}}</a>` +
+			`{{with .Prev}}<a href="/articles/{{.}}">Previous</a>{{end}}` +
+			`{{with .Next}}<a href="/articles/{{.}}">Next</a>{{end}}{{end}}`)),
+	"series": template.Must(template.New("series").Parse(
+		`{{range .Articles}}<a href="/articles/{{.TitlePath}}">{{.Title}}</a>{{end}}`)),
+}
+
+func TestExport(t *testing.T) {
+	defer func(e func(dingo.Context, string, interface{}) error) { execute = e }(execute)
+	execute = func(ctx dingo.Context, name string, data interface{}) error {
+		return exportTemplates[name].Execute(ctx.Response, data)
+	}
+
+	imagePath, err := ioutil.TempDir("", "media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(imagePath)
+	if err := os.MkdirAll(filepath.Join(imagePath, "2013", "06", "03", "c"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(imagePath, "2013", "06", "03", "c", "c.png"), []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Renderings are cached, so nothing is written back
+	r := &listReader{
+		list: []*articles.Article{
+			{TitlePath: "2013/06/04/d/", Title: "D", Rendered: "<p>d</p>",
+				Created: time.Date(2013, 6, 4, 0, 0, 0, 0, time.UTC)},
+			{TitlePath: "2013/06/03/c/", Title: "C", IsPublished: true, Tags: []string{"Go"},
+				Rendered: `<p><img src="/media/2013/06/03/c/c.png"></p>`, Lang: "en",
+				Translations: []articles.Translation{{Lang: "fr", Title: "C fr", Rendered: "<p>c</p>"}},
+				Created:      time.Date(2013, 6, 3, 0, 0, 0, 0, time.UTC)},
+			{TitlePath: "2013/06/02/b/", Title: "B", IsPublished: true, Rendered: `<p><a href="/articles/2013/06/01/a/">a</a></p>`,
+				Created: time.Date(2013, 6, 2, 0, 0, 0, 0, time.UTC)},
+			{TitlePath: "2013/06/01/a/", Title: "A", IsPublished: true, Rendered: "<p>a</p>",
+				Created: time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		series: []*articles.Series{{Name: "abc", TitlePaths: []string{"2013/06/01/a/", "2013/06/02/b/", "2013/06/03/c/"}},
+			{Name: "../../x", TitlePaths: []string{"2013/06/01/a/"}}},
+	}
+	h := Handler{
+		articles:    articles.Articles{Reader: r},
+		ItoArticle:  baseArticle,
+		ItoArticles: articles.ToArticles,
+		Pipeline:    articles.NewPipeline(),
+		Sitemap:     articles.NewSitemap(r),
+		PageCount:   2,
+		FeedCount:   10,
+		FeedTitle:   "Articles",
+		MediaURL:    "/media/",
+		SiteURL:     "http://example.com",
+		BasePath:    "/articles",
+		ImagePath:   imagePath,
+		Templates:   map[string]string{"list": "list", "view": "view", "series": "series"},
+	}
+	if _, err := h.Export(dir, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every page, of each list and translation, that's linked to
+	for _, p := range []string{"articles/page/1/index.html", "articles/2013/06/03/c/fr/index.html", "articles/series/abc/index.html"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(p))); err != nil {
+			t.Errorf("%s wasn't exported: %v", p, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "articles", "2013", "06", "04", "d")); !os.IsNotExist(err) {
+		t.Errorf("unpublished article exported: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "x")); !os.IsNotExist(err) {
+		t.Errorf("series of an invalid name exported: %v", err)
+	}
+
+	// Every internal link is to an exported file
+	href := regexp.MustCompile(`(?:href|src)="([^"]*)"`)
+	links := 0
+	err = filepath.Walk(dir, func(name string, fi os.FileInfo, err error) error {
+		if err != nil || filepath.Ext(name) != ".html" {
+			return err
+		}
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		for _, m := range href.FindAllStringSubmatch(string(b), -1) {
+			u := strings.TrimPrefix(html.UnescapeString(m[1]), h.SiteURL)
+			if !strings.HasPrefix(u, "/") {
+				continue
+			}
+			links++
+			file := filepath.Join(dir, filepath.FromSlash(u))
+			if strings.HasSuffix(u, "/") {
+				file = filepath.Join(file, "index.html")
+			}
+			if _, err := os.Stat(file); err != nil {
+				t.Errorf("%s links to %s, which wasn't exported", name[len(dir):], m[1])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if links < 20 {
+		t.Errorf("only %d internal links", links)
+	}
+
+	// Again, incrementally, writing only what changed, even when the
+	// change, as to the order of a series, didn't modify any article
+	report, err := h.Export(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Written) != 0 || len(report.Removed) != 0 {
+		t.Errorf("unchanged export wrote %v, removed %v", report.Written, report.Removed)
+	}
+	r.series[0].TitlePaths = []string{"2013/06/01/a/", "2013/06/03/c/", "2013/06/02/b/"}
+	os.Remove(filepath.Join(dir, "articles", "feed.atom"))
+	if report, err = h.Export(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	written := strings.Join(report.Written, " ")
+	want := "/articles/2013/06/03/c/ /articles/2013/06/03/c/fr/ /articles/2013/06/02/b/ /articles/2013/06/01/a/ " +
+		"/articles/series/abc/ /articles/feed.atom"
+	if written != want {
+		t.Errorf("wrote %s, want %s", written, want)
+	}
+}
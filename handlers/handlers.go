@@ -5,16 +5,22 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"code.minty.io/config"
 	"code.minty.io/dingo/request"
@@ -33,16 +39,47 @@ var imgTypes = sort.StringSlice{
 	"image/png",
 }
 
+const dateFormat = "2006-01-02"
+
 type ArticleData struct {
 	data.Data
 	Article         interface{}
 	ArticleMediaURL string
+	Related         []articles.Related
+	Series          *articles.SeriesNav
+	Source          string
+	HTML            template.HTML
+	TOC             []*articles.Heading
+	Lang            string
+	Alternates      []Alternate
+	Meta            *articles.SocialMeta
+	JSONLD          template.JS
+	OEmbed          articles.OEmbedLinks
+}
+
+// Alternate is the absolute URL of an article in another language, for
+// `hreflang`, or of `x-default`, its URL that negotiates one.
+type Alternate struct {
+	Lang string
+	URL  string
 }
 
 type ArticlesData struct {
 	data.Data
 	Articles        interface{}
 	ArticleMediaURL string
+	Featured        interface{}
+	JSONLD          template.JS
+	Page            int
+	PrevURL         string
+	NextURL         string
+}
+
+type SeriesData struct {
+	data.Data
+	Series          *articles.Series
+	Articles        []interface{}
+	ArticleMediaURL string
 }
 
 type JSONMessage struct {
@@ -51,13 +88,38 @@ type JSONMessage struct {
 }
 
 type Handler struct {
-	articles   articles.Articles
-	ItoArticle ItoArticle
-	PageCount  int
-	MediaURL   string
-	BasePath   string
-	ImagePath  string
-	Templates  map[string]string
+	articles      articles.Articles
+	ItoArticle    ItoArticle
+	ItoArticles   func(o interface{}) []*articles.Article
+	Recommender   articles.Recommender
+	Pipeline      *articles.Pipeline
+	Policy        *articles.Policy
+	Shortcodes    *articles.Shortcodes
+	Links         *articles.Links
+	MetaSchema    articles.MetaSchema
+	Sitemap       *articles.Sitemap
+	PageCount     int
+	RelatedCount  int
+	FeaturedCount int
+	FeedCount     int
+	EPUBCount     int
+	FeedTitle     string
+	FeedDesc      string
+	FeedAuthor    string
+	SiteName      string
+	TwitterSite   string
+	PostingType   string
+	Publisher     articles.Publisher
+	FeedFull      bool
+	MediaURL      string
+	SiteURL       string
+	BasePath      string
+	ImagePath     string
+	Templates     map[string]string
+	Highlight     string
+
+	// exporting has links be to the files of an exported site
+	exporting bool
 }
 
 type Router interface {
@@ -66,6 +128,18 @@ type Router interface {
 	GetArticle(ctx wombat.Context, titlePath string)
 	PutArticle(ctx wombat.Context, titlePath string)
 	DeleteArticle(ctx wombat.Context, titlePath string)
+	GetSeries(ctx wombat.Context, name string)
+	PutSeries(ctx wombat.Context, name string)
+	GetHighlightStyle(ctx wombat.Context)
+	GetLinkReport(ctx wombat.Context)
+	GetRSS(ctx wombat.Context)
+	GetAtom(ctx wombat.Context)
+	GetTagAtom(ctx wombat.Context, slug string)
+	GetJSONFeed(ctx wombat.Context)
+	GetSitemap(ctx wombat.Context)
+	GetSitemapPage(ctx wombat.Context, n string)
+	GetBackup(ctx wombat.Context)
+	GetOEmbed(ctx wombat.Context)
 }
 
 type ItoArticle func(o interface{}) *articles.Article
@@ -74,11 +148,33 @@ func New() Handler {
 	h := new(Handler)
 	h.articles = articles.New()
 	h.ItoArticle = baseArticle
+	h.ItoArticles = articles.ToArticles
+	h.Recommender = articles.NewRecommender(h.articles.Reader)
+	h.Sitemap = articles.NewSitemap(h.articles.Reader)
 
 	// URLs, Paths
 	h.MediaURL = config.RequiredGroupString("articles", "mediaURL")
 	h.BasePath = config.RequiredGroupString("articles", "basePath")
 	h.ImagePath = config.RequiredGroupString("articles", "imagePath")
+	h.SiteURL, _ = config.GroupString("articles", "siteURL")
+
+	// Language of articles without one
+	if s, ok := config.GroupString("articles", "defaultLang"); ok {
+		articles.DefaultLang = s
+	}
+
+	// Rendering pipeline
+	h.Pipeline = articles.NewPipeline()
+	h.Policy = articles.DefaultPolicy()
+	h.Shortcodes = articles.NewShortcodes(h.MediaURL)
+	h.Links = articles.NewLinks(h.articles.Reader, h.BasePath, h.MediaURL, h.ImagePath)
+	h.Links.ItoArticle, h.Links.ItoArticles = h.ItoArticle, h.ItoArticles
+	h.Pipeline.Filters = append(h.Pipeline.Filters,
+		h.Shortcodes.Filter,
+		h.Policy.Filter,
+		articles.Highlight,
+		articles.Anchors)
+	h.Pipeline.Views = append(h.Pipeline.Views, h.Links.Filter)
 
 	// Templates
 	h.Templates = map[string]string{
@@ -86,6 +182,46 @@ func New() Handler {
 		"view":   "/articles/article.html",
 		"create": "/articles/create.html",
 		"edit":   "/articles/edit.html",
+		"series": "/articles/series.html",
+	}
+
+	// Sanitizer allowlist additions, comma separated
+	if s, ok := config.GroupString("articles", "sanitizeTags"); ok {
+		h.Policy.AllowTags(ParseList(s)...)
+	}
+	if s, ok := config.GroupString("articles", "sanitizeAttrs"); ok {
+		// `tag.attr`, or `*.attr` for any tag
+		for _, a := range ParseList(s) {
+			if i := strings.Index(a, "."); i > 0 {
+				h.Policy.AllowAttrs(a[:i], a[i+1:])
+			}
+		}
+	}
+	if s, ok := config.GroupString("articles", "sanitizeSchemes"); ok {
+		h.Policy.AllowSchemes(ParseList(s)...)
+	}
+	if s, ok := config.GroupString("articles", "embedHosts"); ok {
+		h.Policy.AllowEmbeds(ParseList(s)...)
+	}
+
+	// Schema of the articles' metadata, `name:type` comma separated
+	if s, ok := config.GroupString("articles", "metaSchema"); ok {
+		h.MetaSchema = make(articles.MetaSchema)
+		for _, f := range ParseList(s) {
+			if i := strings.Index(f, ":"); i > 0 {
+				if t := articles.MetaType(f[i+1:]); articles.ValidMetaType(t) {
+					h.MetaSchema[f[:i]] = t
+				} else {
+					log.Printf("Invalid type of meta field %q", f[:i])
+				}
+			}
+		}
+	}
+
+	// Stylesheet of highlighted code
+	h.Highlight = "light"
+	if s, ok := config.GroupString("articles", "highlightStyle"); ok {
+		h.Highlight = s
 	}
 
 	// Page count
@@ -94,6 +230,71 @@ func New() Handler {
 		h.PageCount = c
 	}
 
+	// Featured articles
+	h.FeaturedCount = 3
+	if c, ok := config.GroupInt("articles", "featuredCount"); ok {
+		h.FeaturedCount = c
+	}
+
+	// Feeds
+	h.FeedCount = h.PageCount
+	if c, ok := config.GroupInt("articles", "feedCount"); ok {
+		h.FeedCount = c
+	}
+	h.FeedTitle = "Articles"
+	if s, ok := config.GroupString("articles", "feedTitle"); ok {
+		h.FeedTitle = s
+	}
+	h.FeedDesc, _ = config.GroupString("articles", "feedDescription")
+	h.FeedAuthor, _ = config.GroupString("articles", "feedAuthor")
+	// `summary`, or `full` for the rendered content
+	if s, ok := config.GroupString("articles", "feedContent"); ok {
+		h.FeedFull = s == "full"
+	}
+
+	// Books, of a tag or date range
+	h.EPUBCount = 100
+	if c, ok := config.GroupInt("articles", "epubCount"); ok {
+		h.EPUBCount = c
+	}
+
+	// Social metadata
+	h.SiteName, _ = config.GroupString("articles", "siteName")
+	h.TwitterSite, _ = config.GroupString("articles", "twitterSite")
+
+	// Structured data, as a BlogPosting or NewsArticle
+	h.PostingType = articles.BlogPosting
+	if s, ok := config.GroupString("articles", "postingType"); ok {
+		h.PostingType = s
+	}
+	h.Publisher.Name = h.SiteName
+	if s, ok := config.GroupString("articles", "publisherName"); ok {
+		h.Publisher.Name = s
+	}
+	h.Publisher.URL = h.SiteURL
+	h.Publisher.Logo, _ = config.GroupString("articles", "publisherLogo")
+
+	// Related articles
+	h.RelatedCount = 5
+	if c, ok := config.GroupInt("articles", "relatedCount"); ok {
+		h.RelatedCount = c
+	}
+	if c, ok := config.GroupInt("articles", "relatedCandidates"); ok {
+		if r, ok := h.Recommender.(*articles.TermRecommender); ok {
+			r.Candidates = c
+		}
+	}
+	// cached, rather than scored on every view
+	h.Recommender = articles.NewRelatedCache(h.Recommender)
+
+	// Caches are invalidated by changes made through the handlers, and
+	// otherwise expire, in seconds, for those made by the import and backup
+	// commands
+	if c, ok := config.GroupInt("articles", "cacheMaxAge"); ok {
+		h.Sitemap.MaxAge = time.Duration(c) * time.Second
+		h.Recommender.(*articles.RelatedCache).MaxAge = time.Duration(c) * time.Second
+	}
+
 	return *h
 }
 
@@ -144,6 +345,40 @@ func AddRoutes(s wombat.Server, r Router, basePath string) {
 		//Post(r.RequireTitleAdmin(h.PostArticle)).
 		Put(RequireTitleAdmin(r.PutArticle)).
 		Delete(RequireTitleAdmin(r.DeleteArticle))
+
+	s.ReRouter(fmt.Sprintf("^%s/highlight.css$", basePath)).
+		Get(r.GetHighlightStyle)
+
+	s.ReRouter(fmt.Sprintf("^%s/feed.rss$", basePath)).
+		Get(r.GetRSS)
+
+	s.ReRouter(fmt.Sprintf("^%s/feed.atom$", basePath)).
+		Get(r.GetAtom)
+
+	s.RRouter(fmt.Sprintf(`^%s/tags/([\pL\pN-]+)/feed.atom$`, basePath)).
+		Get(r.GetTagAtom)
+
+	s.ReRouter(fmt.Sprintf("^%s/feed.json$", basePath)).
+		Get(r.GetJSONFeed)
+
+	s.ReRouter(fmt.Sprintf("^%s/sitemap.xml$", basePath)).
+		Get(r.GetSitemap)
+
+	s.RRouter(fmt.Sprintf("^%s/sitemap-(\\d+).xml$", basePath)).
+		Get(r.GetSitemapPage)
+
+	s.ReRouter(fmt.Sprintf("^%s/links/$", basePath)).
+		Get(RequireAdmin(r.GetLinkReport))
+
+	s.ReRouter(fmt.Sprintf("^%s/oembed$", basePath)).
+		Get(r.GetOEmbed)
+
+	s.ReRouter(fmt.Sprintf("^%s/backup.tar.gz$", basePath)).
+		Get(RequireAdmin(r.GetBackup))
+
+	s.RRouter(fmt.Sprintf("^%s/series/([a-zA-Z0-9-]+)/$", basePath)).
+		Get(r.GetSeries).
+		Put(RequireTitleAdmin(r.PutSeries))
 }
 
 func GetErrorStr(r *http.Request, msg string) string {
@@ -157,6 +392,155 @@ func GetError(r *http.Request, err error) string {
 	return GetErrorStr(r, err.Error())
 }
 
+// ParseList splits a comma separated list, dropping empty items.
+func ParseList(s string) []string {
+	list := []string{}
+	for _, i := range strings.Split(s, ",") {
+		if i = strings.TrimSpace(i); i != "" {
+			list = append(list, i)
+		}
+	}
+	return list
+}
+
+// ParseQuery builds an article listing from the request's `page`, `sort`,
+// `published`, `from`, `to`, `tag`, `author`, `thumb`, `featured` and `lang`
+// values.
+// Without a `sort` the pinned articles are listed first.
+func ParseQuery(ctx wombat.Context, limit int) articles.Query {
+	page, err := strconv.Atoi(ctx.FormValue("page"))
+	if err != nil || page < 0 {
+		page = 0
+	}
+
+	q := articles.Query{Limit: limit, Page: page, Sort: "-" + articles.SortCreated}
+	if sort := ctx.FormValue("sort"); articles.ValidSort(sort) {
+		q.Sort = sort
+	} else {
+		q.PinnedFirst = true
+	}
+
+	// Only admins get to see unpublished articles
+	if ctx.User.IsAdmin() {
+		switch ctx.FormValue("published") {
+		default:
+			q.Published = articles.AnyPublishState
+		case "true":
+			q.Published = articles.Published
+		case "false":
+			q.Published = articles.UnPublished
+		}
+	}
+
+	// Created date range, both days inclusive
+	if t, err := time.Parse(dateFormat, ctx.FormValue("from")); err == nil {
+		q.From = t
+	}
+	if t, err := time.Parse(dateFormat, ctx.FormValue("to")); err == nil {
+		q.To = t.AddDate(0, 0, 1)
+	}
+
+	q.Tag = ctx.FormValue("tag")
+	q.Author = ctx.FormValue("author")
+	q.HasThumb, _ = strconv.ParseBool(ctx.FormValue("thumb"))
+	q.Featured, _ = strconv.ParseBool(ctx.FormValue("featured"))
+	q.Lang = ctx.FormValue("lang")
+	return q
+}
+
+// NegotiateLang picks the language of `langs` best matching the request's
+// `lang` value, or its Accept-Language header, defaulting to the first, or
+// to none without any.
+func NegotiateLang(ctx wombat.Context, langs []string) string {
+	if len(langs) == 0 {
+		return ""
+	}
+	if lang := ctx.FormValue("lang"); lang != "" {
+		if l, ok := matchLang(lang, langs); ok {
+			return l
+		}
+	}
+
+	best, bestQ := langs[0], 0.0
+	for _, part := range strings.Split(ctx.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(part, ";")
+		tag, q := strings.TrimSpace(fields[0]), 1.0
+		for _, f := range fields[1:] {
+			if f = strings.TrimSpace(f); strings.HasPrefix(f, "q=") {
+				q, _ = strconv.ParseFloat(f[2:], 64)
+			}
+		}
+		if l, ok := matchLang(tag, langs); ok && q > bestQ {
+			best, bestQ = l, q
+		}
+	}
+	return best
+}
+
+// matchLang finds the language tag `tag` amongst `langs`, exactly or by its
+// primary subtag, so `es-MX` matches `es`.
+func matchLang(tag string, langs []string) (string, bool) {
+	primary := func(s string) string {
+		if i := strings.IndexAny(s, "-_"); i >= 0 {
+			return s[:i]
+		}
+		return s
+	}
+	for _, l := range langs {
+		if strings.EqualFold(l, tag) {
+			return l, true
+		}
+	}
+	for _, l := range langs {
+		if strings.EqualFold(primary(l), primary(tag)) {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// AbsURL resolves the path, or URL, `u` against the configured `siteURL`,
+// or the host of the request when there's none.
+func AbsURL(ctx wombat.Context, siteURL, u string) string {
+	if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+		return u
+	}
+	if siteURL == "" {
+		scheme := "http"
+		if ctx.Request.TLS != nil {
+			scheme = "https"
+		}
+		siteURL = scheme + "://" + ctx.Request.Host
+	}
+	return strings.TrimSuffix(siteURL, "/") + u
+}
+
+// WriteConditional writes `body`, unless the request's If-None-Match, or
+// If-Modified-Since, header shows the client already has it.
+func WriteConditional(ctx wombat.Context, contentType string, modified time.Time, body []byte) {
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(body))
+	header := ctx.Response.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("ETag", etag)
+	if !modified.IsZero() {
+		header.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if match := ctx.Header.Get("If-None-Match"); match != "" {
+		for _, m := range ParseList(match) {
+			if m == etag || m == "*" || m == "W/"+etag {
+				ctx.Response.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	} else if t, err := http.ParseTime(ctx.Header.Get("If-Modified-Since")); err == nil &&
+		!modified.IsZero() && !modified.Truncate(time.Second).After(t) {
+		ctx.Response.WriteHeader(http.StatusNotModified)
+		return
+	}
+	ctx.Response.Write(body)
+}
+
 func IsImageRequest(ctx wombat.Context) bool {
 	c := ctx.Header.Get("Content-Type")
 	i := imgTypes.Search(c)
@@ -198,7 +582,7 @@ func RemoveImage(a *articles.Article, src, imagePath string) (err error) {
 
 /*----------------------------------Handlers----------------------------------*/
 
-func JSONHandler(ctx wombat.Context, a *articles.Article, imagePath string, data []byte) {
+func JSONHandler(ctx wombat.Context, a *articles.Article, imagePath string, policy *articles.Policy, sc *articles.Shortcodes, schema articles.MetaSchema, data []byte) {
 	// Get the JSONMessage from the request
 	var msg JSONMessage
 	err := json.Unmarshal(data, &msg)
@@ -215,12 +599,74 @@ func JSONHandler(ctx wombat.Context, a *articles.Article, imagePath string, data
 	case "setSynopsis":
 		err = a.SetSynopsis(msg.Data)
 	case "setContent":
-		err = a.SetContent(msg.Data)
+		// HTML content is sanitized, reporting whatever was stripped, along
+		// with any shortcodes that can't be expanded
+		content, stripped := msg.Data, []articles.Stripped{}
+		if a.ContentFormat == "" || a.ContentFormat == articles.FormatHTML {
+			if content, stripped = policy.Sanitize(content); stripped == nil {
+				stripped = []articles.Stripped{}
+			}
+		}
+		warnings := sc.Check(a, content)
+		if warnings == nil {
+			warnings = []string{}
+		}
+		if err = a.SetContent(content); err == nil {
+			j, _ := json.Marshal(map[string]interface{}{"stripped": stripped, "warnings": warnings})
+			ctx.Response.Write(j)
+		}
+	case "setContentFormat":
+		if f := articles.ContentFormat(msg.Data); !articles.ValidFormat(f) {
+			err = errors.New("Invalid content format")
+		} else {
+			err = a.SetContentFormat(f)
+		}
 	case "setActive":
 		// Toggle
 		err = a.Publish(!a.IsPublished)
+	case "setPinned":
+		// Toggle
+		err = a.Pin(!a.Pinned)
+	case "setFeatured":
+		// Toggle
+		err = a.Feature(!a.Featured)
 	case "deleteImage":
 		err = RemoveImage(a, msg.Data, imagePath)
+	case "setTags":
+		err = a.SetTags(ParseList(msg.Data))
+	case "setAuthor":
+		err = a.SetAuthor(msg.Data)
+	case "addToSeries":
+		if !articles.ValidSeriesName(msg.Data) {
+			err = errors.New("Invalid series name")
+		} else {
+			err = a.AddToSeries(msg.Data)
+		}
+	case "removeFromSeries":
+		err = a.RemoveFromSeries(msg.Data)
+	case "setMeta":
+		// Data is a JSON encoded object of the fields to change, null
+		// removing a field
+		var changes articles.Meta
+		if err = json.Unmarshal([]byte(msg.Data), &changes); err == nil {
+			if err = schema.Validate(changes); err == nil {
+				err = a.SetMeta(changes)
+			}
+		}
+	case "setLang":
+		err = a.SetLang(msg.Data)
+	case "setTranslation":
+		// Data is a JSON encoded Translation
+		var t articles.Translation
+		if err = json.Unmarshal([]byte(msg.Data), &t); err == nil {
+			if t.Lang == "" || strings.EqualFold(t.Lang, a.Language()) {
+				err = errors.New("Invalid translation language")
+			} else {
+				err = a.SetTranslation(t)
+			}
+		}
+	case "removeTranslation":
+		err = a.RemoveTranslation(msg.Data)
 	}
 
 	// Report if the action resulted in an error
@@ -337,9 +783,122 @@ func (h Handler) Article(titlePath string, unPublished bool) (a interface{}, ok
 
 func (h Handler) Data(ctx wombat.Context, article interface{}, titlePath string) interface{} {
 	if titlePath == "" {
-		return &ArticlesData{data.New(ctx), article, h.MediaURL}
+		d := &ArticlesData{Data: data.New(ctx), Articles: article, ArticleMediaURL: h.MediaURL}
+		if d.Page, _ = strconv.Atoi(ctx.FormValue("page")); d.Page > 0 {
+			d.PrevURL = h.pageURL(ctx, d.Page-1)
+		} else {
+			d.Page = 0
+		}
+		if h.FeaturedCount > 0 {
+			d.Featured, _ = h.articles.Featured(h.FeaturedCount)
+		}
+		if list := h.ItoArticles(article); list != nil {
+			d.JSONLD = articles.JSONLD(
+				articles.ItemList(list, AbsURL(ctx, h.SiteURL, h.BasePath)),
+				articles.BreadcrumbList(h.crumbs(ctx)...))
+			if h.PageCount > 0 && len(list) >= h.PageCount {
+				d.NextURL = h.pageURL(ctx, d.Page+1)
+			}
+		}
+		return d
+	}
+	d := &ArticleData{Data: data.New(ctx), Article: article, ArticleMediaURL: h.MediaURL + titlePath}
+	if a := h.ItoArticle(article); a != nil {
+		d.Related = a.Related
+		d.Source = a.Content
+		d.HTML = template.HTML(a.HTML)
+		d.TOC = a.TOC
+		d.Lang = a.Localized()
+		own := a.Language()
+		if langs := a.Langs(); len(langs) > 1 {
+			// The article's own path negotiates its language, so is the
+			// default, and each language is at an explicit `?lang=`, but
+			// for the exported own language, which doesn't negotiate
+			if !h.exporting {
+				own = ""
+			}
+			for _, l := range langs {
+				d.Alternates = append(d.Alternates, Alternate{l, AbsURL(ctx, h.SiteURL, h.articlePath(titlePath, l, own))})
+			}
+			d.Alternates = append(d.Alternates, Alternate{"x-default", AbsURL(ctx, h.SiteURL, h.BasePath+"/"+titlePath)})
+		}
+
+		// Open Graph, and Twitter Card, metadata
+		url := AbsURL(ctx, h.SiteURL, h.articlePath(titlePath, d.Lang, own))
+		d.Meta = articles.NewSocialMeta(a, url, AbsURL(ctx, h.SiteURL, h.MediaURL))
+		d.Meta.SiteName, d.Meta.TwitterSite = h.SiteName, h.TwitterSite
+		d.OEmbed = articles.OEmbedDiscovery(AbsURL(ctx, h.SiteURL, h.BasePath+"/oembed"), url, a.Title)
+
+		// schema.org structured data
+		d.JSONLD = articles.JSONLD(
+			articles.Posting(h.PostingType, a, url, AbsURL(ctx, h.SiteURL, h.MediaURL), h.Publisher),
+			articles.BreadcrumbList(append(h.crumbs(ctx), articles.Crumb{Name: a.Title, URL: url})...))
+	}
+	if series, err := h.articles.SeriesOf(titlePath); err == nil {
+		if n, ok := series.Nav(titlePath); ok {
+			d.Series = &n
+		}
+	}
+	return d
+}
+
+// pageURL is the URL of page `n` of the request's list of articles. Pages
+// of an exported list are at `page/N/`.
+func (h Handler) pageURL(ctx wombat.Context, n int) string {
+	u := h.BasePath + "/"
+	if h.exporting {
+		if n > 0 {
+			u += fmt.Sprintf("page/%d/", n)
+		}
+		return u
+	}
+	v := ctx.Request.URL.Query()
+	v.Del("page")
+	if n > 0 {
+		v.Set("page", strconv.Itoa(n))
+	}
+	if len(v) > 0 {
+		u += "?" + v.Encode()
+	}
+	return u
+}
+
+// articlePath is the path of the article at `titlePath` in `lang`, its own
+// language being `own`. Exported translations are at `lang/` within the
+// article's path.
+func (h Handler) articlePath(titlePath, lang, own string) string {
+	p := h.BasePath + "/" + titlePath
+	switch {
+	case lang == own:
+	case h.exporting:
+		p += lang + "/"
+	default:
+		p += "?lang=" + lang
+	}
+	return p
+}
+
+// crumbs is the trail of breadcrumbs down to the list of articles.
+func (h Handler) crumbs(ctx wombat.Context) []articles.Crumb {
+	name := h.SiteName
+	if name == "" {
+		name = "Home"
 	}
-	return &ArticleData{data.New(ctx), article, h.MediaURL + titlePath}
+	return []articles.Crumb{
+		{Name: name, URL: AbsURL(ctx, h.SiteURL, "/")},
+		{Name: h.FeedTitle, URL: AbsURL(ctx, h.SiteURL, h.BasePath+"/")},
+	}
+}
+
+func (h Handler) Related(a *articles.Article) []articles.Related {
+	if h.Recommender == nil || h.RelatedCount <= 0 {
+		return nil
+	}
+	related, err := h.Recommender.Related(a, h.RelatedCount, false)
+	if err != nil {
+		log.Println(err)
+	}
+	return related
 }
 
 /*----------Articles----------*/
@@ -348,13 +907,18 @@ func (h Handler) GetArticles(ctx wombat.Context) {
 	var o interface{}
 
 	switch view := ctx.FormValue("view"); {
+	case ctx.FormValue("format") == "epub":
+		h.listEPUB(ctx)
+		return
 	default:
 		tmpl = "list"
-		page, err := strconv.Atoi(ctx.FormValue("page"))
-		if err != nil {
-			page = 0
+		q := ParseQuery(ctx, h.PageCount)
+		o, _ = h.articles.Find(q)
+		if q.Lang != "" {
+			for _, a := range h.ItoArticles(o) {
+				a.Localize(q.Lang)
+			}
 		}
-		o, _ = h.articles.Recent(h.PageCount, page, ctx.User.IsAdmin())
 	case view == "create" && ctx.User.IsAdmin():
 		tmpl = "create"
 	}
@@ -407,6 +971,32 @@ func (h Handler) GetArticle(ctx wombat.Context, titlePath string) {
 		tmpl = "edit"
 	}
 
+	if a := h.ItoArticle(o); a != nil {
+		// Language, of the article's own and its translations, editing
+		// always being of the article's own
+		if tmpl == "view" {
+			ctx.Response.Header().Add("Vary", "Accept-Language")
+			if lang := NegotiateLang(ctx, a.Langs()); lang != a.Language() {
+				a.Localize(lang)
+			}
+		}
+
+		// Rendered content, cached with the article
+		if _, err := a.Render(h.Pipeline); err != nil {
+			log.Println(err)
+		}
+		a.TOC = articles.TOC(a.HTML)
+
+		if tmpl == "view" && ctx.FormValue("format") == "epub" {
+			u := AbsURL(ctx, h.SiteURL, h.BasePath+"/"+a.TitlePath)
+			h.WriteEPUB(ctx, a.Title, u, []*articles.Article{a})
+			return
+		}
+
+		// Related articles
+		a.Related = h.Related(a)
+	}
+
 	// Handle HTTP/JSON response
 	articleResponse(ctx, &h, o, tmpl, titlePath)
 }
@@ -420,6 +1010,7 @@ func (h Handler) PutArticle(ctx wombat.Context, titlePath string) {
 		return
 	}
 	a := h.ItoArticle(o)
+	h.watch(a)
 
 	// JSON message
 	if request.IsApplicationJson(ctx.Request) {
@@ -428,7 +1019,7 @@ func (h Handler) PutArticle(ctx wombat.Context, titlePath string) {
 		if data, err := ioutil.ReadAll(ctx.Body); err != nil {
 			ctx.HttpError(http.StatusBadRequest, GetError(ctx.Request, err))
 		} else {
-			JSONHandler(ctx, a, h.ImagePath, data)
+			JSONHandler(ctx, a, h.ImagePath, h.Policy, h.Shortcodes, h.MetaSchema, data)
 		}
 	} else if IsImageRequest(ctx) {
 		ImagesHandler(ctx, a, h.ImagePath)
@@ -448,7 +1039,381 @@ func (h Handler) DeleteArticle(ctx wombat.Context, titlePath string) {
 	}
 
 	a := h.ItoArticle(o)
+	h.watch(a)
 	if err := a.Delete(); err != nil {
 		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
 	}
 }
+
+/*-----------Series-----------*/
+func (h Handler) GetSeries(ctx wombat.Context, name string) {
+	series, err := h.articles.Series(name)
+	if err != nil {
+		ctx.HttpError(http.StatusNotFound)
+		return
+	}
+
+	// The articles of the series, in order
+	isAdmin := ctx.User.IsAdmin()
+	list := make([]interface{}, 0, len(series.TitlePaths))
+	for _, tp := range series.TitlePaths {
+		if o, ok := h.Article(tp, isAdmin); ok {
+			list = append(list, o)
+		}
+	}
+
+	if request.IsApplicationJson(ctx.Request) {
+		// JSON
+		ctx.Response.Header().Set("Content-Type", "application/json")
+		jd, _ := json.Marshal(list)
+		ctx.Response.Write(jd)
+	} else {
+		// HTTP
+		data := &SeriesData{data.New(ctx), series, list, h.MediaURL}
+		views.Execute(ctx.Context, h.Templates["series"], data)
+	}
+}
+
+func (h Handler) PutSeries(ctx wombat.Context, name string) {
+	ctx.Response.Header().Set("Content-Type", "application/json")
+
+	series, err := h.articles.Series(name)
+	if err != nil {
+		ctx.HttpError(http.StatusNotFound)
+		return
+	}
+
+	// The new order of the series' titlePaths
+	var order struct {
+		TitlePaths []string `json:"titlePaths"`
+	}
+	defer ctx.Body.Close()
+	if b, err := ioutil.ReadAll(ctx.Body); err != nil {
+		ctx.HttpError(http.StatusBadRequest, GetError(ctx.Request, err))
+	} else if err = json.Unmarshal(b, &order); err != nil {
+		ctx.HttpError(http.StatusBadRequest, GetError(ctx.Request, err))
+	} else if err = series.Reorder(order.TitlePaths); err == articles.ErrSeriesOrder {
+		ctx.HttpError(http.StatusBadRequest, GetError(ctx.Request, err))
+	} else if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+	}
+}
+
+/*---------Stylesheets--------*/
+func (h Handler) GetHighlightStyle(ctx wombat.Context) {
+	// The configured style, unless another is selected
+	style, ok := articles.HighlightStyles[ctx.FormValue("style")]
+	if !ok {
+		style, ok = articles.HighlightStyles[h.Highlight]
+	}
+	if !ok {
+		ctx.HttpError(http.StatusNotFound)
+		return
+	}
+
+	ctx.Response.Header().Set("Content-Type", "text/css; charset=utf-8")
+	ctx.Response.Write([]byte(style))
+}
+
+/*------------Feeds-----------*/
+
+// Feed returns the feed of the recent published articles, those tagged
+// `tag` when it isn't empty.
+func (h Handler) Feed(ctx wombat.Context, tag string) (*articles.Feed, error) {
+	o, err := h.articles.Find(articles.FeedQuery(h.FeedCount, tag))
+	if err != nil {
+		return nil, err
+	}
+
+	title := h.FeedTitle
+	if tag != "" {
+		title += " - " + tag
+	}
+	f := articles.NewFeed(title, h.FeedDesc,
+		AbsURL(ctx, h.SiteURL, h.BasePath), AbsURL(ctx, h.SiteURL, h.MediaURL),
+		h.ImagePath, h.ItoArticles(o))
+	f.Self = AbsURL(ctx, h.SiteURL, ctx.Request.URL.Path)
+	f.Author = h.FeedAuthor
+
+	// Full content, rendered like the article's page
+	if f.Full = h.FeedFull; f.Full {
+		f.Render(h.Pipeline)
+	}
+	return f, nil
+}
+
+// WriteEPUB writes the book of `list`, identified by the URL `id`, as a
+// download.
+func (h Handler) WriteEPUB(ctx wombat.Context, title, id string, list []*articles.Article) {
+	e := articles.NewEPUB(title, id, h.MediaURL, h.ImagePath, list)
+	e.Author = h.FeedAuthor
+	e.Render(h.Pipeline)
+
+	var b bytes.Buffer
+	if err := e.Write(&b); err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	name := articles.Slug(title)
+	if name == "" {
+		name = "articles"
+	}
+	ctx.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.epub"`, name))
+	WriteConditional(ctx, "application/epub+zip", e.Modified(), b.Bytes())
+}
+
+// listEPUB writes the book of the articles listed by the request, such as
+// those of a tag, or created within a date range.
+func (h Handler) listEPUB(ctx wombat.Context) {
+	q := ParseQuery(ctx, h.EPUBCount)
+	o, err := h.articles.Find(q)
+	if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	list := h.ItoArticles(o)
+	if len(list) == 0 {
+		ctx.HttpError(http.StatusNotFound)
+		return
+	}
+	if q.Lang != "" {
+		for _, a := range list {
+			a.Localize(q.Lang)
+		}
+	}
+
+	title := h.FeedTitle
+	if q.Tag != "" {
+		title += " - " + q.Tag
+	}
+	switch from, to := ctx.FormValue("from"), ctx.FormValue("to"); {
+	case from != "" && to != "":
+		title += fmt.Sprintf(" (%s to %s)", from, to)
+	case from != "":
+		title += fmt.Sprintf(" (from %s)", from)
+	case to != "":
+		title += fmt.Sprintf(" (to %s)", to)
+	}
+	h.WriteEPUB(ctx, title, AbsURL(ctx, h.SiteURL, ctx.Request.URL.RequestURI()), list)
+}
+
+func (h Handler) GetRSS(ctx wombat.Context) {
+	f, err := h.Feed(ctx, "")
+	if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	b, err := f.RSS()
+	if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	WriteConditional(ctx, "application/rss+xml; charset=utf-8", f.Updated(), b)
+}
+
+func (h Handler) GetAtom(ctx wombat.Context) {
+	h.atom(ctx, "")
+}
+
+// GetTagAtom writes the Atom feed of the tag whose slug is `slug`, as at
+// TagFeedPath.
+func (h Handler) GetTagAtom(ctx wombat.Context, slug string) {
+	tags, err := h.articles.Tags()
+	if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	for _, t := range tags {
+		if articles.Slug(t) == slug {
+			h.atom(ctx, t)
+			return
+		}
+	}
+	ctx.HttpError(http.StatusNotFound)
+}
+
+// TagFeedPath is the path of the Atom feed of `tag`, by its slug, or empty
+// for tags without one.
+func (h Handler) TagFeedPath(tag string) string {
+	slug := articles.Slug(tag)
+	if slug == "" {
+		return ""
+	}
+	return h.BasePath + "/tags/" + slug + "/feed.atom"
+}
+
+func (h Handler) atom(ctx wombat.Context, tag string) {
+	f, err := h.Feed(ctx, tag)
+	if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	b, err := f.Atom()
+	if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	WriteConditional(ctx, "application/atom+xml; charset=utf-8", f.Updated(), b)
+}
+
+func (h Handler) GetJSONFeed(ctx wombat.Context) {
+	f, err := h.Feed(ctx, "")
+	if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	if !f.Full {
+		// Feed renders full feeds already
+		f.Render(h.Pipeline)
+	}
+	b, err := f.JSON()
+	if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	WriteConditional(ctx, "application/feed+json; charset=utf-8", f.Updated(), b)
+}
+
+/*-----------Sitemap----------*/
+func (h Handler) GetSitemap(ctx wombat.Context) {
+	h.sitemap(ctx, 0)
+}
+
+func (h Handler) GetSitemapPage(ctx wombat.Context, n string) {
+	if i, err := strconv.Atoi(n); err != nil || i < 1 {
+		ctx.HttpError(http.StatusNotFound)
+	} else {
+		h.sitemap(ctx, i)
+	}
+}
+
+func (h Handler) sitemap(ctx wombat.Context, n int) {
+	b, generated, err := h.Sitemap.Page(n,
+		AbsURL(ctx, h.SiteURL, h.BasePath), AbsURL(ctx, h.SiteURL, h.MediaURL))
+	if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	if b == nil {
+		ctx.HttpError(http.StatusNotFound)
+		return
+	}
+	WriteConditional(ctx, "application/xml; charset=utf-8", generated, b)
+}
+
+// watch has changes to `a` invalidate the sitemap, and related articles.
+func (h Handler) watch(a *articles.Article) {
+	if a != nil {
+		a.Printer = &articles.NotifyPrinter{Printer: a.Printer, OnChange: h.invalidate}
+	}
+}
+
+// invalidate drops what's cached from every article.
+func (h Handler) invalidate() {
+	if h.Sitemap != nil {
+		h.Sitemap.Invalidate()
+	}
+	if c, ok := h.Recommender.(*articles.RelatedCache); ok {
+		c.Invalidate()
+	}
+}
+
+/*-----------Reports----------*/
+func (h Handler) GetLinkReport(ctx wombat.Context) {
+	ctx.Response.Header().Set("Content-Type", "application/json")
+
+	broken, err := h.Links.Report(h.Pipeline)
+	if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	jd, _ := json.Marshal(broken)
+	ctx.Response.Write(jd)
+}
+
+// GetOEmbed is the oEmbed provider of the articles, the `url` of one being
+// resolved to its titlePath.
+func (h Handler) GetOEmbed(ctx wombat.Context) {
+	format := ctx.FormValue("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "xml" {
+		ctx.HttpError(http.StatusNotImplemented)
+		return
+	}
+
+	titlePath, lang, ok := h.resolveURL(ctx, ctx.FormValue("url"))
+	if !ok {
+		ctx.HttpError(http.StatusNotFound)
+		return
+	}
+	o, ok := h.Article(titlePath, false)
+	if !ok {
+		ctx.HttpError(http.StatusNotFound)
+		return
+	}
+	a := h.ItoArticle(o)
+	if a == nil {
+		ctx.HttpError(http.StatusNotFound)
+		return
+	}
+	if lang != "" {
+		a.Localize(lang)
+	}
+
+	maxWidth, _ := strconv.Atoi(ctx.FormValue("maxwidth"))
+	maxHeight, _ := strconv.Atoi(ctx.FormValue("maxheight"))
+	name := h.SiteName
+	if name == "" {
+		name = h.FeedTitle
+	}
+	e := articles.NewOEmbed(a, AbsURL(ctx, h.SiteURL, h.BasePath+"/"+titlePath),
+		AbsURL(ctx, h.SiteURL, h.MediaURL), name, AbsURL(ctx, h.SiteURL, "/"), maxWidth, maxHeight)
+
+	var b []byte
+	var err error
+	contentType := "application/json"
+	if format == "xml" {
+		b, err = e.XML()
+		contentType = "text/xml; charset=utf-8"
+	} else {
+		b, err = e.JSON()
+	}
+	if err != nil {
+		ctx.HttpError(http.StatusInternalServerError, GetError(ctx.Request, err))
+		return
+	}
+	WriteConditional(ctx, contentType, a.Modified, b)
+}
+
+// resolveURL returns the titlePath, and language, of the URL of an article
+// of this site.
+func (h Handler) resolveURL(ctx wombat.Context, s string) (titlePath, lang string, ok bool) {
+	u, err := url.Parse(s)
+	if err != nil || !u.IsAbs() {
+		return "", "", false
+	}
+	site, err := url.Parse(AbsURL(ctx, h.SiteURL, "/"))
+	if err != nil || !strings.EqualFold(u.Host, site.Host) {
+		return "", "", false
+	}
+	titlePath = strings.TrimPrefix(u.Path, h.BasePath+"/")
+	if titlePath == u.Path || !articles.ValidTitlePath(titlePath) {
+		return "", "", false
+	}
+	return titlePath, u.Query().Get("lang"), true
+}
+
+// GetBackup streams a backup of every article, and the images, which
+// `articles-backup -restore` replays into any backend.
+func (h Handler) GetBackup(ctx wombat.Context) {
+	name := fmt.Sprintf("articles-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+	ctx.Response.Header().Set("Content-Type", "application/gzip")
+	ctx.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+
+	// Headers are sent by now, so failures can only be logged
+	if _, err := articles.Backup(ctx.Response, h.articles.Reader, h.ItoArticles, h.ImagePath); err != nil {
+		log.Println("Failed to backup articles:", err)
+	}
+}
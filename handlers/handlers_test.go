@@ -0,0 +1,406 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"code.minty.io/dingo"
+	"code.minty.io/wombat"
+	articles "code.minty.io/wombat-articles"
+)
+
+func TestWriteConditional(t *testing.T) {
+	body := []byte("<feed/>")
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(body))
+	modified := time.Date(2013, 6, 1, 10, 0, 0, 500, time.UTC)
+	at := func(d time.Duration) string { return modified.Add(d).Format(http.TimeFormat) }
+
+	tests := []struct {
+		name, match, since string
+		modified           time.Time
+		status             int
+	}{
+		{"unconditional", "", "", modified, http.StatusOK},
+
+		{"etag", etag, "", modified, http.StatusNotModified},
+		{"etag listed", `"other", ` + etag, "", modified, http.StatusNotModified},
+		{"weak etag", "W/" + etag, "", modified, http.StatusNotModified},
+		{"any etag", "*", "", modified, http.StatusNotModified},
+		{"other etag", `"other"`, "", modified, http.StatusOK},
+		{"etag first", `"other"`, at(time.Hour), modified, http.StatusOK}, // If-Modified-Since is ignored
+
+		{"not modified since", "", at(0), modified, http.StatusNotModified},
+		{"not modified since later", "", at(time.Hour), modified, http.StatusNotModified},
+		{"modified since", "", at(-time.Second), modified, http.StatusOK},
+		{"unknown modified", "", at(0), time.Time{}, http.StatusOK},
+		{"invalid since", "", "yesterday", modified, http.StatusOK},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "http://example.com/articles/feed.atom", nil)
+		if test.match != "" {
+			r.Header.Set("If-None-Match", test.match)
+		}
+		if test.since != "" {
+			r.Header.Set("If-Modified-Since", test.since)
+		}
+		w := httptest.NewRecorder()
+		WriteConditional(wombat.Context{Context: dingo.Context{Request: r, Response: w}},
+			"application/atom+xml", test.modified, body)
+
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, w.Code, test.status)
+		}
+		wantBody := string(body)
+		if test.status == http.StatusNotModified {
+			wantBody = ""
+		}
+		if w.Body.String() != wantBody {
+			t.Errorf("%s: body %q, want %q", test.name, w.Body.String(), wantBody)
+		}
+
+		// Validators are sent either way
+		h := w.Header()
+		if h.Get("ETag") != etag || h.Get("Content-Type") != "application/atom+xml" {
+			t.Errorf("%s: headers %v", test.name, h)
+		}
+		wantModified := "Sat, 01 Jun 2013 10:00:00 GMT"
+		if test.modified.IsZero() {
+			wantModified = ""
+		}
+		if h.Get("Last-Modified") != wantModified {
+			t.Errorf("%s: Last-Modified %q, want %q", test.name, h.Get("Last-Modified"), wantModified)
+		}
+	}
+}
+
+func TestTagFeedPath(t *testing.T) {
+	h := Handler{BasePath: "/articles"}
+	tests := map[string]string{
+		"News":     "/articles/tags/news/feed.atom",
+		"Go & C++": "/articles/tags/go-c/feed.atom",
+		"a/b?c#d":  "/articles/tags/a-b-c-d/feed.atom",
+		"Café":     "/articles/tags/café/feed.atom",
+		"..":       "",
+		"%2F":      "/articles/tags/2f/feed.atom",
+		"":         "",
+	}
+	for tag, want := range tests {
+		if got := h.TagFeedPath(tag); got != want {
+			t.Errorf("TagFeedPath(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestGetLinkReport(t *testing.T) {
+	tests := []struct {
+		name string
+		list []*articles.Article
+		want string
+	}{
+		{"none", nil, `[]`},
+		{"broken", []*articles.Article{
+			{TitlePath: "2013/06/02/b/", Content: `<a href="article:2013/06/01/a/">a</a>`},
+			{TitlePath: "2013/06/01/a/", IsPublished: true,
+				Content: `<a href="article:2013/06/02/b/">b</a><a href="article:2013/06/03/c/">c</a>`},
+		}, `[{"titlePath":"2013/06/01/a/","url":"article:2013/06/02/b/","reason":"unpublished article"},` +
+			`{"titlePath":"2013/06/01/a/","url":"article:2013/06/03/c/","reason":"missing article"}]`},
+	}
+	for _, test := range tests {
+		r := &listReader{list: test.list}
+		h := Handler{Pipeline: articles.NewPipeline(),
+			Links: &articles.Links{Reader: r, ItoArticle: baseArticle, ItoArticles: articles.ToArticles,
+				BasePath: "/articles", MediaURL: "/media/"}}
+		req, _ := http.NewRequest("GET", "http://example.com/articles/links/", nil)
+		w := httptest.NewRecorder()
+		h.GetLinkReport(wombat.Context{Context: dingo.Context{Request: req, Response: w}})
+
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: status %d, headers %v", test.name, w.Code, w.Header())
+		}
+		if got := w.Body.String(); got != test.want {
+			t.Errorf("%s: report\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNegotiateLang(t *testing.T) {
+	tests := []struct {
+		name, lang, accept string
+		langs              []string
+		want               string
+	}{
+		{"default", "", "", []string{"en", "fr"}, "en"},
+		{"none", "fr", "fr", nil, ""},
+		{"value", "fr", "en", []string{"en", "fr"}, "fr"},
+		{"unknown value", "de", "fr", []string{"en", "fr"}, "fr"},
+		{"header", "", "fr", []string{"en", "fr"}, "fr"},
+		{"case", "", "FR", []string{"en", "fr"}, "fr"},
+
+		// Quality values
+		{"q", "", "fr;q=0.5, en;q=0.8", []string{"fr", "en"}, "en"},
+		{"q default", "", "fr;q=0.9, en", []string{"fr", "en"}, "en"},
+		{"q zero", "", "fr;q=0, de", []string{"en", "fr"}, "en"},
+		{"q invalid", "", "fr;q=x", []string{"en", "fr"}, "en"},
+		{"q tie", "", "fr, en", []string{"en", "fr"}, "fr"},
+
+		// Regions, and their primary language
+		{"region fallback", "", "en-GB", []string{"fr", "en"}, "en"},
+		{"region of tag", "", "pt-BR", []string{"en", "pt_PT"}, "pt_PT"},
+		{"exact region", "", "en-GB", []string{"fr", "en-US", "en-GB"}, "en-GB"},
+		{"no match", "", "de, ja;q=0.5", []string{"en", "fr"}, "en"},
+	}
+	for _, test := range tests {
+		u := "http://example.com/articles/2013/06/01/a/"
+		if test.lang != "" {
+			u += "?lang=" + test.lang
+		}
+		r, _ := http.NewRequest("GET", u, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept-Language", test.accept)
+		}
+		ctx := wombat.Context{Context: dingo.Context{Request: r, Response: httptest.NewRecorder()}}
+		if got := NegotiateLang(ctx, test.langs); got != test.want {
+			t.Errorf("%s: NegotiateLang(%q, %v) = %q, want %q", test.name, test.accept, test.langs, got, test.want)
+		}
+	}
+}
+
+// seriesPrinter records the order series are reordered to.
+type seriesPrinter struct {
+	articles.Printer
+	order []string
+}
+
+func (p *seriesPrinter) ReorderSeries(name string, titlePaths []string) error {
+	p.order = titlePaths
+	return nil
+}
+
+func TestPutSeries(t *testing.T) {
+	tests := []struct {
+		name, series, body string
+		status             int
+		order              string
+	}{
+		{"reordered", "go", `{"titlePaths":["c/","a/","b/"]}`, http.StatusOK, "c/ a/ b/"},
+		{"missing article", "go", `{"titlePaths":["c/","a/"]}`, http.StatusBadRequest, "a/ b/ c/"},
+		{"other article", "go", `{"titlePaths":["c/","a/","d/"]}`, http.StatusBadRequest, "a/ b/ c/"},
+		{"invalid", "go", `{"titlePaths":`, http.StatusBadRequest, "a/ b/ c/"},
+		{"unknown series", "rust", `{"titlePaths":[]}`, http.StatusNotFound, "a/ b/ c/"},
+	}
+	for _, test := range tests {
+		p := new(seriesPrinter)
+		s := &articles.Series{Printer: p, Name: "go", TitlePaths: []string{"a/", "b/", "c/"}}
+		h := Handler{articles: articles.Articles{Reader: &listReader{series: []*articles.Series{s}}}}
+		r, _ := http.NewRequest("PUT", "http://example.com/articles/series/"+test.series+"/", strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		h.PutSeries(wombat.Context{Context: dingo.Context{Request: r, Response: w}}, test.series)
+
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, w.Code, test.status)
+		}
+		if got := strings.Join(s.TitlePaths, " "); got != test.order {
+			t.Errorf("%s: order %s, want %s", test.name, got, test.order)
+		}
+		if test.status == http.StatusOK && strings.Join(p.order, " ") != test.order {
+			t.Errorf("%s: persisted order %v", test.name, p.order)
+		}
+	}
+}
+
+func TestDataSeries(t *testing.T) {
+	a := &articles.Article{TitlePath: "2013/06/02/b/", Title: "B", IsPublished: true}
+	r := &listReader{list: []*articles.Article{a}, series: []*articles.Series{
+		{Name: "go", TitlePaths: []string{"2013/06/01/a/", "2013/06/02/b/", "2013/06/03/c/"}}}}
+	h := Handler{articles: articles.Articles{Reader: r}, ItoArticle: baseArticle, ItoArticles: articles.ToArticles,
+		SiteURL: "http://example.com", BasePath: "/articles"}
+	req, _ := http.NewRequest("GET", "http://example.com/articles/2013/06/02/b/", nil)
+	ctx := wombat.Context{Context: dingo.Context{Request: req, Response: httptest.NewRecorder()}}
+
+	d := h.Data(ctx, a, a.TitlePath).(*ArticleData)
+	want := articles.SeriesNav{Name: "go", Part: 2, Of: 3, Prev: "2013/06/01/a/", Next: "2013/06/03/c/"}
+	if d.Series == nil || *d.Series != want {
+		t.Errorf("series %+v, want %+v", d.Series, want)
+	}
+
+	// Articles of no series have none
+	r.series = nil
+	if d := h.Data(ctx, a, a.TitlePath).(*ArticleData); d.Series != nil {
+		t.Errorf("series %+v of an article of none", d.Series)
+	}
+}
+
+func TestDataAlternates(t *testing.T) {
+	const u = "http://example.com/articles/2013/06/01/a/"
+	tests := []struct {
+		exporting bool
+		want      []Alternate
+		url       string
+	}{
+		// Every language explicitly, as the article's own URL negotiates
+		{false, []Alternate{{"en", u + "?lang=en"}, {"fr", u + "?lang=fr"}, {"x-default", u}}, u + "?lang=en"},
+		{true, []Alternate{{"en", u}, {"fr", u + "fr/"}, {"x-default", u}}, u},
+	}
+	for _, test := range tests {
+		a := &articles.Article{TitlePath: "2013/06/01/a/", Title: "A", Lang: "en", IsPublished: true,
+			Translations: []articles.Translation{{Lang: "fr", Title: "Un"}}}
+		h := Handler{articles: articles.Articles{Reader: &listReader{}}, ItoArticle: baseArticle,
+			SiteURL: "http://example.com", BasePath: "/articles", exporting: test.exporting}
+		req, _ := http.NewRequest("GET", u, nil)
+		ctx := wombat.Context{Context: dingo.Context{Request: req, Response: httptest.NewRecorder()}}
+
+		d := h.Data(ctx, a, a.TitlePath).(*ArticleData)
+		if !reflect.DeepEqual(d.Alternates, test.want) {
+			t.Errorf("exporting %t: alternates %v, want %v", test.exporting, d.Alternates, test.want)
+		}
+		if d.Meta.URL != test.url {
+			t.Errorf("exporting %t: URL %s, want %s", test.exporting, d.Meta.URL, test.url)
+		}
+	}
+
+	// Articles of one language have none
+	a := &articles.Article{TitlePath: "2013/06/01/a/", Title: "A", IsPublished: true}
+	h := Handler{articles: articles.Articles{Reader: &listReader{}}, ItoArticle: baseArticle,
+		SiteURL: "http://example.com", BasePath: "/articles"}
+	req, _ := http.NewRequest("GET", u, nil)
+	ctx := wombat.Context{Context: dingo.Context{Request: req, Response: httptest.NewRecorder()}}
+	if d := h.Data(ctx, a, a.TitlePath).(*ArticleData); d.Alternates != nil || d.Meta.URL != u {
+		t.Errorf("alternates %v, and URL %s, of an untranslated article", d.Alternates, d.Meta.URL)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	june := func(day int) time.Time { return time.Date(2013, 6, day, 0, 0, 0, 0, time.UTC) }
+	base := articles.Query{Limit: 30, Sort: "-created", PinnedFirst: true}
+	tests := []struct {
+		name, values string
+		want         func(q *articles.Query)
+	}{
+		{"none", "", func(q *articles.Query) {}},
+
+		{"page", "page=2", func(q *articles.Query) { q.Page = 2 }},
+		{"negative page", "page=-1", func(q *articles.Query) {}},
+		{"invalid page", "page=two", func(q *articles.Query) {}},
+
+		// A sort, in either direction, lists pinned articles in place
+		{"sort", "sort=title", func(q *articles.Query) { q.Sort, q.PinnedFirst = "title", false }},
+		{"descending sort", "sort=-modified", func(q *articles.Query) { q.Sort, q.PinnedFirst = "-modified", false }},
+		{"invalid sort", "sort=author", func(q *articles.Query) {}},
+
+		// Only admins see unpublished articles
+		{"published", "published=false", func(q *articles.Query) {}},
+
+		// Both days inclusive
+		{"from", "from=2013-06-01", func(q *articles.Query) { q.From = june(1) }},
+		{"to", "to=2013-06-30", func(q *articles.Query) { q.To = june(31) }},
+		{"range", "from=2013-06-01&to=2013-06-01", func(q *articles.Query) { q.From, q.To = june(1), june(2) }},
+		{"invalid dates", "from=June&to=2013-06-31", func(q *articles.Query) {}},
+
+		{"tag", "tag=go", func(q *articles.Query) { q.Tag = "go" }},
+		{"author", "author=Ann", func(q *articles.Query) { q.Author = "Ann" }},
+		{"thumb", "thumb=true", func(q *articles.Query) { q.HasThumb = true }},
+		{"invalid thumb", "thumb=yes", func(q *articles.Query) {}},
+		{"featured", "featured=1", func(q *articles.Query) { q.Featured = true }},
+		{"lang", "lang=fr", func(q *articles.Query) { q.Lang = "fr" }},
+
+		{"combined", "page=1&sort=-title&tag=go&featured=true&from=2013-06-02", func(q *articles.Query) {
+			q.Page, q.Sort, q.PinnedFirst, q.Tag, q.Featured, q.From = 1, "-title", false, "go", true, june(2)
+		}},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "http://example.com/articles/?"+test.values, nil)
+		ctx := wombat.Context{Context: dingo.Context{Request: r, Response: httptest.NewRecorder()}}
+		want := base
+		test.want(&want)
+		if got := ParseQuery(ctx, 30); got != want {
+			t.Errorf("%s: ParseQuery(%q)\n got %+v\nwant %+v", test.name, test.values, got, want)
+		}
+	}
+}
+
+func TestGetOEmbed(t *testing.T) {
+	const u = "http://example.com/articles/2013/06/01/a/"
+	tests := []struct {
+		name, values string
+		status       int
+		contentType  string
+		want         string
+	}{
+		{"json", "url=" + u, http.StatusOK, "application/json", `"title":"A"`},
+		{"xml", "format=xml&url=" + u, http.StatusOK, "text/xml; charset=utf-8", "<title>A</title>"},
+		{"translation", "url=" + u + "%3Flang%3Dfr", http.StatusOK, "application/json", `"title":"Un"`},
+		{"max width", "maxwidth=300&url=" + u, http.StatusOK, "application/json", `"width":300`},
+		{"other format", "format=yaml&url=" + u, http.StatusNotImplemented, "", ""},
+
+		// Only the URLs of published articles of this site
+		{"unpublished", "url=http://example.com/articles/2013/06/02/b/", http.StatusNotFound, "", ""},
+		{"missing", "url=http://example.com/articles/2013/06/03/c/", http.StatusNotFound, "", ""},
+		{"other site", "url=http://example.org/articles/2013/06/01/a/", http.StatusNotFound, "", ""},
+		{"relative", "url=/articles/2013/06/01/a/", http.StatusNotFound, "", ""},
+		{"not an article", "url=http://example.com/articles/feed.atom", http.StatusNotFound, "", ""},
+		{"no url", "", http.StatusNotFound, "", ""},
+	}
+	for _, test := range tests {
+		r := &listReader{list: []*articles.Article{
+			{TitlePath: "2013/06/01/a/", Title: "A", IsPublished: true,
+				Translations: []articles.Translation{{Lang: "fr", Title: "Un"}}},
+			{TitlePath: "2013/06/02/b/", Title: "B"},
+		}}
+		h := Handler{articles: articles.Articles{Reader: r}, ItoArticle: baseArticle,
+			SiteURL: "http://example.com", BasePath: "/articles", MediaURL: "/media/", SiteName: "Site"}
+		req, _ := http.NewRequest("GET", "http://example.com/articles/oembed?"+test.values, nil)
+		w := httptest.NewRecorder()
+		h.GetOEmbed(wombat.Context{Context: dingo.Context{Request: req, Response: w}})
+
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, w.Code, test.status)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		if w.Header().Get("Content-Type") != test.contentType || !strings.Contains(w.Body.String(), test.want) {
+			t.Errorf("%s: %s without %s:\n%s", test.name, w.Header().Get("Content-Type"), test.want, w.Body.String())
+		}
+	}
+}
+
+func TestGetJSONFeed(t *testing.T) {
+	for _, full := range []bool{false, true} {
+		r := &listReader{list: []*articles.Article{
+			{TitlePath: "2013/06/02/b/", Title: "B", IsPublished: true, Content: "b",
+				Rendered: `<a href="/articles/2013/06/01/a/">a</a>`, Created: time.Date(2013, 6, 2, 0, 0, 0, 0, time.UTC)},
+			{TitlePath: "2013/06/01/a/", Title: "A", IsPublished: true, Content: "a",
+				Rendered: `<img src="/media/2013/06/01/a/a.png">`, Created: time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)},
+		}}
+		views := 0
+		p := articles.NewPipeline()
+		p.Views = []articles.Filter{func(a *articles.Article, s string) string { views++; return s }}
+		h := Handler{articles: articles.Articles{Reader: r}, ItoArticles: articles.ToArticles, Pipeline: p,
+			FeedCount: 10, FeedTitle: "Articles", FeedFull: full,
+			SiteURL: "http://example.com", BasePath: "/articles", MediaURL: "/media/"}
+		req, _ := http.NewRequest("GET", "http://example.com/articles/feed.json", nil)
+		w := httptest.NewRecorder()
+		h.GetJSONFeed(wombat.Context{Context: dingo.Context{Request: req, Response: w}})
+
+		// Rendered once, whether the feed is full or not
+		if views != 2 {
+			t.Errorf("full %t: %d views of 2 articles", full, views)
+		}
+		for _, u := range []string{`http://example.com/articles/2013/06/01/a/`, `http://example.com/media/2013/06/01/a/a.png`} {
+			if !strings.Contains(w.Body.String(), `\"`+u+`\"`) {
+				t.Errorf("full %t: content without the absolute %s:\n%s", full, u, w.Body.String())
+			}
+		}
+	}
+}
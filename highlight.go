@@ -0,0 +1,298 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+// Classes of the spans code is highlighted with.
+const (
+	ClassKeyword  = "hl-k"
+	ClassType     = "hl-t"
+	ClassString   = "hl-s"
+	ClassComment  = "hl-c"
+	ClassNumber   = "hl-n"
+	ClassVariable = "hl-v"
+)
+
+// Lexer describes the syntax of a language, enough to highlight it.
+type Lexer struct {
+	Keywords      map[string]bool
+	Types         map[string]bool
+	LineComments  []string
+	BlockComments [][2]string
+	Quotes        string // string delimiters
+	RawQuotes     string // string delimiters without escapes
+	Variables     bool   // shell style `$var` variables
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var goLexer = &Lexer{
+	Keywords: words(`break case chan const continue default defer else fallthrough
+		for func go goto if import interface map package range return select
+		struct switch type var`),
+	Types: words(`bool byte complex64 complex128 error float32 float64 int int8
+		int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr
+		true false iota nil append cap close complex copy delete imag len make
+		new panic print println real recover`),
+	LineComments:  []string{"//"},
+	BlockComments: [][2]string{{"/*", "*/"}},
+	Quotes:        `"'`,
+	RawQuotes:     "`",
+}
+
+var shellLexer = &Lexer{
+	Keywords: words(`if then else elif fi for while until do done case esac in
+		function return export local readonly set unset shift exit break continue`),
+	Types: words(`echo printf cd ls cat grep sed awk test read source eval exec
+		mkdir rm cp mv chmod chown sudo go git make curl`),
+	LineComments: []string{"#"},
+	Quotes:       `"`,
+	RawQuotes:    `'`,
+	Variables:    true,
+}
+
+var jsLexer = &Lexer{
+	Keywords: words(`async await break case catch class const continue debugger
+		default delete do else export extends finally for function if import in
+		instanceof let new of return static super switch this throw try typeof
+		var void while with yield`),
+	Types: words(`true false null undefined NaN Infinity Array Boolean Date
+		Error JSON Math Number Object Promise RegExp String Symbol console
+		window document`),
+	LineComments:  []string{"//"},
+	BlockComments: [][2]string{{"/*", "*/"}},
+	Quotes:        "\"'`",
+}
+
+var pythonLexer = &Lexer{
+	Keywords: words(`and as assert async await break class continue def del
+		elif else except finally for from global if import in is lambda
+		nonlocal not or pass raise return try while with yield`),
+	Types: words(`True False None bool bytes dict float int list object set
+		str tuple len print range open super self isinstance`),
+	LineComments: []string{"#"},
+	Quotes:       `"'`,
+}
+
+var cLexer = &Lexer{
+	Keywords: words(`auto break case catch class const continue default delete
+		do else enum extern for goto if inline namespace new private protected
+		public return sizeof static struct switch template this throw try
+		typedef union using virtual volatile while`),
+	Types: words(`bool char double float int long short signed unsigned void
+		size_t true false NULL nullptr`),
+	LineComments:  []string{"//"},
+	BlockComments: [][2]string{{"/*", "*/"}},
+	Quotes:        `"'`,
+}
+
+// Lexers by the language named in a code block's `language-x` class.
+var Lexers = map[string]*Lexer{
+	"go":         goLexer,
+	"golang":     goLexer,
+	"sh":         shellLexer,
+	"bash":       shellLexer,
+	"shell":      shellLexer,
+	"console":    shellLexer,
+	"js":         jsLexer,
+	"javascript": jsLexer,
+	"json":       jsLexer,
+	"py":         pythonLexer,
+	"python":     pythonLexer,
+	"c":          cLexer,
+	"cpp":        cLexer,
+	"c++":        cLexer,
+	"java":       cLexer,
+}
+
+// Highlight highlights the code of every `<pre><code class="language-x">`
+// block, for which there's a Lexer, as a step of a Pipeline.
+func Highlight(a *Article, s string) string {
+	tokens := tokenize(s)
+	out := make([]token, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		out = append(out, t)
+		if t.Type != startToken || t.Name != "code" || len(out) < 2 {
+			continue
+		}
+		if prev := out[len(out)-2]; prev.Type != startToken || prev.Name != "pre" {
+			continue
+		}
+		class, _ := t.Attr("class")
+		lexer := Lexers[codeLanguage(class)]
+		if lexer == nil {
+			continue
+		}
+
+		// The code, up to the end of the block
+		var code bytes.Buffer
+		j := i + 1
+		for ; j < len(tokens) && !(tokens[j].Type == endToken && tokens[j].Name == "code"); j++ {
+			if tokens[j].Type == textToken {
+				code.WriteString(html.UnescapeString(tokens[j].Data))
+			}
+		}
+		out[len(out)-1].SetAttr("class", strings.TrimSpace(class+" hl"))
+		out = append(out, token{Type: textToken, Data: lexer.Highlight(code.String())})
+		i = j - 1
+	}
+	return render(out)
+}
+
+// codeLanguage returns the language of a `language-x`, or `lang-x`, class.
+func codeLanguage(class string) string {
+	for _, c := range strings.Fields(class) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(c, prefix) {
+				return strings.ToLower(c[len(prefix):])
+			}
+		}
+	}
+	return ""
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// Highlight escapes `code`, wrapping its keywords, types, strings, comments,
+// numbers and variables in classed spans.
+func (l *Lexer) Highlight(code string) string {
+	var b bytes.Buffer
+	span := func(class, s string) {
+		b.WriteString(`<span class="` + class + `">` + html.EscapeString(s) + "</span>")
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+
+		// Comments
+		if n := l.comment(code, i); n > 0 {
+			span(ClassComment, rest[:n])
+			i += n
+			continue
+		}
+
+		switch c := code[i]; {
+		case strings.IndexByte(l.Quotes, c) >= 0 || strings.IndexByte(l.RawQuotes, c) >= 0:
+			raw := strings.IndexByte(l.RawQuotes, c) >= 0
+			n := 1
+			for n < len(rest) && rest[n] != c {
+				if rest[n] == '\n' && c != '`' && !raw {
+					break
+				}
+				if rest[n] == '\\' && !raw {
+					n++
+				}
+				n++
+			}
+			if n < len(rest) && rest[n] == c {
+				n++
+			} else if n > len(rest) {
+				n = len(rest)
+			}
+			span(ClassString, rest[:n])
+			i += n
+
+		case c >= '0' && c <= '9' && (i == 0 || !isIdentByte(code[i-1])):
+			n := 1
+			for n < len(rest) && (isIdentByte(rest[n]) || rest[n] == '.') {
+				n++
+			}
+			span(ClassNumber, rest[:n])
+			i += n
+
+		case isIdentByte(c):
+			n := 1
+			for n < len(rest) && isIdentByte(rest[n]) {
+				n++
+			}
+			switch word := rest[:n]; {
+			case l.Keywords[word]:
+				span(ClassKeyword, word)
+			case l.Types[word]:
+				span(ClassType, word)
+			default:
+				b.WriteString(html.EscapeString(word))
+			}
+			i += n
+
+		case c == '$' && l.Variables && len(rest) > 1:
+			n := 2
+			if rest[1] == '{' {
+				if end := strings.IndexByte(rest, '}'); end > 0 {
+					n = end + 1
+				}
+			} else {
+				for n < len(rest) && isIdentByte(rest[n]) {
+					n++
+				}
+			}
+			span(ClassVariable, rest[:n])
+			i += n
+
+		default:
+			b.WriteString(html.EscapeString(rest[:1]))
+			i++
+		}
+	}
+	return b.String()
+}
+
+// comment returns the length of the comment starting at `code[i]`.
+func (l *Lexer) comment(code string, i int) int {
+	rest := code[i:]
+	for _, c := range l.LineComments {
+		// Shell style comments only start a word
+		if c == "#" && i > 0 && !isSpace(code[i-1]) {
+			continue
+		}
+		if strings.HasPrefix(rest, c) {
+			if end := strings.IndexByte(rest, '\n'); end >= 0 {
+				return end
+			}
+			return len(rest)
+		}
+	}
+	for _, c := range l.BlockComments {
+		if strings.HasPrefix(rest, c[0]) {
+			if end := strings.Index(rest[len(c[0]):], c[1]); end >= 0 {
+				return len(c[0]) + end + len(c[1])
+			}
+			return len(rest)
+		}
+	}
+	return 0
+}
+
+// HighlightStyles are stylesheets for highlighted code, by name.
+var HighlightStyles = map[string]string{
+	"light": `.hl .hl-k { color: #a626a4; font-weight: bold; }
+.hl .hl-t { color: #4078f2; }
+.hl .hl-s { color: #50a14f; }
+.hl .hl-c { color: #a0a1a7; font-style: italic; }
+.hl .hl-n { color: #986801; }
+.hl .hl-v { color: #e45649; }
+`,
+	"dark": `.hl .hl-k { color: #c678dd; font-weight: bold; }
+.hl .hl-t { color: #61afef; }
+.hl .hl-s { color: #98c379; }
+.hl .hl-c { color: #5c6370; font-style: italic; }
+.hl .hl-n { color: #d19a66; }
+.hl .hl-v { color: #e06c75; }
+`,
+}
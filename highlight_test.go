@@ -0,0 +1,83 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import "testing"
+
+func TestLexerHighlight(t *testing.T) {
+	tests := []struct {
+		lexer     *Lexer
+		code, out string
+	}{
+		// Everything is escaped, within spans or not
+		{goLexer, `if a < b && c > 0 {}`,
+			`<span class="hl-k">if</span> a &lt; b &amp;&amp; c &gt; <span class="hl-n">0</span> {}`},
+		{goLexer, "x := \"<b>&\" // a<b\n",
+			`x := <span class="hl-s">&#34;&lt;b&gt;&amp;&#34;</span> <span class="hl-c">// a&lt;b</span>` + "\n"},
+		{goLexer, `/* <x> */ 0x1F`,
+			`<span class="hl-c">/* &lt;x&gt; */</span> <span class="hl-n">0x1F</span>`},
+
+		// Strings
+		{goLexer, "`raw\\n`", "<span class=\"hl-s\">`raw\\n`</span>"},
+		{goLexer, `'\''`, `<span class="hl-s">&#39;\&#39;&#39;</span>`},
+		{goLexer, "\"unterminated\nnext", "<span class=\"hl-s\">&#34;unterminated</span>\nnext"},
+
+		// Numbers, not within identifiers
+		{goLexer, `x1 := 2.5e3`, `x1 := <span class="hl-n">2.5e3</span>`},
+		{goLexer, `string(nil)`, `<span class="hl-t">string</span>(<span class="hl-t">nil</span>)`},
+
+		// Shell variables, and comments only starting a word
+		{shellLexer, `echo $HOME ${X}#no # yes <x>`,
+			`<span class="hl-t">echo</span> <span class="hl-v">$HOME</span> <span class="hl-v">${X}</span>#no <span class="hl-c"># yes &lt;x&gt;</span>`},
+		{shellLexer, `a='$x' "$y"`,
+			`a=<span class="hl-s">&#39;$x&#39;</span> <span class="hl-s">&#34;$y&#34;</span>`},
+	}
+	for _, test := range tests {
+		if got := test.lexer.Highlight(test.code); got != test.out {
+			t.Errorf("Highlight(%q)\n got %q\nwant %q", test.code, got, test.out)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{`<pre><code class="language-go">if a &lt; b {}</code></pre>`,
+			`<pre><code class="language-go hl"><span class="hl-k">if</span> a &lt; b {}</code></pre>`},
+		{`<pre><code class="x lang-py">None</code></pre>`,
+			`<pre><code class="x lang-py hl"><span class="hl-t">None</span></code></pre>`},
+		{`<pre><code class="language-go"></code></pre>`, `<pre><code class="language-go hl"></code></pre>`},
+
+		// Markup within the code is dropped, entities decoded and escaped again
+		{`<pre><code class="language-go">a &amp;&amp; <b>b</b></code></pre>`,
+			`<pre><code class="language-go hl">a &amp;&amp; b</code></pre>`},
+
+		// Unknown languages, and inline code, are left be
+		{`<pre><code class="language-cobol">if</code></pre>`, `<pre><code class="language-cobol">if</code></pre>`},
+		{`<pre><code>if</code></pre>`, `<pre><code>if</code></pre>`},
+		{`<code class="language-go">if</code>`, `<code class="language-go">if</code>`},
+	}
+	for _, test := range tests {
+		if got := Highlight(nil, test.in); got != test.out {
+			t.Errorf("Highlight(%q)\n got %q\nwant %q", test.in, got, test.out)
+		}
+	}
+}
+
+func TestCodeLanguage(t *testing.T) {
+	tests := map[string]string{
+		"language-go":        "go",
+		"x lang-Python":      "python",
+		"language-c++ other": "c++",
+		"go":                 "",
+		"":                   "",
+	}
+	for class, want := range tests {
+		if got := codeLanguage(class); got != want {
+			t.Errorf("codeLanguage(%q) = %q, want %q", class, got, want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package importers imports articles from other blogging platforms, writing
+// them through any articles.Printer.
+package importers
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	articles "code.minty.io/wombat-articles"
+)
+
+// Entry is the outcome of importing one post.
+type Entry struct {
+	TitlePath string
+	Title     string
+	Published bool
+	Imgs      []string
+	Skipped   string // why the post wasn't imported, if it wasn't
+	Updated   bool   // of an article imported before
+	Warnings  []string
+}
+
+// Report lists every post of an import, and what was, or would be for a dry
+// run, done with it.
+type Report struct {
+	DryRun  bool
+	Entries []*Entry
+}
+
+// Imported is the number of posts imported.
+func (r *Report) Imported() int {
+	n := 0
+	for _, e := range r.Entries {
+		if e.Skipped == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes the report, one post per line followed by its warnings.
+func (r *Report) Print(w io.Writer) {
+	verb := "imported"
+	if r.DryRun {
+		verb = "would import"
+	}
+	for _, e := range r.Entries {
+		if e.Skipped != "" {
+			fmt.Fprintf(w, "%-7s%s: %s\n", "skip", e.Title, e.Skipped)
+		} else {
+			state, action := "draft", "ok"
+			if e.Published {
+				state = "published"
+			}
+			if e.Updated {
+				action = "update"
+			}
+			fmt.Fprintf(w, "%-7s%s (%s, %d images)\n", action, e.TitlePath, state, len(e.Imgs))
+		}
+		for _, warning := range e.Warnings {
+			fmt.Fprintf(w, "       warning: %s\n", warning)
+		}
+	}
+	fmt.Fprintf(w, "%s %d of %d posts\n", verb, r.Imported(), len(r.Entries))
+}
+
+var errMissingDate = errors.New("Missing date")
+
+// Fetcher retrieves the attachments of posts by their URL.
+type Fetcher interface {
+	Fetch(u string) (io.ReadCloser, error)
+}
+
+// HTTPFetcher downloads attachments.
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+func (f HTTPFetcher) Fetch(u string) (io.ReadCloser, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Failed to fetch %s: %s", u, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// DirFetcher reads attachments from a local copy of the uploads directory,
+// such as WordPress's `wp-content/uploads`, so imports can run offline. The
+// path of an attachment's URL after Prefix is its path within Dir.
+type DirFetcher struct {
+	Dir    string
+	Prefix string
+}
+
+func (f DirFetcher) Fetch(u string) (io.ReadCloser, error) {
+	p := u
+	if parsed, err := url.Parse(u); err == nil {
+		p = parsed.Path
+	}
+	if i := strings.Index(p, f.Prefix); f.Prefix != "" && i >= 0 {
+		p = p[i+len(f.Prefix):]
+	}
+	return os.Open(filepath.Join(f.Dir, filepath.FromSlash(path.Clean("/"+p))))
+}
+
+// fileFetcher reads attachments from their path.
+type fileFetcher struct{}
+
+func (fileFetcher) Fetch(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+// Slug converts `s` into the last element of a titlePath, keeping its
+// ASCII letters and numbers.
+func Slug(s string) string {
+	if unescaped, err := url.QueryUnescape(s); err == nil {
+		s = unescaped
+	}
+	slug := articles.Slug(s)
+	out := make([]byte, 0, len(slug))
+	for i := 0; i < len(slug); i++ {
+		c := slug[i]
+		if c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' {
+			out = append(out, c)
+		}
+	}
+	return strings.Trim(strings.Replace(string(out), "--", "-", -1), "-")
+}
+
+// saveImg writes the attachment `u`, fetched by `f`, into the images of the
+// article `titlePath` as `name`, returning it as an Img.
+func saveImg(f Fetcher, imagePath, titlePath, name, u string) (articles.Img, error) {
+	img := articles.Img{Src: name}
+	r, err := f.Fetch(u)
+	if err != nil {
+		return img, err
+	}
+	defer r.Close()
+
+	dir := filepath.Join(imagePath, filepath.FromSlash(titlePath))
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return img, err
+	}
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return img, err
+	}
+	if _, err = io.Copy(file, r); err != nil {
+		file.Close()
+		return img, err
+	}
+	if _, err = file.Seek(0, 0); err == nil {
+		img.W, img.H = imgSize(file)
+	}
+	return img, file.Close()
+}
+
+// imgSize returns the dimensions of the image `r`, if it's one.
+func imgSize(r io.Reader) (w, h int) {
+	if c, _, err := image.DecodeConfig(r); err == nil {
+		return c.Width, c.Height
+	}
+	return 0, 0
+}
@@ -0,0 +1,505 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package importers
+
+import (
+	"html"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	articles "code.minty.io/wombat-articles"
+)
+
+var (
+	// Jekyll's `_posts/2013-06-01-slug.md`
+	datedName = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+	// `![alt](src "title")` and `<img src="src">`, along with its attributes
+	mdImg    = regexp.MustCompile(`(!\[[^\]]*\]\()\s*<?([^)\s>]+)>?((?:\s+"[^"]*")?\s*\))`)
+	htmlImg  = regexp.MustCompile(`(?i)<img\b[^>]*>`)
+	htmlAttr = regexp.MustCompile(`(?i)\s(src|alt|title)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	// Jekyll's `{{ site.baseurl }}`, and `{{ "/path" | relative_url }}`
+	siteURL   = regexp.MustCompile(`{{\s*site\.(baseurl|url)\s*}}`)
+	urlFilter = regexp.MustCompile(`{{\s*["']([^"']*)["']\s*\|\s*(relative|absolute)_url\s*}}`)
+
+	// What would end the parts of a Markdown image early
+	mdEscaper  = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`")
+	urlEscaper = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29")
+)
+
+var dateFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Markdown imports a directory of Markdown posts with YAML, or TOML, front
+// matter, such as Jekyll's `_posts`, or Hugo's `content/posts`, along with
+// the images they reference. Imports are idempotent: articles imported
+// before are updated, rather than duplicated, when Reader is set.
+type Markdown struct {
+	Printer    articles.Printer
+	Reader     articles.Reader
+	ItoArticle func(o interface{}) *articles.Article
+	Root       string // the site, every image being within it
+	ImagePath  string
+	MediaURL   string
+	DryRun     bool
+}
+
+func NewMarkdown(p articles.Printer, r articles.Reader, root, imagePath, mediaURL string) *Markdown {
+	return &Markdown{p, r, articles.ToArticle, root, imagePath, mediaURL, false}
+}
+
+// Import imports the posts within `dir`, and its subdirectories.
+func (m *Markdown) Import(dir string) (*Report, error) {
+	var files []string
+	err := filepath.Walk(dir, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() && (fi.Name() == "_drafts" || strings.HasPrefix(fi.Name(), ".")) && name != dir {
+			return filepath.SkipDir
+		}
+		if ext := strings.ToLower(filepath.Ext(name)); !fi.IsDir() && (ext == ".md" || ext == ".markdown") &&
+			fi.Name() != "_index.md" {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	report := &Report{DryRun: m.DryRun}
+	for _, name := range files {
+		report.Entries = append(report.Entries, m.importFile(name))
+	}
+	return report, nil
+}
+
+// post is an article read from a file, along with its images.
+type post struct {
+	article *articles.Article
+	imgs    map[string]string // image name, by its source file
+}
+
+func (m *Markdown) importFile(name string) *Entry {
+	e := &Entry{Title: name}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		e.Skipped = err.Error()
+		return e
+	}
+	p, err := m.parse(name, string(b))
+	if err != nil {
+		e.Skipped = err.Error()
+		return e
+	}
+	a := p.article
+	e.Title, e.TitlePath, e.Published = a.Title, a.TitlePath, a.IsPublished
+
+	// Images, by their source file
+	srcs := make([]string, 0, len(p.imgs))
+	for src := range p.imgs {
+		srcs = append(srcs, src)
+	}
+	sort.Strings(srcs)
+	for _, src := range srcs {
+		img := articles.Img{Src: p.imgs[src]}
+		if m.DryRun {
+			f, err := os.Open(src)
+			if err != nil {
+				e.Warnings = append(e.Warnings, err.Error())
+				continue
+			}
+			img.W, img.H = imgSize(f)
+			f.Close()
+		} else if img, err = saveImg(fileFetcher{}, m.ImagePath, a.TitlePath, p.imgs[src], src); err != nil {
+			e.Warnings = append(e.Warnings, err.Error())
+			continue
+		}
+		if img.Src == a.Img.Src {
+			a.Img = img
+		}
+		a.Imgs = append(a.Imgs, img)
+		e.Imgs = append(e.Imgs, img.Src)
+	}
+
+	// Articles imported before are updated
+	if m.Reader != nil {
+		if o, err := m.Reader.ByTitlePath(a.TitlePath, true); err == nil {
+			if existing := m.ItoArticle(o); existing != nil {
+				if err := m.update(existing, a, e); err != nil {
+					e.Skipped = err.Error()
+				}
+				return e
+			}
+		}
+	}
+	if !m.DryRun {
+		if err := a.Print(); err != nil {
+			e.Skipped = err.Error()
+		}
+	}
+	return e
+}
+
+// update writes whatever of `a` differs from the article `existing`.
+func (m *Markdown) update(existing, a *articles.Article, e *Entry) error {
+	p := m.Printer
+	var changes []func() error
+	if existing.Title != a.Title {
+		// There's no setter of the title, so it's replaced along with the
+		// rest of the article as it is, before anything else is updated
+		changes = append(changes, func() error {
+			replaced := *existing
+			replaced.Title, replaced.Modified = a.Title, a.Modified
+			return p.Replace(a.TitlePath, &replaced)
+		})
+	}
+	if existing.Content != a.Content {
+		changes = append(changes, func() error { return p.UpdateContent(a.TitlePath, a.Content, a.Stats, a.Modified) })
+	}
+	if existing.ContentFormat != a.ContentFormat {
+		changes = append(changes, func() error { return p.UpdateContentFormat(a.TitlePath, a.ContentFormat, a.Modified) })
+	}
+	if existing.Synopsis != a.Synopsis {
+		changes = append(changes, func() error { return p.UpdateSynopsis(a.TitlePath, a.Synopsis, a.Modified) })
+	}
+	if existing.Author != a.Author {
+		changes = append(changes, func() error { return p.WriteAuthor(a.TitlePath, a.Author) })
+	}
+	if !equalStrings(existing.Tags, a.Tags) {
+		changes = append(changes, func() error { return p.WriteTags(a.TitlePath, a.Tags) })
+	}
+	if existing.IsPublished != a.IsPublished {
+		changes = append(changes, func() error { return p.Publish(a.TitlePath, a.IsPublished) })
+	}
+	if existing.Img != a.Img {
+		changes = append(changes, func() error { return p.WriteImg(a.TitlePath, a.Img) })
+	}
+	if !equalImgs(existing.Imgs, a.Imgs) {
+		changes = append(changes, func() error { return p.WriteImgs(a.TitlePath, a.Imgs) })
+	}
+
+	if len(changes) == 0 {
+		e.Skipped = "unchanged"
+		return nil
+	}
+	e.Updated = true
+	if m.DryRun {
+		return nil
+	}
+	for _, change := range changes {
+		if err := change(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// equalStrings reports if `a` and `b` have the same elements, in order, a
+// nil slice being equal to an empty one, as backends may return either.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equalImgs is equalStrings, of images.
+func equalImgs(a, b []articles.Img) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parse reads the post `name`, of content `s`, into an article.
+func (m *Markdown) parse(name, s string) (*post, error) {
+	fm, body := FrontMatter(s)
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	if base == "index" {
+		// Hugo's page bundles, `slug/index.md`
+		base = filepath.Base(filepath.Dir(name))
+	}
+
+	// Created, from the front matter, else the file's name
+	var created time.Time
+	slug := base
+	if d := datedName.FindStringSubmatch(base); d != nil {
+		created, _ = time.Parse("2006-01-02", d[1])
+		slug = d[2]
+	}
+	if t, ok := parseDate(fmString(fm, "date")); ok {
+		created = t
+	}
+	if created.IsZero() {
+		return nil, errMissingDate
+	}
+	modified := created
+	if t, ok := parseDate(fmString(fm, "lastmod", "last_modified_at", "updated", "modified")); ok {
+		modified = t
+	}
+
+	if s := fmString(fm, "slug"); s != "" {
+		slug = s
+	}
+	title := fmString(fm, "title")
+	if slug = Slug(slug); slug == "" {
+		slug = Slug(title)
+	}
+	if title == "" {
+		title = slug
+	}
+
+	published := true
+	if b, ok := fm["draft"].(bool); ok && b {
+		published = false
+	}
+	if b, ok := fm["published"].(bool); ok && !b {
+		published = false
+	}
+
+	a := &articles.Article{
+		Printer:       m.Printer,
+		TitlePath:     articles.TitlePath(created, slug),
+		Title:         title,
+		Author:        fmString(fm, "author"),
+		Synopsis:      fmString(fm, "description", "summary", "excerpt"),
+		IsPublished:   published,
+		Created:       created,
+		Modified:      modified,
+		Tags:          append(fmList(fm, "tags"), fmList(fm, "categories")...),
+		ContentFormat: articles.FormatMarkdown,
+	}
+	p := &post{a, make(map[string]string)}
+
+	// Images, copied under the article's media, the content linking there
+	imgURL := func(src string) (string, bool) {
+		if src == "" || strings.Contains(src, "://") || strings.HasPrefix(src, "//") || strings.HasPrefix(src, "data:") {
+			return "", false
+		}
+		var file string
+		if strings.HasPrefix(src, "/") {
+			file = filepath.Join(m.Root, filepath.FromSlash(path.Clean(src)))
+		} else {
+			file = filepath.Join(filepath.Dir(name), filepath.FromSlash(path.Clean(src)))
+		}
+		if !within(m.Root, file) {
+			// Not of the site, such as `../../etc/passwd`, so not published
+			return "", false
+		}
+		n, ok := p.imgs[file]
+		if !ok {
+			n = path.Base(src)
+			for _, other := range p.imgs {
+				if other == n {
+					n = strconv.Itoa(len(p.imgs)) + "-" + n
+				}
+			}
+			p.imgs[file] = n
+		}
+		return m.MediaURL + a.TitlePath + n, true
+	}
+	rewrite := func(re *regexp.Regexp) func(string) string {
+		return func(match string) string {
+			sm := re.FindStringSubmatch(match)
+			if u, ok := imgURL(sm[2]); ok {
+				return sm[1] + u + sm[3]
+			}
+			return match
+		}
+	}
+	body = urlFilter.ReplaceAllString(siteURL.ReplaceAllString(body, ""), "$1")
+	body = mdImg.ReplaceAllStringFunc(body, rewrite(mdImg))
+	// Markdown is rendered with its HTML escaped, so `<img>` tags are
+	// written as images of Markdown
+	body = htmlImg.ReplaceAllStringFunc(body, func(tag string) string {
+		attrs := make(map[string]string)
+		for _, am := range htmlAttr.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(am[1])] = html.UnescapeString(am[2] + am[3])
+		}
+		src := attrs["src"]
+		if src == "" {
+			return tag
+		}
+		if u, ok := imgURL(src); ok {
+			src = u
+		}
+		return mdImage(attrs["alt"], src, attrs["title"])
+	})
+	a.Content = strings.TrimSpace(body) + "\n"
+	a.Stats = articles.ContentStats(articles.FormatMarkdown, a.Content)
+
+	// Thumbnail
+	if src := fmString(fm, "image", "thumbnail", "featured_image", "cover"); src != "" {
+		if u, ok := imgURL(src); ok {
+			a.Img.Src = path.Base(u)
+		}
+	}
+	return p, nil
+}
+
+// mdImage returns the Markdown image `![alt](src "title")`, escaping what
+// would end its parts early.
+func mdImage(alt, src, title string) string {
+	alt = mdEscaper.Replace(alt)
+	src = urlEscaper.Replace(src)
+	if title == "" || strings.ContainsAny(title, "()\n") {
+		return "![" + alt + "](" + src + ")"
+	}
+	return "![" + alt + "](" + src + ` "` + title + `")`
+}
+
+// within reports if the file `name` is within the directory `root`.
+func within(root, name string) bool {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	if name, err = filepath.Abs(name); err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func parseDate(s string) (time.Time, bool) {
+	for _, f := range dateFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func fmString(fm map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := fm[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// fmList returns a list, or a single value, of the front matter.
+func fmList(fm map[string]interface{}, key string) []string {
+	switch v := fm[key].(type) {
+	case []string:
+		return v
+	case string:
+		if v != "" {
+			return strings.Fields(v)
+		}
+	}
+	return nil
+}
+
+// FrontMatter splits the YAML (`---`), or TOML (`+++`), front matter from
+// the body of `s`. Only scalars, and lists of them, are read, values being
+// strings, bools or string slices.
+func FrontMatter(s string) (map[string]interface{}, string) {
+	fm := make(map[string]interface{})
+	s = strings.TrimPrefix(s, "\ufeff")
+	var delim, sep string
+	switch {
+	case strings.HasPrefix(s, "---"):
+		delim, sep = "---", ":"
+	case strings.HasPrefix(s, "+++"):
+		delim, sep = "+++", "="
+	default:
+		return fm, s
+	}
+	lines := strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n")
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == delim {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return fm, s
+	}
+
+	var listKey string
+	for _, line := range lines[1:end] {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// YAML list items, of the previous key
+		if strings.HasPrefix(trimmed, "- ") && listKey != "" {
+			list, _ := fm[listKey].([]string)
+			fm[listKey] = append(list, unquote(trimmed[2:]))
+			continue
+		}
+		if line != trimmed {
+			// nested values aren't read
+			continue
+		}
+
+		i := strings.Index(line, sep)
+		if i <= 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		listKey = ""
+		switch {
+		case value == "":
+			listKey = key
+		case strings.HasPrefix(value, "["):
+			list := []string{}
+			for _, item := range strings.Split(strings.Trim(value, "[]"), ",") {
+				if item = unquote(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			fm[key] = list
+		case value == "true" || value == "false":
+			fm[key] = value == "true"
+		default:
+			fm[key] = unquote(value)
+		}
+	}
+	return fm, strings.Join(lines[end+1:], "\n")
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		if s[0] == '"' {
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,285 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package importers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	articles "code.minty.io/wombat-articles"
+)
+
+const (
+	helloPost  = "2013/06/01/hello-world/"
+	secondPost = "2013/06/02/second/"
+	plainPost  = "2013/06/03/plain/"
+)
+
+func importMarkdown(t *testing.T, m *Markdown) *Report {
+	report, err := m.Import("testdata/site/_posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestMarkdownDryRun(t *testing.T) {
+	imagePath, err := ioutil.TempDir("", "markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(imagePath)
+	s := newMemStore()
+	m := NewMarkdown(s, nil, "testdata/site", imagePath, "/media/")
+	m.DryRun = true
+
+	report := importMarkdown(t, m)
+	want := []Entry{
+		{TitlePath: helloPost, Title: "Hello World", Published: true, Imgs: []string{"photo.png"}},
+		{TitlePath: plainPost, Title: "plain", Published: true},
+		{TitlePath: secondPost, Title: "Second Post", Imgs: []string{"diagram.png"}},
+		{Title: filepath.Join("testdata", "site", "_posts", "undated.md"), Skipped: "Missing date"},
+	}
+	if len(report.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(report.Entries), len(want))
+	}
+	for i, e := range report.Entries {
+		w := want[i]
+		if e.TitlePath != w.TitlePath || e.Title != w.Title || e.Published != w.Published ||
+			e.Skipped != w.Skipped || strings.Join(e.Imgs, ",") != strings.Join(w.Imgs, ",") {
+			t.Errorf("entry %d = %+v, want %+v", i, *e, w)
+		}
+	}
+
+	var b bytes.Buffer
+	report.Print(&b)
+	for _, line := range []string{
+		"ok     " + helloPost + " (published, 1 images)\n",
+		"ok     " + secondPost + " (draft, 1 images)\n",
+		"skip   " + want[3].Title + ": Missing date\n",
+		"would import 3 of 4 posts\n",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("report without %q:\n%s", line, b.String())
+		}
+	}
+
+	// Nothing written, or copied
+	if len(s.printed) != 0 {
+		t.Errorf("dry run printed %d articles", len(s.printed))
+	}
+	if files, _ := ioutil.ReadDir(imagePath); len(files) != 0 {
+		t.Errorf("dry run copied %d images", len(files))
+	}
+}
+
+func TestMarkdownImport(t *testing.T) {
+	imagePath, err := ioutil.TempDir("", "markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(imagePath)
+	s := newMemStore()
+	m := NewMarkdown(s, nil, "testdata/site", imagePath, "/media/")
+
+	if report := importMarkdown(t, m); report.Imported() != 3 {
+		t.Errorf("imported %d posts, want 3", report.Imported())
+	}
+
+	// YAML front matter, dated by the file's name
+	a, ok := s.printed[helloPost]
+	if !ok {
+		t.Fatalf("%s wasn't printed", helloPost)
+	}
+	if !a.IsPublished || a.Author != "Ann" || a.Synopsis != "The first post." ||
+		!a.Created.Equal(time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)) ||
+		strings.Join(a.Tags, ",") != "news,intro,blog" {
+		t.Errorf("imported %+v", a)
+	}
+	if !strings.Contains(a.Content, `![A photo](/media/`+helloPost+`photo.png "The photo")`) ||
+		!strings.Contains(a.Content, `![](https://example.com/remote.png)`) {
+		t.Errorf("content links to the images:\n%s", a.Content)
+	}
+	if a.Img.Src != "photo.png" || a.Img.W == 0 || len(a.Imgs) != 1 || a.Imgs[0] != a.Img {
+		t.Errorf("images %+v, %+v, want photo.png as both the thumbnail and an image", a.Img, a.Imgs)
+	}
+
+	// TOML front matter, of a page bundle
+	a, ok = s.printed[secondPost]
+	if !ok {
+		t.Fatalf("%s wasn't printed", secondPost)
+	}
+	if a.IsPublished || a.Title != "Second Post" || strings.Join(a.Tags, ",") != "go,toml" ||
+		!a.Created.Equal(time.Date(2013, 6, 2, 10, 30, 0, 0, time.UTC)) ||
+		!a.Modified.Equal(time.Date(2013, 6, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("imported %+v", a)
+	}
+	if !strings.Contains(a.Content, `![Diagram](/media/`+secondPost+`diagram.png)`) {
+		t.Errorf("content links to the image:\n%s", a.Content)
+	}
+
+	// Rendered, with their `<img>` tags as images rather than text
+	for titlePath, img := range map[string]string{
+		helloPost:  `<img src="https://example.com/remote.png" alt="">`,
+		secondPost: `<img src="/media/` + secondPost + `diagram.png" alt="Diagram">`,
+	} {
+		if html := articles.Markdown(s.printed[titlePath].Content); !strings.Contains(html, img) {
+			t.Errorf("%s rendered without %s:\n%s", titlePath, img, html)
+		}
+	}
+
+	// Images are copied under their article's media
+	for _, name := range []string{helloPost + "photo.png", secondPost + "diagram.png"} {
+		if _, err := os.Stat(filepath.Join(imagePath, filepath.FromSlash(name))); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestMarkdownReimport(t *testing.T) {
+	imagePath, err := ioutil.TempDir("", "markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(imagePath)
+	s := newMemStore()
+	m := NewMarkdown(s, nil, "testdata/site", imagePath, "/media/")
+	importMarkdown(t, m)
+
+	// Importing again changes nothing, even once backends store the nil
+	// tags and images of a post as empty
+	plain := s.printed[plainPost]
+	if plain.Tags != nil || plain.Imgs != nil {
+		t.Fatalf("imported %+v, without tags or images", plain)
+	}
+	plain.Tags, plain.Imgs = []string{}, []articles.Img{}
+	m.Reader = s
+	report := importMarkdown(t, m)
+	if report.Imported() != 0 {
+		t.Errorf("imported %d posts again", report.Imported())
+	}
+	for _, e := range report.Entries[:3] {
+		if e.Skipped != "unchanged" || e.Updated {
+			t.Errorf("%s re-imported as %+v, want it unchanged", e.TitlePath, *e)
+		}
+	}
+
+	// Changes are updated
+	s.printed[helloPost].Content = "Old content.\n"
+	report = importMarkdown(t, m)
+	if e := report.Entries[0]; !e.Updated || e.Skipped != "" || report.Imported() != 1 {
+		t.Errorf("changed post re-imported as %+v", *e)
+	}
+	if a := s.printed[helloPost]; !strings.HasPrefix(a.Content, "Welcome to the blog.") {
+		t.Errorf("content not updated:\n%s", a.Content)
+	}
+	var b bytes.Buffer
+	report.Print(&b)
+	if !strings.Contains(b.String(), "update "+helloPost) {
+		t.Errorf("report:\n%s", b.String())
+	}
+
+	// as are titles, keeping the rest of the article
+	s.printed[helloPost].Title = "Old title"
+	s.printed[helloPost].Pinned = true
+	report = importMarkdown(t, m)
+	if e := report.Entries[0]; !e.Updated || report.Imported() != 1 {
+		t.Errorf("retitled post re-imported as %+v", *e)
+	}
+	if a := s.printed[helloPost]; a.Title != "Hello World" || !a.Pinned || a.Author != "Ann" {
+		t.Errorf("title not updated, or the rest of the article lost: %+v", a)
+	}
+	if report = importMarkdown(t, m); report.Imported() != 0 {
+		t.Errorf("imported %d posts a third time", report.Imported())
+	}
+}
+
+func TestFrontMatter(t *testing.T) {
+	tests := []struct {
+		in, body string
+		fm       map[string]interface{}
+	}{
+		{"---\ntitle: \"A: b\"\ndraft: true\ntags: [x, 'y z']\n---\nBody", "Body",
+			map[string]interface{}{"title": "A: b", "draft": true, "tags": []string{"x", "y z"}}},
+		{"+++\ntitle = 'T'\n# comment\ntags = []\n+++\r\nBody\r\n", "Body\n",
+			map[string]interface{}{"title": "T", "tags": []string{}}},
+		{"\ufeff---\nlist:\n  - a\n  - \"b\"\nnested:\n  key: v\n---\n", "",
+			map[string]interface{}{"list": []string{"a", "b"}}},
+		{"No front matter", "No front matter", map[string]interface{}{}},
+		{"---\nunclosed: x\n", "---\nunclosed: x\n", map[string]interface{}{}},
+	}
+	for _, test := range tests {
+		fm, body := FrontMatter(test.in)
+		if body != test.body {
+			t.Errorf("FrontMatter(%q) body %q, want %q", test.in, body, test.body)
+		}
+		if len(fm) != len(test.fm) {
+			t.Errorf("FrontMatter(%q) = %#v, want %#v", test.in, fm, test.fm)
+			continue
+		}
+		for k, v := range test.fm {
+			if got, want := fmt.Sprintf("%#v", fm[k]), fmt.Sprintf("%#v", v); got != want {
+				t.Errorf("FrontMatter(%q)[%q] = %s, want %s", test.in, k, got, want)
+			}
+		}
+	}
+}
+
+func TestMarkdownOutsideRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	site, imagePath := filepath.Join(dir, "site"), filepath.Join(dir, "media")
+	os.MkdirAll(filepath.Join(site, "_posts"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "secret.png"), []byte("secret"), 0644)
+	ioutil.WriteFile(filepath.Join(site, "_posts", "2013-06-01-a.md"),
+		[]byte("---\ntitle: A\nimage: ../../secret.png\n---\n![a](../../secret.png)\n"), 0644)
+
+	// Images outside of the site are left as they are, rather than copied
+	s := newMemStore()
+	m := NewMarkdown(s, nil, site, imagePath, "/media/")
+	if _, err := m.Import(filepath.Join(site, "_posts")); err != nil {
+		t.Fatal(err)
+	}
+	a, ok := s.printed["2013/06/01/a/"]
+	if !ok {
+		t.Fatal("post wasn't imported")
+	}
+	if a.Img.Src != "" || len(a.Imgs) != 0 || !strings.Contains(a.Content, "![a](../../secret.png)") {
+		t.Errorf("imported %+v", a)
+	}
+	if _, err := os.Stat(imagePath); !os.IsNotExist(err) {
+		t.Errorf("images copied from outside of the site: %v", err)
+	}
+}
+
+func TestMarkdownHTMLImg(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`<IMG alt='a [b]' SRC="https://example.com/a b.png" title="T">`, `![a \[b\]](https://example.com/a%20b.png "T")`},
+		{`<img data-src="x.png" src="https://example.com/y.png" alt="&amp; *">`, `![& \*](https://example.com/y.png)`},
+		{`<img src="https://example.com/y.png" title="(T)" />`, `![](https://example.com/y.png)`},
+		{`<img alt="no source">`, `<img alt="no source">`},
+	}
+	m := NewMarkdown(newMemStore(), nil, "testdata/site", "", "/media/")
+	for _, test := range tests {
+		p, err := m.parse("2013-06-01-a.md", test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(p.article.Content); got != test.want {
+			t.Errorf("%s imported as %s, want %s", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package importers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	articles "code.minty.io/wombat-articles"
+)
+
+const wxrTime = "2006-01-02 15:04:05"
+
+var (
+	// `src` and `href` attributes, of in-content links to attachments
+	attrURL = regexp.MustCompile(`(?i)\b(src|href)\s*=\s*("[^"]*"|'[^']*')`)
+	// the size suffix of resized images, such as `photo-300x200.jpg`
+	sizeSuffix = regexp.MustCompile(`-\d+x\d+(\.[a-zA-Z0-9]+)$`)
+	blockStart = regexp.MustCompile(`(?i)^<(p|div|h[1-6]|ul|ol|li|blockquote|pre|table|figure|hr|iframe)`)
+	paraBreak  = regexp.MustCompile(`\r?\n\s*\r?\n`)
+)
+
+// WXR imports a WordPress eXtended RSS export. Posts become articles, with
+// their attachments fetched into ImagePath as their images, and the links
+// of their content to those rewritten to MediaURL. Pages, and trashed posts,
+// are skipped.
+type WXR struct {
+	Printer   articles.Printer
+	Reader    articles.Reader  // when set, existing articles are skipped
+	Policy    *articles.Policy // when set, content is sanitized
+	Fetcher   Fetcher
+	ImagePath string
+	MediaURL  string
+	DryRun    bool // report without fetching or writing anything
+}
+
+func NewWXR(p articles.Printer, f Fetcher, imagePath, mediaURL string) *WXR {
+	return &WXR{Printer: p, Fetcher: f, ImagePath: imagePath, MediaURL: mediaURL}
+}
+
+// wxrElem is any element, WXR's namespaces changing between versions.
+type wxrElem struct {
+	XMLName  xml.Name
+	Domain   string    `xml:"domain,attr"`
+	Value    string    `xml:",chardata"`
+	Children []wxrElem `xml:",any"`
+}
+
+// field returns the text of the first child named `local`, within a
+// namespace containing `space`.
+func (e *wxrElem) field(space, local string) string {
+	for _, c := range e.Children {
+		if c.XMLName.Local == local && strings.Contains(c.XMLName.Space, space) {
+			return strings.TrimSpace(c.Value)
+		}
+	}
+	return ""
+}
+
+func (e *wxrElem) all(local string) []wxrElem {
+	var all []wxrElem
+	for _, c := range e.Children {
+		if c.XMLName.Local == local {
+			all = append(all, c)
+		}
+	}
+	return all
+}
+
+type wxrPost struct {
+	ID, Parent, Type, Status, Slug string
+	Title, Creator                 string
+	Content, Excerpt               string
+	Created, Modified              time.Time
+	AttachmentURL, Thumbnail       string
+	Tags                           []string
+}
+
+type wxrExport struct {
+	Channel struct {
+		Items []wxrElem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// parseTime parses a `wp:post_date_gmt`, falling back on the local date,
+// WordPress leaving the former zeroed for drafts.
+func parseTime(gmt, local string) time.Time {
+	for _, s := range []string{gmt, local} {
+		if t, err := time.Parse(wxrTime, s); err == nil && t.Year() > 1 {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func parsePost(item *wxrElem) *wxrPost {
+	p := &wxrPost{
+		ID:            item.field("wordpress.org/export", "post_id"),
+		Parent:        item.field("wordpress.org/export", "post_parent"),
+		Type:          item.field("wordpress.org/export", "post_type"),
+		Status:        item.field("wordpress.org/export", "status"),
+		Slug:          item.field("wordpress.org/export", "post_name"),
+		Title:         item.field("", "title"),
+		Creator:       item.field("purl.org/dc", "creator"),
+		Content:       item.field("purl.org/rss/1.0/modules/content", "encoded"),
+		Excerpt:       item.field("/excerpt/", "encoded"),
+		AttachmentURL: item.field("wordpress.org/export", "attachment_url"),
+	}
+	p.Created = parseTime(item.field("wordpress.org/export", "post_date_gmt"),
+		item.field("wordpress.org/export", "post_date"))
+	p.Modified = parseTime(item.field("wordpress.org/export", "post_modified_gmt"),
+		item.field("wordpress.org/export", "post_modified"))
+	for _, c := range item.all("category") {
+		if c.Domain == "post_tag" || c.Domain == "category" {
+			p.Tags = append(p.Tags, strings.TrimSpace(c.Value))
+		}
+	}
+	for _, m := range item.all("postmeta") {
+		if m.field("", "meta_key") == "_thumbnail_id" {
+			p.Thumbnail = m.field("", "meta_value")
+		}
+	}
+	return p
+}
+
+// Import reads the export `r`, importing its posts.
+func (w *WXR) Import(r io.Reader) (*Report, error) {
+	var export wxrExport
+	if err := xml.NewDecoder(r).Decode(&export); err != nil {
+		return nil, err
+	}
+
+	// Attachments, by ID and by their post
+	var posts []*wxrPost
+	attachments := make(map[string]*wxrPost)
+	children := make(map[string][]*wxrPost)
+	for i := range export.Channel.Items {
+		p := parsePost(&export.Channel.Items[i])
+		if p.Type == "attachment" {
+			attachments[p.ID] = p
+			children[p.Parent] = append(children[p.Parent], p)
+		} else {
+			posts = append(posts, p)
+		}
+	}
+
+	report := &Report{DryRun: w.DryRun}
+	for _, p := range posts {
+		report.Entries = append(report.Entries, w.importPost(p, attachments, children[p.ID]))
+	}
+	return report, nil
+}
+
+func (w *WXR) importPost(p *wxrPost, attachments map[string]*wxrPost, children []*wxrPost) *Entry {
+	e := &Entry{Title: p.Title}
+	switch {
+	case p.Type != "post":
+		e.Skipped = fmt.Sprintf("%s, not a post", p.Type)
+		return e
+	case p.Status == "trash" || p.Status == "auto-draft":
+		e.Skipped = p.Status
+		return e
+	case p.Created.IsZero():
+		e.Skipped = "missing date"
+		return e
+	}
+
+	slug := Slug(p.Slug)
+	if slug == "" {
+		slug = Slug(p.Title)
+	}
+	if slug == "" {
+		slug = "post-" + p.ID
+	}
+	a := &articles.Article{
+		Printer:       w.Printer,
+		TitlePath:     articles.TitlePath(p.Created, slug),
+		Title:         p.Title,
+		Author:        p.Creator,
+		Synopsis:      p.Excerpt,
+		IsPublished:   p.Status == "publish",
+		Created:       p.Created,
+		Modified:      p.Modified,
+		Tags:          p.Tags,
+		ContentFormat: articles.FormatHTML,
+	}
+	if a.Modified.IsZero() {
+		a.Modified = a.Created
+	}
+	e.TitlePath, e.Published = a.TitlePath, a.IsPublished
+
+	if w.Reader != nil {
+		if _, err := w.Reader.ByTitlePath(a.TitlePath, true); err == nil {
+			e.Skipped = "already exists"
+			return e
+		}
+	}
+
+	// Attachments of the post, its thumbnail first, along with those of other
+	// posts, or resized, within its content, by URL
+	var order []*wxrPost
+	byURL := make(map[string]*wxrPost)
+	add := func(u string, c *wxrPost) {
+		if _, ok := byURL[u]; !ok {
+			byURL[u] = c
+			order = append(order, c)
+		}
+	}
+	thumb := attachments[p.Thumbnail]
+	if thumb != nil {
+		add(thumb.AttachmentURL, thumb)
+	}
+	for _, c := range children {
+		add(c.AttachmentURL, c)
+	}
+	content := autop(p.Content)
+	for _, m := range attrURL.FindAllStringSubmatch(content, -1) {
+		u := m[2][1 : len(m[2])-1]
+		for _, c := range attachments {
+			if c.AttachmentURL == u || c.AttachmentURL == sizeSuffix.ReplaceAllString(u, "$1") {
+				add(u, c)
+			}
+		}
+	}
+
+	// Fetch each attachment once, as the article's images
+	saved := make(map[string]string)
+	names := make(map[string]bool)
+	for _, c := range order {
+		if _, ok := saved[c.AttachmentURL]; ok || c.AttachmentURL == "" {
+			continue
+		}
+		// named as the file of its URL, without any query or fragment
+		name := c.AttachmentURL
+		if u, err := url.Parse(c.AttachmentURL); err == nil {
+			name = u.Path
+		}
+		if name = path.Base(name); name == "." || name == "/" {
+			name = "attachment-" + c.ID
+		}
+		if names[name] {
+			name = c.ID + "-" + name
+		}
+		img := articles.Img{Src: name, Alt: c.Title}
+		if !w.DryRun {
+			fetched, err := saveImg(w.Fetcher, w.ImagePath, a.TitlePath, name, c.AttachmentURL)
+			if err != nil {
+				e.Warnings = append(e.Warnings, err.Error())
+				saved[c.AttachmentURL] = ""
+				continue
+			}
+			img.W, img.H = fetched.W, fetched.H
+		}
+		saved[c.AttachmentURL], names[name] = name, true
+		if c == thumb {
+			a.Img = img
+		}
+		a.Imgs = append(a.Imgs, img)
+		e.Imgs = append(e.Imgs, name)
+	}
+
+	// Content, linking to the images rather than the attachments
+	a.Content = attrURL.ReplaceAllStringFunc(content, func(attr string) string {
+		m := attrURL.FindStringSubmatch(attr)
+		u := m[2][1 : len(m[2])-1]
+		if c, ok := byURL[u]; ok {
+			if name := saved[c.AttachmentURL]; name != "" {
+				return m[1] + `="` + w.MediaURL + a.TitlePath + name + `"`
+			}
+		}
+		return attr
+	})
+	if w.Policy != nil {
+		var stripped []articles.Stripped
+		if a.Content, stripped = w.Policy.Sanitize(a.Content); len(stripped) > 0 {
+			e.Warnings = append(e.Warnings, fmt.Sprintf("%d disallowed tags, or attributes, stripped", len(stripped)))
+		}
+	}
+	a.Stats = articles.NewStats(a.Content)
+
+	if !w.DryRun {
+		if err := a.Print(); err != nil {
+			e.Skipped = err.Error()
+		}
+	}
+	return e
+}
+
+// autop wraps the paragraphs of WordPress content, separated by blank lines,
+// in `<p>`, as WordPress does when displaying it.
+func autop(content string) string {
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+	var out []string
+	for _, para := range paraBreak.Split(strings.TrimSpace(content), -1) {
+		if para = strings.TrimSpace(para); para == "" {
+			continue
+		}
+		// A block closed on its first line, such as a heading, is followed
+		// by a paragraph
+		for m := blockStart.FindStringSubmatch(para); m != nil; m = blockStart.FindStringSubmatch(para) {
+			first := strings.SplitN(para, "\n", 2)
+			name := strings.ToLower(m[1])
+			if len(first) < 2 || name != "hr" && !strings.Contains(strings.ToLower(first[0]), "</"+name+">") {
+				break
+			}
+			out = append(out, strings.TrimSpace(first[0]))
+			para = strings.TrimSpace(first[1])
+		}
+		switch {
+		case para == "":
+		case blockStart.MatchString(para):
+			out = append(out, para)
+		default:
+			out = append(out, "<p>"+strings.Replace(para, "\n", "<br>\n", -1)+"</p>")
+		}
+	}
+	return strings.Join(out, "\n")
+}
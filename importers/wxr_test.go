@@ -0,0 +1,223 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package importers
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	articles "code.minty.io/wombat-articles"
+)
+
+// memStore keeps the articles printed to it in memory. Only the methods
+// used by imports are implemented.
+type memStore struct {
+	articles.Printer
+	articles.Reader
+	printed map[string]*articles.Article
+}
+
+func newMemStore() *memStore {
+	return &memStore{printed: make(map[string]*articles.Article)}
+}
+
+func (s *memStore) Print(article interface{}) error {
+	a := *article.(*articles.Article)
+	if _, ok := s.printed[a.TitlePath]; ok {
+		return errors.New("Duplicate titlePath")
+	}
+	s.printed[a.TitlePath] = &a
+	return nil
+}
+
+func (s *memStore) ByTitlePath(titlePath string, unPublished bool) (interface{}, error) {
+	a, ok := s.printed[titlePath]
+	if !ok {
+		return nil, errors.New("Not found")
+	}
+	return a, nil
+}
+
+func (s *memStore) Replace(titlePath string, article interface{}) error {
+	if _, ok := s.printed[titlePath]; !ok {
+		return errors.New("Not found")
+	}
+	a := *article.(*articles.Article)
+	s.printed[titlePath] = &a
+	return nil
+}
+
+func (s *memStore) UpdateContent(titlePath, content string, stats articles.Stats, modified time.Time) error {
+	a, ok := s.printed[titlePath]
+	if !ok {
+		return errors.New("Not found")
+	}
+	a.Content, a.Stats, a.Modified = content, stats, modified
+	return nil
+}
+
+func importWXR(t *testing.T, w *WXR, name string) *Report {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	report, err := w.Import(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// uploads are the files attached to posts of testdata/wordpress.xml.
+var uploads = DirFetcher{Dir: "testdata/uploads", Prefix: "/wp-content/uploads/"}
+
+const helloWorld = "2013/06/01/hello-world/"
+
+func TestWXRDryRun(t *testing.T) {
+	imagePath, err := ioutil.TempDir("", "wxr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(imagePath)
+	s := newMemStore()
+	w := NewWXR(s, uploads, imagePath, "/media/")
+	w.DryRun = true
+
+	report := importWXR(t, w, "testdata/wordpress.xml")
+	want := []Entry{
+		{TitlePath: helloWorld, Title: "Hello World", Published: true, Imgs: []string{"photo.png"}},
+		{TitlePath: "2013/06/05/work-in-progress/", Title: "Work In Progress"},
+		{Title: "About", Skipped: "page, not a post"},
+		{Title: "Deleted", Skipped: "trash"},
+	}
+	if len(report.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(report.Entries), len(want))
+	}
+	for i, e := range report.Entries {
+		w := want[i]
+		if e.TitlePath != w.TitlePath || e.Title != w.Title || e.Published != w.Published ||
+			e.Skipped != w.Skipped || strings.Join(e.Imgs, ",") != strings.Join(w.Imgs, ",") {
+			t.Errorf("entry %d = %+v, want %+v", i, *e, w)
+		}
+	}
+
+	var b bytes.Buffer
+	report.Print(&b)
+	if !strings.Contains(b.String(), "would import 2 of 4 posts") {
+		t.Errorf("report:\n%s", b.String())
+	}
+
+	// Nothing written, or fetched
+	if len(s.printed) != 0 {
+		t.Errorf("dry run printed %d articles", len(s.printed))
+	}
+	if files, _ := ioutil.ReadDir(imagePath); len(files) != 0 {
+		t.Errorf("dry run fetched %d attachments", len(files))
+	}
+}
+
+func TestWXRImport(t *testing.T) {
+	imagePath, err := ioutil.TempDir("", "wxr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(imagePath)
+	s := newMemStore()
+	w := NewWXR(s, uploads, imagePath, "/media/")
+
+	if report := importWXR(t, w, "testdata/wordpress.xml"); report.Imported() != 2 {
+		t.Errorf("imported %d posts, want 2", report.Imported())
+	}
+	a, ok := s.printed[helloWorld]
+	if !ok {
+		t.Fatalf("%s wasn't printed", helloWorld)
+	}
+	if !a.IsPublished || a.Author != "admin" || a.Synopsis != "The first post." ||
+		strings.Join(a.Tags, ",") != "News,intro" {
+		t.Errorf("imported %+v", a)
+	}
+	if draft := s.printed["2013/06/05/work-in-progress/"]; draft == nil || draft.IsPublished {
+		t.Errorf("draft imported as %+v", draft)
+	}
+
+	// The attachment, and its resized copy, link to the article's image
+	src := "/media/" + helloWorld + "photo.png"
+	if !strings.Contains(a.Content, `href="`+src+`"`) || !strings.Contains(a.Content, `src="`+src+`"`) {
+		t.Errorf("content links to the attachments:\n%s", a.Content)
+	}
+	if strings.Contains(a.Content, "wp-content") {
+		t.Errorf("content still links to WordPress:\n%s", a.Content)
+	}
+	if a.Img.Src != "photo.png" || len(a.Imgs) != 1 || a.Imgs[0].Src != "photo.png" {
+		t.Errorf("images %+v, %+v, want photo.png as both the thumbnail and an image", a.Img, a.Imgs)
+	}
+	if _, err := os.Stat(filepath.Join(imagePath, filepath.FromSlash(helloWorld), "photo.png")); err != nil {
+		t.Error(err)
+	}
+	if !strings.HasPrefix(a.Content, "<p>Welcome to the blog.</p>") || !strings.Contains(a.Content, "<h2>More</h2>\n<p>And some more text.</p>") {
+		t.Errorf("paragraphs not wrapped:\n%s", a.Content)
+	}
+}
+
+func TestWXRReimport(t *testing.T) {
+	imagePath, err := ioutil.TempDir("", "wxr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(imagePath)
+	s := newMemStore()
+	w := NewWXR(s, uploads, imagePath, "/media/")
+	importWXR(t, w, "testdata/wordpress.xml")
+	imported := s.printed[helloWorld]
+
+	// Existing articles are skipped, rather than duplicated
+	w.Reader = s
+	report := importWXR(t, w, "testdata/wordpress.xml")
+	if report.Imported() != 0 {
+		t.Errorf("imported %d posts again", report.Imported())
+	}
+	for _, e := range report.Entries[:2] {
+		if e.Skipped != "already exists" {
+			t.Errorf("%s skipped as %q, want it already existing", e.TitlePath, e.Skipped)
+		}
+	}
+	if len(s.printed) != 2 || s.printed[helloWorld] != imported {
+		t.Errorf("re-import changed the articles")
+	}
+}
+
+func TestWXRAttachmentName(t *testing.T) {
+	imagePath, err := ioutil.TempDir("", "wxr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(imagePath)
+	s := newMemStore()
+	w := NewWXR(s, uploads, imagePath, "/media/")
+
+	b, err := ioutil.ReadFile("testdata/wordpress.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	export := strings.Replace(string(b), "<wp:attachment_url>http://blog.example.com/wp-content/uploads/2013/06/photo.png",
+		"<wp:attachment_url>http://blog.example.com/wp-content/uploads/2013/06/photo.png?ver=2#top", 1)
+	report, err := w.Import(strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := report.Entries[0]; len(e.Imgs) != 1 || e.Imgs[0] != "photo.png" {
+		t.Errorf("images %v, want photo.png without its query", e.Imgs)
+	}
+	if _, err := os.Stat(filepath.Join(imagePath, filepath.FromSlash(helloWorld), "photo.png")); err != nil {
+		t.Error(err)
+	}
+}
@@ -4,3 +4,7 @@ go install code.minty.io/wombat-articles
 go install code.minty.io/wombat-articles/backends
 go install code.minty.io/wombat-articles/backends/mongo
 go install code.minty.io/wombat-articles/handlers
+go install code.minty.io/wombat-articles/importers
+go install code.minty.io/wombat-articles/cmd/articles-backup
+go install code.minty.io/wombat-articles/cmd/articles-export
+go install code.minty.io/wombat-articles/cmd/articles-import
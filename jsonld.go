@@ -0,0 +1,131 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"encoding/json"
+	"html/template"
+	"time"
+)
+
+// Types of schema.org postings.
+const (
+	BlogPosting = "BlogPosting"
+	NewsArticle = "NewsArticle"
+)
+
+// LD is a schema.org JSON-LD object.
+type LD map[string]interface{}
+
+// Publisher is the organization publishing the articles.
+type Publisher struct {
+	Name string
+	URL  string
+	Logo string // absolute URL
+}
+
+func (p Publisher) ld() LD {
+	o := LD{"@type": "Organization", "name": p.Name}
+	if p.URL != "" {
+		o["url"] = p.URL
+	}
+	if p.Logo != "" {
+		o["logo"] = LD{"@type": "ImageObject", "url": p.Logo}
+	}
+	return o
+}
+
+// Crumb is an entry of a BreadcrumbList.
+type Crumb struct {
+	Name string
+	URL  string
+}
+
+// Posting returns the `kind` of posting, BlogPosting or NewsArticle, of `a`
+// at the absolute URL `url`, its images being under the absolute `mediaURL`.
+func Posting(kind string, a *Article, url, mediaURL string, publisher Publisher) LD {
+	o := LD{
+		"@context":         "https://schema.org",
+		"@type":            kind,
+		"headline":         a.Title,
+		"url":              url,
+		"mainEntityOfPage": LD{"@type": "WebPage", "@id": url},
+		"datePublished":    a.Created.Format(time.RFC3339),
+		"publisher":        publisher.ld(),
+		"inLanguage":       a.Localized(),
+	}
+	if !a.Modified.IsZero() {
+		o["dateModified"] = a.Modified.Format(time.RFC3339)
+	}
+	if d := a.Summary(); d != "" {
+		o["description"] = d
+	}
+	if a.WordCount > 0 {
+		o["wordCount"] = a.WordCount
+	}
+	if len(a.Tags) > 0 {
+		o["keywords"] = a.Tags
+	}
+
+	// Author, or the publisher for articles without one
+	if a.Author != "" {
+		o["author"] = LD{"@type": "Person", "name": a.Author}
+	} else {
+		o["author"] = publisher.ld()
+	}
+
+	// The thumbnail, then the other images
+	var imgs []string
+	if a.Img.Src != "" {
+		imgs = append(imgs, mediaURL+a.TitlePath+a.Img.Src)
+	}
+	for _, img := range a.Imgs {
+		if img.Src != a.Img.Src {
+			imgs = append(imgs, mediaURL+a.TitlePath+img.Src)
+		}
+	}
+	if len(imgs) > 0 {
+		o["image"] = imgs
+	}
+	return o
+}
+
+// ItemList returns the list of `list`, whose URLs are under the absolute
+// `siteURL`, the articles' base path included.
+func ItemList(list []*Article, siteURL string) LD {
+	items := []LD{}
+	for i, a := range list {
+		items = append(items, LD{
+			"@type":    "ListItem",
+			"position": i + 1,
+			"url":      siteURL + "/" + a.TitlePath,
+			"name":     a.Title,
+		})
+	}
+	return LD{"@context": "https://schema.org", "@type": "ItemList", "itemListElement": items}
+}
+
+// BreadcrumbList returns the trail of `crumbs`, from the top.
+func BreadcrumbList(crumbs ...Crumb) LD {
+	items := []LD{}
+	for i, c := range crumbs {
+		items = append(items, LD{"@type": "ListItem", "position": i + 1, "name": c.Name, "item": c.URL})
+	}
+	return LD{"@context": "https://schema.org", "@type": "BreadcrumbList", "itemListElement": items}
+}
+
+// JSONLD encodes `objects` for a `<script type="application/ld+json">`.
+func JSONLD(objects ...LD) template.JS {
+	var v interface{} = objects
+	if len(objects) == 1 {
+		v = objects[0]
+	}
+	// `<`, `>` and `&` are escaped, so the script can't be closed early
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return template.JS(b)
+}
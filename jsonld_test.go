@@ -0,0 +1,101 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPosting(t *testing.T) {
+	publisher := Publisher{Name: "Site"}
+	tests := []struct {
+		name, kind string
+		a          *Article
+		publisher  Publisher
+		out        string
+	}{
+		{"minimal", BlogPosting, &Article{TitlePath: "2013/06/01/a/", Title: "A",
+			Created: time.Date(2013, 6, 1, 10, 0, 0, 0, time.UTC)}, publisher,
+			`{"@context":"https://schema.org","@type":"BlogPosting",` +
+				`"author":{"@type":"Organization","name":"Site"},"datePublished":"2013-06-01T10:00:00Z",` +
+				`"headline":"A","inLanguage":"en","mainEntityOfPage":{"@id":"http://example.com/2013/06/01/a/","@type":"WebPage"},` +
+				`"publisher":{"@type":"Organization","name":"Site"},"url":"http://example.com/2013/06/01/a/"}`},
+
+		{"full", NewsArticle, &Article{TitlePath: "2013/06/01/a/", Title: "A", Synopsis: "About A",
+			Author: "Ann", WordCount: 120, Tags: []string{"x", "y"},
+			Img:      Img{Src: "a.png"},
+			Imgs:     []Img{{Src: "a.png"}, {Src: "b.png"}},
+			Created:  time.Date(2013, 6, 1, 10, 0, 0, 0, time.UTC),
+			Modified: time.Date(2013, 6, 2, 10, 0, 0, 0, time.UTC)},
+			Publisher{Name: "Site", URL: "http://example.com/", Logo: "http://example.com/logo.png"},
+			`{"@context":"https://schema.org","@type":"NewsArticle","author":{"@type":"Person","name":"Ann"},` +
+				`"dateModified":"2013-06-02T10:00:00Z","datePublished":"2013-06-01T10:00:00Z","description":"About A",` +
+				`"headline":"A","image":["http://example.com/media/2013/06/01/a/a.png","http://example.com/media/2013/06/01/a/b.png"],` +
+				`"inLanguage":"en","keywords":["x","y"],"mainEntityOfPage":{"@id":"http://example.com/2013/06/01/a/","@type":"WebPage"},` +
+				`"publisher":{"@type":"Organization","logo":{"@type":"ImageObject","url":"http://example.com/logo.png"},` +
+				`"name":"Site","url":"http://example.com/"},"url":"http://example.com/2013/06/01/a/","wordCount":120}`},
+
+		// Script can't be closed, or markup started, within the JSON
+		{"escaped", BlogPosting, &Article{TitlePath: "2013/06/01/a/", Title: "</script><b>&",
+			Created: time.Date(2013, 6, 1, 10, 0, 0, 0, time.UTC)}, publisher,
+			`{"@context":"https://schema.org","@type":"BlogPosting",` +
+				`"author":{"@type":"Organization","name":"Site"},"datePublished":"2013-06-01T10:00:00Z",` +
+				`"headline":"\u003c/script\u003e\u003cb\u003e\u0026","inLanguage":"en",` +
+				`"mainEntityOfPage":{"@id":"http://example.com/2013/06/01/a/","@type":"WebPage"},` +
+				`"publisher":{"@type":"Organization","name":"Site"},"url":"http://example.com/2013/06/01/a/"}`},
+	}
+	for _, test := range tests {
+		o := Posting(test.kind, test.a, "http://example.com/"+test.a.TitlePath, "http://example.com/media/", test.publisher)
+		if got := string(JSONLD(o)); got != test.out {
+			t.Errorf("%s: Posting\n got %s\nwant %s", test.name, got, test.out)
+		}
+	}
+}
+
+func TestItemList(t *testing.T) {
+	tests := []struct {
+		list []*Article
+		out  string
+	}{
+		{nil, `{"@context":"https://schema.org","@type":"ItemList","itemListElement":[]}`},
+		{[]*Article{{TitlePath: "2013/06/02/b/", Title: "B"}, {TitlePath: "2013/06/01/a/", Title: "A"}},
+			`{"@context":"https://schema.org","@type":"ItemList","itemListElement":[` +
+				`{"@type":"ListItem","name":"B","position":1,"url":"http://example.com/articles/2013/06/02/b/"},` +
+				`{"@type":"ListItem","name":"A","position":2,"url":"http://example.com/articles/2013/06/01/a/"}]}`},
+	}
+	for _, test := range tests {
+		if got := string(JSONLD(ItemList(test.list, "http://example.com/articles"))); got != test.out {
+			t.Errorf("ItemList\n got %s\nwant %s", got, test.out)
+		}
+	}
+}
+
+func TestBreadcrumbList(t *testing.T) {
+	got := string(JSONLD(BreadcrumbList(Crumb{"Home", "http://example.com/"}, Crumb{"A", "http://example.com/a/"})))
+	want := `{"@context":"https://schema.org","@type":"BreadcrumbList","itemListElement":[` +
+		`{"@type":"ListItem","item":"http://example.com/","name":"Home","position":1},` +
+		`{"@type":"ListItem","item":"http://example.com/a/","name":"A","position":2}]}`
+	if got != want {
+		t.Errorf("BreadcrumbList\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestJSONLD(t *testing.T) {
+	tests := []struct {
+		objects []LD
+		out     string
+	}{
+		{[]LD{{"a": 1}}, `{"a":1}`},
+		{[]LD{{"a": 1}, {"b": 2}}, `[{"a":1},{"b":2}]`},
+		{nil, `null`},
+		{[]LD{{"bad": func() {}}}, ``},
+	}
+	for _, test := range tests {
+		if got := string(JSONLD(test.objects...)); got != test.out {
+			t.Errorf("JSONLD(%v) = %s, want %s", test.objects, got, test.out)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LinkScheme is the scheme of internal links to other articles, by their
+// titlePath, such as `article:2013/06/01/Some-Title/#section`.
+const LinkScheme = "article:"
+
+// Reasons for a link to be broken.
+const (
+	LinkMissing     = "missing article"
+	LinkUnpublished = "unpublished article"
+	ImgMissing      = "missing image"
+)
+
+var titlePathRe = regexp.MustCompile(`^\d{4}/\d{2}/\d{2}/[a-zA-Z0-9-]+/$`)
+
+// ValidTitlePath reports if `titlePath` is of the form of an article's.
+func ValidTitlePath(titlePath string) bool {
+	return titlePathRe.MatchString(titlePath)
+}
+
+// BrokenLink is a link, or image, of an article that doesn't resolve.
+type BrokenLink struct {
+	TitlePath string `json:"titlePath"`
+	URL       string `json:"url"`
+	Reason    string `json:"reason"`
+}
+
+// Links resolves internal links when showing articles, and finds the broken
+// links of articles.
+type Links struct {
+	Reader      Reader
+	ItoArticle  func(o interface{}) *Article
+	ItoArticles func(o interface{}) []*Article
+	BasePath    string
+	MediaURL    string
+	ImagePath   string
+}
+
+func NewLinks(r Reader, basePath, mediaURL, imagePath string) *Links {
+	return &Links{r, ToArticle, ToArticles, basePath, mediaURL, imagePath}
+}
+
+// ToArticle converts the article returned by `Reader.ByTitlePath`, returning
+// nil for types it doesn't know about.
+func ToArticle(o interface{}) *Article {
+	switch a := o.(type) {
+	case *Article:
+		return a
+	case Article:
+		return &a
+	}
+	return nil
+}
+
+// URL is the URL of the article `titlePath`.
+func (l *Links) URL(titlePath string) string {
+	return l.BasePath + "/" + titlePath
+}
+
+// internal returns the titlePath, and fragment, of a link to an article.
+func (l *Links) internal(href string) (titlePath, fragment string, ok bool) {
+	if strings.HasPrefix(href, LinkScheme) {
+		titlePath = strings.TrimPrefix(href, LinkScheme)
+	} else if u, err := url.Parse(href); err == nil && strings.HasPrefix(u.Path, l.BasePath+"/") {
+		titlePath = strings.TrimPrefix(u.Path, l.BasePath+"/")
+	} else {
+		return
+	}
+	if i := strings.IndexByte(titlePath, '#'); i >= 0 {
+		titlePath, fragment = titlePath[:i], titlePath[i:]
+	}
+	return titlePath, fragment, titlePathRe.MatchString(titlePath)
+}
+
+// status returns why a link to `titlePath` is broken, if it is, looking up
+// each article only once amongst the `statuses` already found.
+func (l *Links) status(statuses map[string]string, titlePath string) string {
+	if reason, ok := statuses[titlePath]; ok {
+		return reason
+	}
+	reason := LinkMissing
+	if o, err := l.Reader.ByTitlePath(titlePath, true); err == nil {
+		if a := l.ItoArticle(o); a != nil {
+			reason = articleStatus(a)
+		}
+	}
+	statuses[titlePath] = reason
+	return reason
+}
+
+// articleStatus returns why a link to `a` is broken, if it is.
+func articleStatus(a *Article) string {
+	if !a.IsPublished {
+		return LinkUnpublished
+	}
+	return ""
+}
+
+// Filter resolves the `article:` links of rendered content into URLs, as a
+// view of a Pipeline, so they follow the articles they link to being
+// published, or not. Links to articles that aren't published lose their
+// `href`, and are classed as a `broken-link`.
+func (l *Links) Filter(a *Article, s string) string {
+	tokens := tokenize(s)
+	statuses := make(map[string]string)
+	for i, t := range tokens {
+		href, ok := t.Attr("href")
+		if t.Type != startToken || t.Name != "a" || !ok || !strings.HasPrefix(href, LinkScheme) {
+			continue
+		}
+
+		titlePath, fragment, ok := l.internal(href)
+		if ok && l.status(statuses, titlePath) == "" {
+			tokens[i].SetAttr("href", l.URL(titlePath)+fragment)
+			continue
+		}
+		attrs := tokens[i].Attrs[:0]
+		for _, at := range t.Attrs {
+			if at.Key != "href" {
+				attrs = append(attrs, at)
+			}
+		}
+		tokens[i].Attrs = attrs
+		tokens[i].SetAttr("class", "broken-link")
+	}
+	return render(tokens)
+}
+
+// imgExists reports if the media file of the URL `src` exists.
+func (l *Links) imgExists(src string) bool {
+	_, err := os.Stat(filepath.Join(l.ImagePath, filepath.FromSlash(src)))
+	return err == nil
+}
+
+// Check returns the broken links of `a`, within its content `s`, rendered
+// from its format only, and amongst its images.
+func (l *Links) Check(a *Article, s string) []BrokenLink {
+	return l.check(a, s, make(map[string]string))
+}
+
+func (l *Links) check(a *Article, s string, statuses map[string]string) []BrokenLink {
+	var broken []BrokenLink
+	for _, t := range tokenize(s) {
+		if t.Type != startToken {
+			continue
+		}
+		if href, ok := t.Attr("href"); ok && t.Name == "a" {
+			if titlePath, _, ok := l.internal(href); ok {
+				if reason := l.status(statuses, titlePath); reason != "" {
+					broken = append(broken, BrokenLink{a.TitlePath, href, reason})
+				}
+			} else if strings.HasPrefix(href, LinkScheme) {
+				broken = append(broken, BrokenLink{a.TitlePath, href, LinkMissing})
+			}
+		}
+		if src, ok := t.Attr("src"); ok && t.Name == "img" && strings.HasPrefix(src, l.MediaURL) {
+			if !l.imgExists(strings.TrimPrefix(src, l.MediaURL)) {
+				broken = append(broken, BrokenLink{a.TitlePath, src, ImgMissing})
+			}
+		}
+	}
+
+	// The thumbnail, and images, uploaded for the article
+	imgs := a.Imgs
+	if a.Img.Src != "" {
+		imgs = append([]Img{a.Img}, imgs...)
+	}
+	for _, img := range imgs {
+		if !l.imgExists(a.TitlePath + img.Src) {
+			broken = append(broken, BrokenLink{a.TitlePath, l.MediaURL + a.TitlePath + img.Src, ImgMissing})
+		}
+	}
+	return broken
+}
+
+// Report scans every article, returning all of their broken links. The
+// articles linked to are those scanned, rather than each being looked up.
+func (l *Links) Report(p *Pipeline) ([]BrokenLink, error) {
+	broken := []BrokenLink{}
+	var all []*Article
+	statuses := make(map[string]string)
+	q := Query{Limit: 100, Sort: "-" + SortCreated, Published: AnyPublishState}
+	for ; ; q.Page++ {
+		o, err := l.Reader.Find(q)
+		if err != nil {
+			return broken, err
+		}
+		list := l.ItoArticles(o)
+		for _, a := range list {
+			statuses[a.TitlePath] = articleStatus(a)
+		}
+		all = append(all, list...)
+		if len(list) < q.Limit {
+			break
+		}
+	}
+
+	for _, a := range all {
+		broken = append(broken, l.check(a, p.Convert(a), statuses)...)
+	}
+	return broken, nil
+}
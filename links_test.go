@@ -0,0 +1,94 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLinksFilter(t *testing.T) {
+	b := newMemBackend(
+		&Article{TitlePath: "2013/06/01/a/", Title: "A", IsPublished: true},
+		&Article{TitlePath: "2013/06/02/b/", Title: "B"})
+	l := NewLinks(b, "/articles", "/media/", "")
+
+	tests := []struct {
+		name, in, out string
+	}{
+		{"published", `<a href="article:2013/06/01/a/">a</a>`, `<a href="/articles/2013/06/01/a/">a</a>`},
+		{"fragment", `<a href="article:2013/06/01/a/#s">a</a>`, `<a href="/articles/2013/06/01/a/#s">a</a>`},
+		{"unpublished", `<a title="t" href="article:2013/06/02/b/">b</a>`, `<a title="t" class="broken-link">b</a>`},
+		{"missing", `<a href="article:2013/06/03/c/" class="x">c</a>`, `<a class="broken-link">c</a>`},
+		{"invalid", `<a href="article:c">c</a>`, `<a class="broken-link">c</a>`},
+
+		// Only `article:` links are resolved
+		{"path", `<a href="/articles/2013/06/02/b/">b</a>`, `<a href="/articles/2013/06/02/b/">b</a>`},
+		{"external", `<a href="http://example.com/">x</a>`, `<a href="http://example.com/">x</a>`},
+		{"image", `<img src="article:2013/06/01/a/">`, `<img src="article:2013/06/01/a/">`},
+	}
+	for _, test := range tests {
+		if got := l.Filter(nil, test.in); got != test.out {
+			t.Errorf("%s: Filter(%q)\n got %q\nwant %q", test.name, test.in, got, test.out)
+		}
+	}
+
+	// Each article linked to is looked up once
+	b.lookups = 0
+	l.Filter(nil, `<a href="article:2013/06/01/a/">1</a><a href="article:2013/06/02/b/">2</a>`+
+		`<a href="article:2013/06/01/a/#x">3</a><a href="article:2013/06/02/b/">4</a>`)
+	if b.lookups != 2 {
+		t.Errorf("%d lookups of 2 articles", b.lookups)
+	}
+}
+
+func TestLinksReport(t *testing.T) {
+	imagePath, err := ioutil.TempDir("", "links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(imagePath)
+	dir := filepath.Join(imagePath, "2013", "06", "01", "a")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.png"), []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := newMemBackend(
+		&Article{TitlePath: "2013/06/01/a/", IsPublished: true, Created: time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC),
+			Img: Img{Src: "a.png"}, Imgs: []Img{{Src: "gone.png"}},
+			Content: `<a href="article:2013/06/02/b/">b</a><a href="/articles/2013/06/03/c/">c</a>` +
+				`<img src="/media/2013/06/01/a/a.png"><img src="/media/2013/06/01/a/x.png"><img src="http://example.com/x.png">`},
+		&Article{TitlePath: "2013/06/02/b/", Created: time.Date(2013, 6, 2, 0, 0, 0, 0, time.UTC),
+			Content: `<a href="article:2013/06/01/a/">a</a><a href="article:2013/06/02/b/#top">b</a><a href="article:nope">x</a>`})
+	l := NewLinks(b, "/articles", "/media/", imagePath)
+
+	broken, err := l.Report(NewPipeline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BrokenLink{
+		{"2013/06/02/b/", "article:2013/06/02/b/#top", LinkUnpublished},
+		{"2013/06/02/b/", "article:nope", LinkMissing},
+		{"2013/06/01/a/", "article:2013/06/02/b/", LinkUnpublished},
+		{"2013/06/01/a/", "/articles/2013/06/03/c/", LinkMissing},
+		{"2013/06/01/a/", "/media/2013/06/01/a/x.png", ImgMissing},
+		{"2013/06/01/a/", "/media/2013/06/01/a/gone.png", ImgMissing},
+	}
+	if !reflect.DeepEqual(broken, want) {
+		t.Errorf("broken links\n%v\nwant\n%v", broken, want)
+	}
+
+	// Only the article that isn't amongst those scanned is looked up
+	if b.lookups != 1 {
+		t.Errorf("%d lookups, want 1", b.lookups)
+	}
+}
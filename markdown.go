@@ -0,0 +1,423 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	mdHeading  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdRule     = regexp.MustCompile(`^ {0,3}((?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdFence    = regexp.MustCompile("^ {0,3}(```+|~~~+)[ \t]*([^ \t`]*)")
+	mdItem     = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])([ \t]+|$)`)
+	mdSetext   = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	mdQuote    = regexp.MustCompile(`^ {0,3}> ?`)
+	mdAutolink = regexp.MustCompile(`^<((?:https?://|mailto:)[^ <>]+)>`)
+)
+
+// Markdown converts the markdown `s` into HTML. Raw HTML isn't supported
+// and is escaped, as are links with unsafe schemes.
+func Markdown(s string) string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	s = strings.Replace(s, "\t", "    ", -1)
+
+	var b bytes.Buffer
+	mdBlocks(&b, strings.Split(s, "\n"), false)
+	return b.String()
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// indent returns the number of leading spaces of `line`.
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// unindent removes up to `n` leading spaces from every line.
+func unindent(lines []string, n int) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		if d := indent(l); d < n {
+			out[i] = l[d:]
+		} else {
+			out[i] = l[n:]
+		}
+	}
+	return out
+}
+
+// startsBlock reports if `line` interrupts a paragraph.
+func startsBlock(line string) bool {
+	return mdHeading.MatchString(line) || mdRule.MatchString(line) ||
+		mdFence.MatchString(line) || mdQuote.MatchString(line) || mdItem.MatchString(line)
+}
+
+// mdBlocks renders the block structure of `lines`, leaving paragraphs
+// unwrapped when `tight`, as within the items of a tight list.
+func mdBlocks(b *bytes.Buffer, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+
+		case mdFence.MatchString(line):
+			m := mdFence.FindStringSubmatch(line)
+			fence, lang := m[1], m[2]
+			i++
+			start := i
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+				i++
+			}
+			code := strings.Join(lines[start:i], "\n")
+			i++
+			b.WriteString("<pre><code")
+			if lang != "" {
+				b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+			}
+			b.WriteString(">" + html.EscapeString(code))
+			if code != "" {
+				b.WriteString("\n")
+			}
+			b.WriteString("</code></pre>\n")
+
+		case mdHeading.MatchString(line):
+			m := mdHeading.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + mdInline(m[2]) + "</h" + level + ">\n")
+			i++
+
+		case mdRule.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case mdQuote.MatchString(line):
+			var quote []string
+			for ; i < len(lines) && !isBlank(lines[i]); i++ {
+				quote = append(quote, mdQuote.ReplaceAllString(lines[i], ""))
+			}
+			b.WriteString("<blockquote>\n")
+			mdBlocks(b, quote, false)
+			b.WriteString("</blockquote>\n")
+
+		case mdItem.MatchString(line):
+			i = mdList(b, lines, i)
+
+		case indent(line) >= 4:
+			start := i
+			for i < len(lines) && (indent(lines[i]) >= 4 || isBlank(lines[i])) {
+				i++
+			}
+			for i > start && isBlank(lines[i-1]) {
+				i--
+			}
+			code := strings.Join(unindent(lines[start:i], 4), "\n")
+			b.WriteString("<pre><code>" + html.EscapeString(code) + "\n</code></pre>\n")
+
+		default:
+			start := i
+			for i++; i < len(lines) && !isBlank(lines[i]) && !startsBlock(lines[i]) && !mdSetext.MatchString(lines[i]); i++ {
+			}
+			// Setext headings underline their text
+			if i < len(lines) && mdSetext.MatchString(lines[i]) {
+				level := "1"
+				if strings.TrimSpace(lines[i])[0] == '-' {
+					level = "2"
+				}
+				text := mdInline(strings.TrimSpace(strings.Join(lines[start:i], "\n")))
+				b.WriteString("<h" + level + ">" + text + "</h" + level + ">\n")
+				i++
+				continue
+			}
+			text := mdInline(strings.TrimSpace(strings.Join(lines[start:i], "\n")))
+			if tight {
+				b.WriteString(text + "\n")
+			} else {
+				b.WriteString("<p>" + text + "</p>\n")
+			}
+		}
+	}
+}
+
+// mdList renders the list starting at `lines[i]`, returning the index of
+// the first line after it.
+func mdList(b *bytes.Buffer, lines []string, i int) int {
+	m := mdItem.FindStringSubmatch(lines[i])
+	ordered := m[2][0] >= '0' && m[2][0] <= '9'
+	var items [][]string
+	tight := true
+
+	for i < len(lines) {
+		m := mdItem.FindStringSubmatch(lines[i])
+		if m == nil || (m[2][0] >= '0' && m[2][0] <= '9') != ordered {
+			break
+		}
+
+		// The item's content is indented past its marker
+		width := len(m[0])
+		if isBlank(m[3]) {
+			width = len(m[1]) + len(m[2]) + 1
+		}
+		item := []string{lines[i][len(m[0]):]}
+		for i++; i < len(lines); i++ {
+			if isBlank(lines[i]) {
+				// A blank line continues the item, when followed by indented content
+				j := i
+				for j < len(lines) && isBlank(lines[j]) {
+					j++
+				}
+				if j == len(lines) || indent(lines[j]) < width {
+					break
+				}
+				tight = false
+			} else if indent(lines[i]) < width && startsBlock(lines[i]) {
+				break
+			}
+			item = append(item, lines[i])
+		}
+		items = append(items, unindent(item, width))
+
+		// Blank lines between the items loosen the list
+		j := i
+		for j < len(lines) && isBlank(lines[j]) {
+			j++
+		}
+		if j > i && j < len(lines) {
+			if m := mdItem.FindStringSubmatch(lines[j]); m != nil && (m[2][0] >= '0' && m[2][0] <= '9') == ordered {
+				tight = false
+			}
+		}
+		i = j
+	}
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+		if start := strings.TrimRight(m[2], ".)"); strings.TrimLeft(start, "0") != "1" {
+			tag += ` start="` + start + `"`
+		}
+	}
+	b.WriteString("<" + tag + ">\n")
+	for _, item := range items {
+		b.WriteString("<li>")
+		mdBlocks(b, item, tight)
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag[:2] + ">\n")
+	return i
+}
+
+// mdInline renders the inline markup of `s`: code spans, emphasis, links,
+// images, autolinks and hard line breaks.
+func mdInline(s string) string {
+	var b bytes.Buffer
+	ends := mdMatches(s)
+	// Emphasis delimiters, and code span backticks, that aren't closed
+	// anywhere after their first occurrence, so later ones aren't looked for
+	unclosed := make(map[string]bool)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!<>|~\"'", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			n := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			ticks := s[i : i+n]
+			if unclosed[ticks] {
+				break
+			}
+			if end := strings.Index(s[i+n:], ticks); end >= 0 {
+				code := strings.TrimSpace(s[i+n : i+n+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += 2*n + end
+				continue
+			}
+			unclosed[ticks] = true
+
+		case c == '!' && strings.HasPrefix(s[i+1:], "["):
+			if text, url, title, n, ok := mdLink(s, i+1, ends); ok {
+				b.WriteString(`<img src="` + html.EscapeString(safeURL(url)) + `" alt="` +
+					html.EscapeString(PlainText(mdInline(text))) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">")
+				i += 1 + n
+				continue
+			}
+
+		case c == '[':
+			if text, url, title, n, ok := mdLink(s, i, ends); ok {
+				b.WriteString(`<a href="` + html.EscapeString(safeURL(url)) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">" + mdInline(text) + "</a>")
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if m := mdAutolink.FindStringSubmatch(s[i:]); m != nil {
+				url := html.EscapeString(m[1])
+				b.WriteString(`<a href="` + url + `">` + strings.TrimPrefix(url, "mailto:") + "</a>")
+				i += len(m[0])
+				continue
+			}
+
+		case c == '*' || c == '_':
+			// `_` only emphasizes at word boundaries
+			if c == '_' && i > 0 && isWordByte(s[i-1]) {
+				break
+			}
+			delim := string(c)
+			open, close := "<em>", "</em>"
+			if strings.HasPrefix(s[i:], strings.Repeat(delim, 3)) {
+				delim, open, close = strings.Repeat(delim, 3), "<em><strong>", "</strong></em>"
+			} else if strings.HasPrefix(s[i+1:], delim) {
+				delim, open, close = delim+delim, "<strong>", "</strong>"
+			}
+			n := len(delim)
+			if i+n < len(s) && s[i+n] != ' ' && !unclosed[delim] {
+				if end := closingDelim(s[i+n:], delim); end > 0 {
+					b.WriteString(open + mdInline(s[i+n:i+n+end]) + close)
+					i += 2*n + end
+					continue
+				}
+				unclosed[delim] = true
+			}
+
+		case c == '\n':
+			// Two trailing spaces, or a backslash, break the line
+			text := bytes.TrimRight(b.Bytes(), " ")
+			if trimmed := b.Len() - len(text); trimmed >= 2 {
+				b.Truncate(len(text))
+				b.WriteString("<br>\n")
+				i++
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// closingDelim returns the index of the emphasis delimiter closing `s`.
+func closingDelim(s, delim string) int {
+	for i := 1; i+len(delim) <= len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				i += end + 1
+			}
+		case strings.HasPrefix(s[i:], delim) && s[i-1] != ' ':
+			next := i + len(delim)
+			if delim[0] == '_' && next < len(s) && isWordByte(s[next]) {
+				continue
+			}
+			// Leave `**` for strong emphasis, when looking for `*`
+			if len(delim) == 1 && next < len(s) && s[next] == delim[0] {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// mdMatches returns the index of the bracket, or parenthesis, closing each
+// one opened in `s`, or -1, so links are found without rescanning `s` from
+// every bracket.
+func mdMatches(s string) []int {
+	ends := make([]int, len(s))
+	var open []int
+	for i := 0; i < len(s); i++ {
+		ends[i] = -1
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				ends[i] = -1
+			}
+		case '[':
+			open = append(open, i)
+		case ']':
+			if len(open) > 0 {
+				ends[open[len(open)-1]] = i
+				open = open[:len(open)-1]
+			}
+		}
+	}
+
+	// Parentheses of the destination aren't escaped
+	open = open[:0]
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			open = append(open, i)
+		case ')':
+			if len(open) > 0 {
+				ends[open[len(open)-1]] = i
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return ends
+}
+
+// mdLink parses `[text](url "title")` at `s[i:]`, returning the number of
+// bytes it spans. `ends` are the matches of mdMatches.
+func mdLink(s string, i int, ends []int) (text, url, title string, n int, ok bool) {
+	textEnd := ends[i]
+	if textEnd < 0 || textEnd+1 >= len(s) || s[textEnd+1] != '(' {
+		return
+	}
+	// The destination may hold balanced parentheses
+	destEnd := ends[textEnd+1]
+	if destEnd < 0 {
+		return
+	}
+	dest := strings.TrimSpace(s[textEnd+2 : destEnd])
+	if sp := strings.IndexAny(dest, " \t\n"); sp >= 0 {
+		title = strings.Trim(strings.TrimSpace(dest[sp:]), `"'`)
+		dest = dest[:sp]
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	return s[i+1 : textEnd], dest, title, destEnd + 1 - i, true
+}
+
+// safeURL drops URLs with a scheme other than http, https, mailto and the
+// internal article links.
+func safeURL(url string) string {
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || strings.ContainsAny(url[:colon], "/?#") {
+		// relative
+		return url
+	}
+	switch strings.ToLower(url[:colon]) {
+	case "http", "https", "mailto", "article":
+		return url
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var markdownTests = []struct {
+	name, in, want string
+}{
+	// Headings
+	{"atx heading", "# H1", "<h1>H1</h1>\n"},
+	{"atx heading level", "###### H6", "<h6>H6</h6>\n"},
+	{"atx closing hashes", "### H3 ###", "<h3>H3</h3>\n"},
+	{"atx needs a space", "#nospace", "<p>#nospace</p>\n"},
+	{"setext h1", "Title\n=====", "<h1>Title</h1>\n"},
+	{"setext h2", "Sub\n---", "<h2>Sub</h2>\n"},
+	{"heading inline", "## A *b*", "<h2>A <em>b</em></h2>\n"},
+
+	// Emphasis
+	{"em", "*em*", "<p><em>em</em></p>\n"},
+	{"em underscore", "_em_", "<p><em>em</em></p>\n"},
+	{"strong", "**strong**", "<p><strong>strong</strong></p>\n"},
+	{"strong underscore", "__strong__", "<p><strong>strong</strong></p>\n"},
+	{"em strong", "***both***", "<p><em><strong>both</strong></em></p>\n"},
+	{"intraword underscore", "snake_case_word", "<p>snake_case_word</p>\n"},
+	{"unclosed", "*open", "<p>*open</p>\n"},
+	{"spaced", "a * b * c", "<p>a * b * c</p>\n"},
+	{"unclosed with others", "_a *b* c", "<p>_a <em>b</em> c</p>\n"},
+	{"escaped", `\*not\*`, "<p>*not*</p>\n"},
+
+	// Code spans
+	{"code", "`code`", "<p><code>code</code></p>\n"},
+	{"code escaped", "`<b>&`", "<p><code>&lt;b&gt;&amp;</code></p>\n"},
+	{"code backticks", "`` a ` b ``", "<p><code>a ` b</code></p>\n"},
+	{"code keeps emphasis", "`*x*`", "<p><code>*x*</code></p>\n"},
+
+	// Code blocks
+	{"fence", "```\nx := 1\n```", "<pre><code>x := 1\n</code></pre>\n"},
+	{"fence lang", "```go\nfunc() {}\n```", "<pre><code class=\"language-go\">func() {}\n</code></pre>\n"},
+	{"fence escaped", "```\n<b>\n```", "<pre><code>&lt;b&gt;\n</code></pre>\n"},
+	{"fence tildes", "~~~\nx\n~~~", "<pre><code>x\n</code></pre>\n"},
+	{"indented", "    code", "<pre><code>code\n</code></pre>\n"},
+
+	// Lists
+	{"ul", "- a\n- b", "<ul>\n<li>a\n</li>\n<li>b\n</li>\n</ul>\n"},
+	{"ul star", "* a", "<ul>\n<li>a\n</li>\n</ul>\n"},
+	{"ol", "1. one\n2. two", "<ol>\n<li>one\n</li>\n<li>two\n</li>\n</ol>\n"},
+	{"ol start", "3. three", "<ol start=\"3\">\n<li>three\n</li>\n</ol>\n"},
+	{"loose", "- a\n\n- b", "<ul>\n<li><p>a</p>\n</li>\n<li><p>b</p>\n</li>\n</ul>\n"},
+	{"nested", "- a\n  - b", "<ul>\n<li>a\n<ul>\n<li>b\n</li>\n</ul>\n</li>\n</ul>\n"},
+
+	// Links
+	{"link", "[a](http://example.com)", "<p><a href=\"http://example.com\">a</a></p>\n"},
+	{"link title", `[a](http://example.com "T")`, "<p><a href=\"http://example.com\" title=\"T\">a</a></p>\n"},
+	{"link relative", "[a](/b?c=1&d=2)", "<p><a href=\"/b?c=1&amp;d=2\">a</a></p>\n"},
+	{"link parens", "[a](http://x/(b))", "<p><a href=\"http://x/(b)\">a</a></p>\n"},
+	{"link emphasis", "[*a*](/b)", "<p><a href=\"/b\"><em>a</em></a></p>\n"},
+	{"link mailto", "[a](mailto:a@example.com)", "<p><a href=\"mailto:a@example.com\">a</a></p>\n"},
+	{"link article", "[a](article:2013/06/01/a/)", "<p><a href=\"article:2013/06/01/a/\">a</a></p>\n"},
+	{"autolink", "<http://example.com>", "<p><a href=\"http://example.com\">http://example.com</a></p>\n"},
+	{"not a link", "[a] (b)", "<p>[a] (b)</p>\n"},
+	{"unclosed bracket", "a[ see [b](/c)", "<p>a[ see <a href=\"/c\">b</a></p>\n"},
+	{"unclosed destination", "[a](b [c](/d)", "<p>[a](b <a href=\"/d\">c</a></p>\n"},
+	{"escaped bracket", `[a\]](/b)`, "<p><a href=\"/b\">a]</a></p>\n"},
+
+	// Images
+	{"image", "![alt](/a.png)", "<p><img src=\"/a.png\" alt=\"alt\"></p>\n"},
+	{"image alt text", "![a *b*](/a.png \"T\")", "<p><img src=\"/a.png\" alt=\"a b\" title=\"T\"></p>\n"},
+
+	// Unsafe URLs
+	{"javascript", "[a](javascript:alert(1))", "<p><a href=\"\">a</a></p>\n"},
+	{"javascript case", "[a](JavaScript:alert(1))", "<p><a href=\"\">a</a></p>\n"},
+	{"javascript spaced", "[a]( javascript:alert(1))", "<p><a href=\"\">a</a></p>\n"},
+	{"javascript image", "![a](javascript:alert(1))", "<p><img src=\"\" alt=\"a\"></p>\n"},
+	{"data", "![a](data:image/png;base64,AAA)", "<p><img src=\"\" alt=\"a\"></p>\n"},
+	{"javascript autolink", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>\n"},
+
+	// Other blocks, and raw HTML
+	{"raw html", "<b>raw</b>", "<p>&lt;b&gt;raw&lt;/b&gt;</p>\n"},
+	{"quote", "> a\n> b", "<blockquote>\n<p>a\nb</p>\n</blockquote>\n"},
+	{"rule", "---", "<hr>\n"},
+	{"break", "a  \nb", "<p>a<br>\nb</p>\n"},
+	{"paragraphs", "a\n\nb", "<p>a</p>\n<p>b</p>\n"},
+}
+
+func TestMarkdown(t *testing.T) {
+	for _, test := range markdownTests {
+		if got := Markdown(test.in); got != test.want {
+			t.Errorf("%s: Markdown(%q)\n got %q\nwant %q", test.name, test.in, got, test.want)
+		}
+	}
+}
+
+func TestMarkdownUnclosed(t *testing.T) {
+	for _, in := range []string{
+		strings.Repeat("*a ", 20000),
+		strings.Repeat("_a ", 20000),
+		strings.Repeat("[a](", 20000),
+		strings.Repeat("[[a]", 20000),
+		strings.Repeat("``a`", 20000),
+	} {
+		start := time.Now()
+		Markdown(in)
+		if d := time.Since(start); d > 2*time.Second {
+			t.Errorf("Markdown(%.12q...) took %v", in, d)
+		}
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"http://example.com", "http://example.com"},
+		{"HTTPS://example.com", "HTTPS://example.com"},
+		{"mailto:a@example.com", "mailto:a@example.com"},
+		{"article:2013/06/01/a/", "article:2013/06/01/a/"},
+		{"/relative:colon", "/relative:colon"},
+		{"page?a=b:c", "page?a=b:c"},
+		{"#frag", "#frag"},
+		{"javascript:alert(1)", ""},
+		{"JAVASCRIPT:alert(1)", ""},
+		{"vbscript:x", ""},
+		{"data:text/html,x", ""},
+		{"java\x00script:alert(1)", ""},
+	}
+	for _, test := range tests {
+		if got := safeURL(test.in); got != test.want {
+			t.Errorf("safeURL(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestPlain(t *testing.T) {
+	if got, want := Plain("a <b>\nc\n\nd"), "<p>a &lt;b&gt;<br>\nc</p>\n<p>d</p>"; got != want {
+		t.Errorf("Plain = %q, want %q", got, want)
+	}
+}
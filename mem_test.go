@@ -0,0 +1,329 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"code.minty.io/wombat/backends"
+)
+
+var errNotFound = backends.NewError(backends.StatusNotFound, "Not found", nil)
+
+// memBackend is an in-memory Reader and Printer, for tests.
+type memBackend struct {
+	articles map[string]*Article
+	series   map[string]*Series
+	finds    int // calls of Find and Recent
+	lookups  int // calls of ByTitlePath
+}
+
+func newMemBackend(list ...*Article) *memBackend {
+	b := &memBackend{articles: make(map[string]*Article), series: make(map[string]*Series)}
+	for _, a := range list {
+		b.Print(a)
+	}
+	return b
+}
+
+// article returns a copy of the stored article, as a backend would.
+func (b *memBackend) article(titlePath string) (*Article, error) {
+	a, ok := b.articles[titlePath]
+	if !ok {
+		return nil, errNotFound
+	}
+	c := *a
+	c.Printer = b
+	c.Translations = append([]Translation(nil), a.Translations...)
+	return &c, nil
+}
+
+func (b *memBackend) update(titlePath string, fn func(a *Article)) error {
+	a, ok := b.articles[titlePath]
+	if !ok {
+		return errNotFound
+	}
+	fn(a)
+	return nil
+}
+
+// Reader
+func (b *memBackend) ByTitlePath(titlePath string, unPublished bool) (interface{}, error) {
+	b.lookups++
+	a, err := b.article(titlePath)
+	if err != nil || !unPublished && !a.IsPublished {
+		return nil, errNotFound
+	}
+	return a, nil
+}
+func (b *memBackend) Recent(limit, page int, unPublished bool) (interface{}, error) {
+	return b.Find(RecentQuery(limit, page, unPublished))
+}
+func (b *memBackend) Featured(limit int) (interface{}, error) {
+	return b.Find(FeaturedQuery(limit))
+}
+func (b *memBackend) Tags() ([]string, error) {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, a := range b.articles {
+		for _, t := range a.Tags {
+			if a.IsPublished && !seen[t] {
+				seen[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+func (b *memBackend) Find(q Query) (interface{}, error) {
+	b.finds++
+	var list []*Article
+	for tp := range b.articles {
+		a, _ := b.article(tp)
+		switch {
+		case q.Published == Published && !a.IsPublished,
+			q.Published == UnPublished && a.IsPublished,
+			q.Tag != "" && !hasTag(a.Tags, q.Tag),
+			q.Featured && !a.Featured,
+			!q.From.IsZero() && a.Created.Before(q.From),
+			!q.To.IsZero() && !a.Created.Before(q.To):
+			continue
+		}
+		list = append(list, a)
+	}
+	_, desc := q.SortField()
+	sort.SliceStable(list, func(i, j int) bool {
+		if desc {
+			return list[i].Created.After(list[j].Created)
+		}
+		return list[i].Created.Before(list[j].Created)
+	})
+	if !q.PinnedFirst {
+		return page(list, q.Page*q.Limit, q.Limit), nil
+	}
+
+	// As the mongo backend, the pinned articles leading the listing
+	var pinned, rest []*Article
+	for _, a := range list {
+		if a.Pinned {
+			pinned = append(pinned, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	pinnedSkip, lead, skip, limit := q.PinnedPage(len(pinned))
+	list = pinned[pinnedSkip : pinnedSkip+lead]
+	if limit >= 0 {
+		list = append(list, page(rest, skip, limit)...)
+	}
+	return list, nil
+}
+
+// page returns up to `limit` of `list` from `start`, or all of them when
+// `limit` is zero.
+func page(list []*Article, start, limit int) []*Article {
+	if limit <= 0 {
+		return list
+	}
+	if start > len(list) {
+		start = len(list)
+	}
+	end := start + limit
+	if end > len(list) {
+		end = len(list)
+	}
+	return list[start:end]
+}
+func (b *memBackend) Series(name string) (*Series, error) {
+	s, ok := b.series[name]
+	if !ok {
+		return nil, errNotFound
+	}
+	c := *s
+	c.Printer = b
+	return &c, nil
+}
+func (b *memBackend) SeriesOf(titlePath string) (*Series, error) {
+	for _, s := range b.series {
+		for _, tp := range s.TitlePaths {
+			if tp == titlePath {
+				return b.Series(s.Name)
+			}
+		}
+	}
+	return nil, errNotFound
+}
+func (b *memBackend) AllSeries() ([]*Series, error) {
+	var all []*Series
+	for name := range b.series {
+		s, _ := b.Series(name)
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all, nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Printer
+func (b *memBackend) Print(article interface{}) error {
+	a := *article.(*Article)
+	if _, ok := b.articles[a.TitlePath]; ok {
+		return errors.New("Duplicate titlePath")
+	}
+	a.Printer = nil
+	b.articles[a.TitlePath] = &a
+	return nil
+}
+func (b *memBackend) Replace(titlePath string, article interface{}) error {
+	if _, ok := b.articles[titlePath]; !ok {
+		return errNotFound
+	}
+	a := *article.(*Article)
+	a.Printer = nil
+	b.articles[titlePath] = &a
+	return nil
+}
+func (b *memBackend) UpdateSynopsis(titlePath, synopsis string, modified time.Time) error {
+	return b.update(titlePath, func(a *Article) { a.Synopsis, a.Modified = synopsis, modified })
+}
+func (b *memBackend) UpdateContent(titlePath, content string, stats Stats, modified time.Time) error {
+	return b.update(titlePath, func(a *Article) {
+		a.Content, a.Stats, a.Modified = content, stats, modified
+		a.clearRendered()
+	})
+}
+func (b *memBackend) UpdateContentFormat(titlePath string, format ContentFormat, modified time.Time) error {
+	return b.update(titlePath, func(a *Article) {
+		a.ContentFormat, a.Modified = format, modified
+		a.clearRendered()
+	})
+}
+func (b *memBackend) WriteRendered(titlePath, rendered string) error {
+	return b.update(titlePath, func(a *Article) { a.Rendered = rendered })
+}
+func (b *memBackend) WriteLang(titlePath, lang string) error {
+	return b.update(titlePath, func(a *Article) { a.Lang = lang })
+}
+func (b *memBackend) WriteTranslation(titlePath string, t Translation) error {
+	return b.update(titlePath, func(a *Article) {
+		for i := range a.Translations {
+			if a.Translations[i].Lang == t.Lang {
+				a.Translations[i] = t
+				return
+			}
+		}
+		a.Translations = append(a.Translations, t)
+	})
+}
+func (b *memBackend) WriteTranslationRendered(titlePath, lang, rendered string) error {
+	return b.update(titlePath, func(a *Article) {
+		for i := range a.Translations {
+			if a.Translations[i].Lang == lang {
+				a.Translations[i].Rendered = rendered
+			}
+		}
+	})
+}
+func (b *memBackend) RemoveTranslation(titlePath, lang string) error {
+	return b.update(titlePath, func(a *Article) {
+		for i := range a.Translations {
+			if a.Translations[i].Lang == lang {
+				a.Translations = append(a.Translations[:i], a.Translations[i+1:]...)
+				return
+			}
+		}
+	})
+}
+func (b *memBackend) Delete(titlePath string) error {
+	if _, ok := b.articles[titlePath]; !ok {
+		return errNotFound
+	}
+	delete(b.articles, titlePath)
+	for name := range b.series {
+		b.RemoveFromSeries(name, titlePath)
+	}
+	return nil
+}
+func (b *memBackend) Publish(titlePath string, publish bool) error {
+	return b.update(titlePath, func(a *Article) { a.IsPublished = publish })
+}
+func (b *memBackend) Pin(titlePath string, pin bool) error {
+	return b.update(titlePath, func(a *Article) { a.Pinned = pin })
+}
+func (b *memBackend) Feature(titlePath string, feature bool) error {
+	return b.update(titlePath, func(a *Article) { a.Featured = feature })
+}
+func (b *memBackend) WriteImg(titlePath string, img interface{}) error {
+	return b.update(titlePath, func(a *Article) {
+		a.Img = img.(Img)
+		a.clearRendered()
+	})
+}
+func (b *memBackend) WriteImgs(titlePath string, imgs interface{}) error {
+	return b.update(titlePath, func(a *Article) {
+		a.Imgs = imgs.([]Img)
+		a.clearRendered()
+	})
+}
+func (b *memBackend) WriteTags(titlePath string, tags []string) error {
+	return b.update(titlePath, func(a *Article) { a.Tags = tags })
+}
+func (b *memBackend) WriteAuthor(titlePath, author string) error {
+	return b.update(titlePath, func(a *Article) { a.Author = author })
+}
+func (b *memBackend) WriteMeta(titlePath string, meta Meta) error {
+	return b.update(titlePath, func(a *Article) { a.Meta = meta })
+}
+func (b *memBackend) AddToSeries(name, titlePath string) error {
+	s, ok := b.series[name]
+	if !ok {
+		s = &Series{Name: name}
+		b.series[name] = s
+	}
+	for _, tp := range s.TitlePaths {
+		if tp == titlePath {
+			return nil
+		}
+	}
+	s.TitlePaths = append(s.TitlePaths, titlePath)
+	return nil
+}
+func (b *memBackend) RemoveFromSeries(name, titlePath string) error {
+	s, ok := b.series[name]
+	if !ok {
+		return errNotFound
+	}
+	for i, tp := range s.TitlePaths {
+		if tp == titlePath {
+			s.TitlePaths = append(s.TitlePaths[:i], s.TitlePaths[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+func (b *memBackend) ReorderSeries(name string, titlePaths []string) error {
+	s, ok := b.series[name]
+	if !ok {
+		return errNotFound
+	}
+	s.TitlePaths = append([]string(nil), titlePaths...)
+	return nil
+}
+func (b *memBackend) WriteSeries(s *Series) error {
+	b.series[s.Name] = &Series{Name: s.Name, TitlePaths: append([]string(nil), s.TitlePaths...)}
+	return nil
+}
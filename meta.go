@@ -0,0 +1,87 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Meta holds the custom fields of an article, such as a source URL, decoded
+// from JSON.
+type Meta map[string]interface{}
+
+// Types of metadata fields.
+type MetaType string
+
+const (
+	MetaString MetaType = "string"
+	MetaNumber MetaType = "number"
+	MetaBool   MetaType = "bool"
+	MetaTime   MetaType = "time" // RFC 3339, or `2006-01-02`
+	MetaURL    MetaType = "url"  // absolute URL
+)
+
+// ValidMetaType reports if `t` is one of the types of metadata fields.
+func ValidMetaType(t MetaType) bool {
+	switch t {
+	case MetaString, MetaNumber, MetaBool, MetaTime, MetaURL:
+		return true
+	}
+	return false
+}
+
+// MetaSchema is the type of every metadata field articles may have, by name.
+type MetaSchema map[string]MetaType
+
+// Validate checks that every field of `meta` is in the schema, and of its
+// type. A nil schema allows any field.
+func (s MetaSchema) Validate(meta Meta) error {
+	if s == nil {
+		return nil
+	}
+	for k, v := range meta {
+		t, ok := s[k]
+		if !ok {
+			return fmt.Errorf("Unknown meta field %q", k)
+		}
+		if v != nil && !t.valid(v) {
+			return fmt.Errorf("Meta field %q must be a %s", k, t)
+		}
+	}
+	return nil
+}
+
+// valid reports if the decoded JSON value `v` is of the type.
+func (t MetaType) valid(v interface{}) bool {
+	switch t {
+	case MetaString:
+		_, ok := v.(string)
+		return ok
+	case MetaNumber:
+		switch v.(type) {
+		case float64, float32, int, int64:
+			return true
+		}
+	case MetaBool:
+		_, ok := v.(bool)
+		return ok
+	case MetaTime:
+		if s, ok := v.(string); ok {
+			if _, err := time.Parse(time.RFC3339, s); err == nil {
+				return true
+			}
+			_, err := time.Parse("2006-01-02", s)
+			return err == nil
+		}
+	case MetaURL:
+		if s, ok := v.(string); ok {
+			u, err := url.Parse(s)
+			return err == nil && u.IsAbs() && u.Host != ""
+		}
+	}
+	return false
+}
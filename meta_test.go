@@ -0,0 +1,112 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var metaSchema = MetaSchema{
+	"source":    MetaURL,
+	"rating":    MetaNumber,
+	"featured":  MetaBool,
+	"event":     MetaTime,
+	"publisher": MetaString,
+}
+
+func TestMetaSchemaValidate(t *testing.T) {
+	tests := []struct {
+		meta string // as JSON
+		err  string
+	}{
+		{`{}`, ""},
+		{`{"source": "https://example.com/a?b=c", "rating": 4.5, "featured": true, "publisher": "P"}`, ""},
+		{`{"rating": -2}`, ""},
+		{`{"event": "2013-06-01"}`, ""},
+		{`{"event": "2013-06-01T10:30:00+02:00"}`, ""},
+		{`{"source": null}`, ""}, // removes the field
+
+		{`{"author": "A"}`, `Unknown meta field "author"`},
+		{`{"rating": "5"}`, `Meta field "rating" must be a number`},
+		{`{"featured": "true"}`, `Meta field "featured" must be a bool`},
+		{`{"featured": 1}`, `Meta field "featured" must be a bool`},
+		{`{"publisher": 2}`, `Meta field "publisher" must be a string`},
+		{`{"publisher": ["P"]}`, `Meta field "publisher" must be a string`},
+		{`{"event": "June 1st"}`, `Meta field "event" must be a time`},
+		{`{"event": "2013-06-01 10:30"}`, `Meta field "event" must be a time`},
+		{`{"event": 1370044800}`, `Meta field "event" must be a time`},
+		{`{"source": "/a/b"}`, `Meta field "source" must be a url`},
+		{`{"source": "example.com"}`, `Meta field "source" must be a url`},
+		{`{"source": "mailto:a@example.com"}`, `Meta field "source" must be a url`},
+		{`{"source": "http://%zz"}`, `Meta field "source" must be a url`},
+	}
+	for _, test := range tests {
+		var meta Meta
+		if err := json.Unmarshal([]byte(test.meta), &meta); err != nil {
+			t.Fatal(err)
+		}
+		err := metaSchema.Validate(meta)
+		if got := errString(err); got != test.err {
+			t.Errorf("Validate(%s) = %q, want %q", test.meta, got, test.err)
+		}
+	}
+
+	// A nil schema allows anything
+	if err := MetaSchema(nil).Validate(Meta{"any": []int{1}}); err != nil {
+		t.Errorf("nil schema: %v", err)
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestMetaSchemaValidateTypes(t *testing.T) {
+	// Values from backends, rather than JSON
+	meta := Meta{"rating": 4, "featured": false}
+	if err := metaSchema.Validate(meta); err != nil {
+		t.Errorf("Validate(%v) = %v", meta, err)
+	}
+}
+
+func TestValidMetaType(t *testing.T) {
+	tests := map[MetaType]bool{
+		MetaString: true,
+		MetaNumber: true,
+		MetaBool:   true,
+		MetaTime:   true,
+		MetaURL:    true,
+		"int":      false,
+		"":         false,
+	}
+	for typ, want := range tests {
+		if got := ValidMetaType(typ); got != want {
+			t.Errorf("ValidMetaType(%q) = %v, want %v", typ, got, want)
+		}
+	}
+}
+
+func TestSetMeta(t *testing.T) {
+	b := newMemBackend(&Article{TitlePath: "2013/06/01/a/", Meta: Meta{"publisher": "P", "rating": 3.0}})
+	a, _ := b.article("2013/06/01/a/")
+	original := a.Meta
+
+	if err := a.SetMeta(Meta{"rating": nil, "featured": true}); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := b.article("2013/06/01/a/")
+	for _, m := range []Meta{a.Meta, stored.Meta} {
+		if len(m) != 2 || m["publisher"] != "P" || m["featured"] != true {
+			t.Errorf("meta %v, want rating removed and featured added", m)
+		}
+	}
+	if len(original) != 2 || original["rating"] != 3.0 {
+		t.Errorf("SetMeta changed the previous meta %v", original)
+	}
+}
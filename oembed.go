@@ -0,0 +1,142 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+)
+
+// Size of the embedded card, unless the consumer asks for a smaller one.
+const (
+	OEmbedWidth  = 600
+	OEmbedHeight = 400
+)
+
+// OEmbed is the oEmbed response of an article, a `rich` card linking to it.
+type OEmbed struct {
+	XMLName         xml.Name `json:"-" xml:"oembed"`
+	Type            string   `json:"type" xml:"type"`
+	Version         string   `json:"version" xml:"version"`
+	Title           string   `json:"title,omitempty" xml:"title,omitempty"`
+	AuthorName      string   `json:"author_name,omitempty" xml:"author_name,omitempty"`
+	ProviderName    string   `json:"provider_name,omitempty" xml:"provider_name,omitempty"`
+	ProviderURL     string   `json:"provider_url,omitempty" xml:"provider_url,omitempty"`
+	ThumbnailURL    string   `json:"thumbnail_url,omitempty" xml:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int      `json:"thumbnail_width,omitempty" xml:"thumbnail_width,omitempty"`
+	ThumbnailHeight int      `json:"thumbnail_height,omitempty" xml:"thumbnail_height,omitempty"`
+	HTML            string   `json:"html" xml:"html"`
+	Width           int      `json:"width" xml:"width"`
+	Height          int      `json:"height" xml:"height"`
+}
+
+// NewOEmbed returns the card of `a`, at the absolute URL `url`, its thumbnail
+// being under the absolute `mediaURL`, from the site `providerName` at
+// `providerURL`. The card, and thumbnail, fit within
+// `maxWidth` and `maxHeight`, when they're above 0.
+func NewOEmbed(a *Article, url, mediaURL, providerName, providerURL string, maxWidth, maxHeight int) *OEmbed {
+	o := &OEmbed{
+		Type:         "rich",
+		Version:      "1.0",
+		Title:        a.Title,
+		AuthorName:   a.Author,
+		ProviderName: providerName,
+		ProviderURL:  providerURL,
+		Width:        OEmbedWidth,
+		Height:       OEmbedHeight,
+	}
+	if maxWidth > 0 && maxWidth < o.Width {
+		o.Width = maxWidth
+	}
+	if maxHeight > 0 && maxHeight < o.Height {
+		o.Height = maxHeight
+	}
+
+	// The thumbnail, only when its size is known, and fits
+	if img := a.Img; img.Src != "" && img.W > 0 && img.H > 0 &&
+		(maxWidth <= 0 || img.W <= maxWidth) && (maxHeight <= 0 || img.H <= maxHeight) {
+		o.ThumbnailURL = mediaURL + a.TitlePath + img.Src
+		o.ThumbnailWidth, o.ThumbnailHeight = img.W, img.H
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<blockquote class="article-embed" style="box-sizing:border-box;max-width:%dpx;max-height:%dpx;overflow:hidden;margin:0;padding:12px;border:1px solid #ddd;border-radius:4px">`,
+		o.Width, o.Height)
+	if o.ThumbnailURL != "" {
+		fmt.Fprintf(&b, `<a href="%s"><img src="%s" alt="%s" width="%d" height="%d" style="max-width:100%%;height:auto"></a>`,
+			html.EscapeString(url), html.EscapeString(o.ThumbnailURL), html.EscapeString(a.Img.Alt), o.ThumbnailWidth, o.ThumbnailHeight)
+	}
+	fmt.Fprintf(&b, `<p><strong><a href="%s">%s</a></strong></p>`, html.EscapeString(url), html.EscapeString(a.Title))
+	if s := a.Summary(); s != "" {
+		b.WriteString("<p>" + html.EscapeString(s) + "</p>")
+	}
+	if by := byline(a.Author, providerName); by != "" {
+		b.WriteString("<p><small>" + html.EscapeString(by) + "</small></p>")
+	}
+	b.WriteString("</blockquote>")
+	o.HTML = b.String()
+	return o
+}
+
+// byline joins the author, and provider, of a card.
+func byline(author, provider string) string {
+	switch {
+	case author != "" && provider != "":
+		return author + ", " + provider
+	case author != "":
+		return author
+	}
+	return provider
+}
+
+// JSON encodes the response as oEmbed's `json` format.
+func (o *OEmbed) JSON() ([]byte, error) {
+	return json.Marshal(o)
+}
+
+// XML encodes the response as oEmbed's `xml` format.
+func (o *OEmbed) XML() ([]byte, error) {
+	b, err := xml.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// OEmbedLink is an oEmbed discovery `<link>`, of a page's `<head>`.
+type OEmbedLink struct {
+	Type  string // application/json+oembed, or text/xml+oembed
+	Href  string
+	Title string
+}
+
+type OEmbedLinks []OEmbedLink
+
+// OEmbedDiscovery returns the discovery links, for both formats, of the page
+// at `pageURL` titled `title`, the oEmbed endpoint being `endpoint`.
+func OEmbedDiscovery(endpoint, pageURL, title string) OEmbedLinks {
+	href := func(format string) string {
+		return endpoint + "?" + url.Values{"url": {pageURL}, "format": {format}}.Encode()
+	}
+	return OEmbedLinks{
+		{"application/json+oembed", href("json"), title},
+		{"text/xml+oembed", href("xml"), title},
+	}
+}
+
+// HTML renders the links as `<link>` tags, for a page's `<head>`.
+func (l OEmbedLinks) HTML() template.HTML {
+	var b bytes.Buffer
+	for _, link := range l {
+		b.WriteString(`<link rel="alternate" type="` + link.Type + `" href="` +
+			html.EscapeString(link.Href) + `" title="` + html.EscapeString(link.Title) + "\">\n")
+	}
+	return template.HTML(b.String())
+}
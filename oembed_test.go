@@ -0,0 +1,134 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOEmbedSize(t *testing.T) {
+	a := &Article{TitlePath: "2013/06/01/a/", Title: "A", Img: Img{Src: "a.png", W: 300, H: 200}}
+	tests := []struct {
+		maxWidth, maxHeight int
+		width, height       int
+		thumbnail           bool
+	}{
+		{0, 0, OEmbedWidth, OEmbedHeight, true},
+		{-1, -1, OEmbedWidth, OEmbedHeight, true},
+		{1000, 1000, OEmbedWidth, OEmbedHeight, true},
+		{400, 0, 400, OEmbedHeight, true},
+		{0, 300, OEmbedWidth, 300, true},
+		{300, 200, 300, 200, true}, // the thumbnail just fits
+		{299, 0, 299, OEmbedHeight, false},
+		{0, 199, OEmbedWidth, 199, false},
+	}
+	for _, test := range tests {
+		o := NewOEmbed(a, "http://example.com/a/", "http://example.com/media/", "", "", test.maxWidth, test.maxHeight)
+		if o.Width != test.width || o.Height != test.height {
+			t.Errorf("NewOEmbed(%d, %d) is %dx%d, want %dx%d", test.maxWidth, test.maxHeight,
+				o.Width, o.Height, test.width, test.height)
+		}
+		if (o.ThumbnailURL != "") != test.thumbnail {
+			t.Errorf("NewOEmbed(%d, %d) thumbnail %q, want one %v", test.maxWidth, test.maxHeight,
+				o.ThumbnailURL, test.thumbnail)
+		}
+		if style := "max-width:" + strconv.Itoa(o.Width) + "px;max-height:" + strconv.Itoa(o.Height) + "px"; !strings.Contains(o.HTML, style) {
+			t.Errorf("NewOEmbed(%d, %d) HTML without %s:\n%s", test.maxWidth, test.maxHeight, style, o.HTML)
+		}
+	}
+
+	// Thumbnails of unknown size are left out
+	a.Img = Img{Src: "a.png"}
+	if o := NewOEmbed(a, "http://example.com/a/", "http://example.com/media/", "", "", 0, 0); o.ThumbnailURL != "" {
+		t.Errorf("thumbnail %q of unknown size", o.ThumbnailURL)
+	}
+}
+
+func TestOEmbedHTML(t *testing.T) {
+	tests := []struct {
+		a              *Article
+		provider, want string
+	}{
+		{&Article{TitlePath: "2013/06/01/a/", Title: `"A" & <B>`, Synopsis: "<i>s</i>", Author: "Ann",
+			Img: Img{Src: "a.png", Alt: `a "photo"`, W: 300, H: 200}}, "Site",
+			`<a href="http://example.com/a/?x=1&amp;y=2"><img src="http://example.com/media/2013/06/01/a/a.png" alt="a &#34;photo&#34;" width="300" height="200" style="max-width:100%;height:auto"></a>` +
+				`<p><strong><a href="http://example.com/a/?x=1&amp;y=2">&#34;A&#34; &amp; &lt;B&gt;</a></strong></p>` +
+				`<p>&lt;i&gt;s&lt;/i&gt;</p><p><small>Ann, Site</small></p></blockquote>`},
+		{&Article{TitlePath: "2013/06/01/a/", Title: "A", Author: "Ann"}, "",
+			`overflow:hidden;margin:0;padding:12px;border:1px solid #ddd;border-radius:4px">` +
+				`<p><strong><a href="http://example.com/a/?x=1&amp;y=2">A</a></strong></p><p><small>Ann</small></p></blockquote>`},
+		{&Article{TitlePath: "2013/06/01/a/", Title: "A"}, "",
+			`border-radius:4px"><p><strong><a href="http://example.com/a/?x=1&amp;y=2">A</a></strong></p></blockquote>`},
+	}
+	for _, test := range tests {
+		o := NewOEmbed(test.a, "http://example.com/a/?x=1&y=2", "http://example.com/media/", test.provider, "http://example.com/", 0, 0)
+		if !strings.HasPrefix(o.HTML, `<blockquote class="article-embed"`) || !strings.HasSuffix(o.HTML, test.want) {
+			t.Errorf("HTML\n got %s\nwant it ending %s", o.HTML, test.want)
+		}
+	}
+}
+
+func TestOEmbedEncoding(t *testing.T) {
+	a := &Article{TitlePath: "2013/06/01/a/", Title: "A & B", Author: "Ann", Img: Img{Src: "a.png", W: 300, H: 200}}
+	o := NewOEmbed(a, "http://example.com/a/", "http://example.com/media/", "Site", "http://example.com/", 0, 0)
+
+	b, err := o.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]interface{}{
+		"type": "rich", "version": "1.0", "title": "A & B", "author_name": "Ann",
+		"provider_name": "Site", "provider_url": "http://example.com/",
+		"thumbnail_url": "http://example.com/media/2013/06/01/a/a.png", "thumbnail_width": 300.0,
+		"thumbnail_height": 200.0, "width": 600.0, "height": 400.0, "html": o.HTML,
+	} {
+		if fields[k] != want {
+			t.Errorf("JSON %s = %v, want %v", k, fields[k], want)
+		}
+	}
+	if len(fields) != 12 {
+		t.Errorf("JSON has %d fields, want 12:\n%s", len(fields), b)
+	}
+
+	// Without a thumbnail, or provider
+	bare := NewOEmbed(&Article{Title: "A"}, "http://example.com/a/", "", "", "", 0, 0)
+	if b, err = bare.JSON(); err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); strings.Contains(s, "thumbnail") || strings.Contains(s, "provider") || strings.Contains(s, "author") {
+		t.Errorf("JSON with empty fields:\n%s", s)
+	}
+
+	if b, err = o.XML(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), xml.Header+"<oembed><type>rich</type><version>1.0</version><title>A &amp; B</title>") {
+		t.Errorf("XML:\n%s", b)
+	}
+	var decoded OEmbed
+	if err := xml.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.HTML != o.HTML || decoded.Width != 600 || decoded.ThumbnailHeight != 200 {
+		t.Errorf("XML decoded as %+v", decoded)
+	}
+}
+
+func TestOEmbedDiscovery(t *testing.T) {
+	links := OEmbedDiscovery("http://example.com/articles/oembed", "http://example.com/articles/2013/06/01/a/", `"A" & B`)
+	want := `<link rel="alternate" type="application/json+oembed" href="http://example.com/articles/oembed?format=json&amp;url=http%3A%2F%2Fexample.com%2Farticles%2F2013%2F06%2F01%2Fa%2F" title="&#34;A&#34; &amp; B">` + "\n" +
+		`<link rel="alternate" type="text/xml+oembed" href="http://example.com/articles/oembed?format=xml&amp;url=http%3A%2F%2Fexample.com%2Farticles%2F2013%2F06%2F01%2Fa%2F" title="&#34;A&#34; &amp; B">` + "\n"
+	if got := string(links.HTML()); got != want {
+		t.Errorf("HTML()\n got %s\nwant %s", got, want)
+	}
+}
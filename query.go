@@ -0,0 +1,112 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"strings"
+	"time"
+)
+
+// PublishState filters articles on their published state.
+type PublishState int
+
+const (
+	Published PublishState = iota
+	UnPublished
+	AnyPublishState
+)
+
+// Sortable fields, prefix with `-` for a descending sort.
+const (
+	SortCreated  = "created"
+	SortModified = "modified"
+	SortTitle    = "title"
+)
+
+// Query describes a listing of articles, which Reader implementations
+// translate into their native queries.
+type Query struct {
+	Limit     int
+	Page      int
+	Sort      string
+	Published PublishState
+	From, To  time.Time // Created, from inclusive, to exclusive
+	Tag       string
+	Author    string
+	HasThumb  bool
+	Featured  bool
+	Lang      string // own language, or translation
+
+	// PinnedFirst lists the pinned articles ahead of the others, paging
+	// through them before any of the others
+	PinnedFirst bool
+}
+
+// RecentQuery is the query used by `Reader.Recent`.
+func RecentQuery(limit, page int, unPublished bool) Query {
+	q := Query{Limit: limit, Page: page, Sort: "-" + SortCreated, PinnedFirst: true}
+	if unPublished {
+		q.Published = AnyPublishState
+	}
+	return q
+}
+
+// ValidSort reports if `sort` is one of the sortable fields, either direction.
+func ValidSort(sort string) bool {
+	switch strings.TrimPrefix(sort, "-") {
+	case SortCreated, SortModified, SortTitle:
+		return true
+	}
+	return false
+}
+
+// FeaturedQuery is the query used by `Reader.Featured`.
+func FeaturedQuery(limit int) Query {
+	return Query{Limit: limit, Sort: "-" + SortCreated, Featured: true}
+}
+
+// FeedQuery is the query of the published articles of a feed, newest first,
+// those tagged `tag` when it isn't empty. Pinned articles aren't put first, as
+// readers order entries by their dates.
+func FeedQuery(limit int, tag string) Query {
+	return Query{Limit: limit, Sort: "-" + SortCreated, Tag: tag}
+}
+
+// SortField returns the field being sorted on, and if it's descending,
+// defaulting to the newest articles first.
+func (q Query) SortField() (field string, desc bool) {
+	if !ValidSort(q.Sort) {
+		return SortCreated, true
+	}
+	return strings.TrimPrefix(q.Sort, "-"), strings.HasPrefix(q.Sort, "-")
+}
+
+// PinnedPage splits the page of a PinnedFirst query, given the number of
+// `pinned` articles it matches, into where it starts among, and how many it
+// lists of, the pinned articles leading it, and where it starts among, and
+// how many it lists of, the others. Pages span the pinned articles and then
+// the others, as one listing. A negative limit is of none of the others,
+// and zero, as for Limit, of all of them.
+func (q Query) PinnedPage(pinned int) (pinnedSkip, lead, skip, limit int) {
+	if q.Limit <= 0 {
+		if q.Page == 0 {
+			lead = pinned
+		}
+		return 0, lead, 0, 0
+	}
+	start := q.Page * q.Limit
+	if start < pinned {
+		pinnedSkip = start
+		if lead = pinned - start; lead > q.Limit {
+			lead = q.Limit
+		}
+	} else {
+		pinnedSkip, skip = pinned, start-pinned
+	}
+	if lead == q.Limit {
+		return pinnedSkip, lead, 0, -1
+	}
+	return pinnedSkip, lead, skip, q.Limit - lead
+}
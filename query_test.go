@@ -0,0 +1,168 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedQuery(t *testing.T) {
+	day := time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)
+	b := newMemBackend(
+		&Article{TitlePath: "2013/06/01/pinned/", IsPublished: true, Pinned: true, Created: day, Tags: []string{"go"}},
+		&Article{TitlePath: "2013/06/02/newer/", IsPublished: true, Created: day.AddDate(0, 0, 1), Tags: []string{"go"}},
+		&Article{TitlePath: "2013/06/03/draft/", Created: day.AddDate(0, 0, 2), Tags: []string{"go"}},
+		&Article{TitlePath: "2013/06/04/other/", IsPublished: true, Created: day.AddDate(0, 0, 3)})
+
+	// Recent puts the pinned article first, feeds don't
+	o, _ := b.Recent(10, 0, false)
+	if list := o.([]*Article); list[0].TitlePath != "2013/06/01/pinned/" {
+		t.Errorf("Recent starts with %s, want the pinned article", list[0].TitlePath)
+	}
+	o, _ = b.Find(FeedQuery(10, "go"))
+	list := o.([]*Article)
+	want := []string{"2013/06/02/newer/", "2013/06/01/pinned/"}
+	if len(list) != len(want) {
+		t.Fatalf("feed has %d articles, want %d", len(list), len(want))
+	}
+	for i, tp := range want {
+		if list[i].TitlePath != tp {
+			t.Errorf("feed[%d] = %s, want %s", i, list[i].TitlePath, tp)
+		}
+	}
+}
+
+func TestPinnedPage(t *testing.T) {
+	tests := []struct {
+		limit, page, pinned int
+		pinnedSkip, lead    int
+		skip, restLimit     int
+	}{
+		{10, 0, 0, 0, 0, 0, 10},
+		{10, 0, 2, 0, 2, 0, 8},
+		{10, 1, 2, 2, 0, 8, 10},
+		{10, 2, 2, 2, 0, 18, 10},
+		{10, 0, 10, 0, 10, 0, -1},
+		{10, 1, 10, 10, 0, 0, 10},
+
+		// More pinned than fit a page, paged through first
+		{10, 0, 12, 0, 10, 0, -1},
+		{10, 1, 12, 10, 2, 0, 8},
+		{10, 2, 12, 12, 0, 8, 10},
+		{10, 2, 25, 20, 5, 0, 5},
+
+		{0, 0, 2, 0, 2, 0, 0},
+		{0, 1, 2, 0, 0, 0, 0},
+	}
+	for _, test := range tests {
+		q := Query{Limit: test.limit, Page: test.page, PinnedFirst: true}
+		pinnedSkip, lead, skip, limit := q.PinnedPage(test.pinned)
+		if pinnedSkip != test.pinnedSkip || lead != test.lead || skip != test.skip || limit != test.restLimit {
+			t.Errorf("PinnedPage(%d) of limit %d, page %d = %d, %d, %d, %d, want %d, %d, %d, %d",
+				test.pinned, test.limit, test.page, pinnedSkip, lead, skip, limit,
+				test.pinnedSkip, test.lead, test.skip, test.restLimit)
+		}
+	}
+}
+
+func TestRecentPinned(t *testing.T) {
+	day := time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)
+	var list []*Article
+	for i := 0; i < 5; i++ {
+		list = append(list, &Article{TitlePath: fmt.Sprintf("2013/06/0%d/a/", i+1),
+			IsPublished: true, Created: day.AddDate(0, 0, i)})
+	}
+	b := newMemBackend(list...)
+	for _, tp := range []string{"2013/06/01/a/", "2013/06/03/a/"} {
+		a, _ := b.article(tp)
+		if err := a.Pin(true); err != nil || !a.Pinned {
+			t.Fatalf("Pin(true) = %v, pinned %v", err, a.Pinned)
+		}
+	}
+
+	// Pinned first, the others following on without
+	// any being left out or repeated
+	pages := [][]string{
+		{"2013/06/03/a/", "2013/06/01/a/"},
+		{"2013/06/05/a/", "2013/06/04/a/"},
+		{"2013/06/02/a/"},
+	}
+	for page, want := range pages {
+		o, _ := b.Recent(2, page, false)
+		if got := titlePaths(o.([]*Article)); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("page %d = %v, want %v", page, got, want)
+		}
+	}
+
+	// More pinned than fit a page follow on from it
+	a, _ := b.article("2013/06/02/a/")
+	a.Pin(true)
+	pages = [][]string{
+		{"2013/06/03/a/", "2013/06/02/a/"},
+		{"2013/06/01/a/", "2013/06/05/a/"},
+		{"2013/06/04/a/"},
+	}
+	for page, want := range pages {
+		o, _ := b.Recent(2, page, false)
+		if got := titlePaths(o.([]*Article)); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("page %d = %v, want %v", page, got, want)
+		}
+	}
+	a.Pin(false)
+
+	// Unpinning puts it back in order
+	a, _ = b.article("2013/06/03/a/")
+	if err := a.Pin(false); err != nil || a.Pinned {
+		t.Fatalf("Pin(false) = %v, pinned %v", err, a.Pinned)
+	}
+	o, _ := b.Recent(3, 0, false)
+	if got, want := titlePaths(o.([]*Article)), "2013/06/01/a/ 2013/06/05/a/ 2013/06/04/a/"; strings.Join(got, " ") != want {
+		t.Errorf("after unpinning, got %v, want %s", got, want)
+	}
+}
+
+func TestFeatured(t *testing.T) {
+	day := time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)
+	b := newMemBackend(
+		&Article{TitlePath: "2013/06/01/a/", IsPublished: true, Created: day},
+		&Article{TitlePath: "2013/06/02/b/", IsPublished: true, Created: day.AddDate(0, 0, 1)},
+		&Article{TitlePath: "2013/06/03/c/", IsPublished: true, Created: day.AddDate(0, 0, 2)})
+	for _, tp := range []string{"2013/06/01/a/", "2013/06/02/b/"} {
+		a, _ := b.article(tp)
+		if err := a.Feature(true); err != nil || !a.Featured {
+			t.Fatalf("Feature(true) = %v, featured %v", err, a.Featured)
+		}
+	}
+
+	o, _ := b.Featured(10)
+	if got, want := titlePaths(o.([]*Article)), "2013/06/02/b/ 2013/06/01/a/"; strings.Join(got, " ") != want {
+		t.Errorf("Featured = %v, want %s", got, want)
+	}
+	o, _ = b.Featured(1)
+	if got := titlePaths(o.([]*Article)); len(got) != 1 || got[0] != "2013/06/02/b/" {
+		t.Errorf("Featured(1) = %v, want the newest", got)
+	}
+
+	a, _ := b.article("2013/06/02/b/")
+	a.Feature(false)
+	if stored, _ := b.article(a.TitlePath); stored.Featured {
+		t.Error("Feature(false) wasn't stored")
+	}
+	o, _ = b.Featured(10)
+	if got := titlePaths(o.([]*Article)); len(got) != 1 || got[0] != "2013/06/01/a/" {
+		t.Errorf("Featured after unfeaturing = %v", got)
+	}
+}
+
+func titlePaths(list []*Article) []string {
+	s := make([]string, len(list))
+	for i, a := range list {
+		s[i] = a.TitlePath
+	}
+	return s
+}
@@ -0,0 +1,245 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Related is a lightweight summary of an article related to another.
+type Related struct {
+	TitlePath string  `json:"titlePath" bson:"titlePath"`
+	Title     string  `json:"title" bson:"title"`
+	Synopsis  string  `json:"synopsis" bson:"synopsis"`
+	Img       Img     `json:"img" bson:"img"`
+	Score     float64 `json:"score" bson:"-"`
+}
+
+// Recommender finds the articles most related to a given article.
+type Recommender interface {
+	Related(a *Article, limit int, unPublished bool) ([]Related, error)
+}
+
+// TermRecommender is an in-process Recommender, that ranks the most recent
+// articles of any Reader by shared tags and term overlap.
+type TermRecommender struct {
+	Reader      Reader
+	ItoArticles func(o interface{}) []*Article
+	Candidates  int
+	TagWeight   float64
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true, "has": true,
+	"have": true, "in": true, "is": true, "it": true, "its": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "this": true, "to": true,
+	"was": true, "were": true, "will": true, "with": true, "you": true, "your": true,
+}
+
+func NewRecommender(r Reader) *TermRecommender {
+	return &TermRecommender{Reader: r,
+		ItoArticles: ToArticles,
+		Candidates:  100,
+		TagWeight:   2}
+}
+
+// ToArticles converts the list returned by `Reader.Recent` into articles,
+// returning nil for types it doesn't know about.
+func ToArticles(o interface{}) []*Article {
+	switch s := o.(type) {
+	case []*Article:
+		return s
+	case *[]*Article:
+		return *s
+	case []Article:
+		a := make([]*Article, len(s))
+		for i := range s {
+			a[i] = &s[i]
+		}
+		return a
+	case *[]Article:
+		return ToArticles(*s)
+	}
+	return nil
+}
+
+func (r *TermRecommender) Related(a *Article, limit int, unPublished bool) ([]Related, error) {
+	o, err := r.Reader.Recent(r.Candidates, 0, unPublished)
+	if err != nil {
+		return nil, err
+	}
+
+	// Score every candidate against the article
+	terms := articleTerms(a)
+	var related []Related
+	if limit > 0 {
+		related = make([]Related, 0, limit)
+	}
+	for _, c := range r.ItoArticles(o) {
+		if c.TitlePath == a.TitlePath {
+			continue
+		}
+		score := r.TagWeight*tagOverlap(a.Tags, c.Tags) + cosine(terms, articleTerms(c))
+		if score > 0 {
+			related = append(related, Related{c.TitlePath, c.Title, c.Summary(), c.Img, score})
+		}
+	}
+
+	// Rank, highest score first
+	sort.SliceStable(related, func(i, j int) bool {
+		return related[i].Score > related[j].Score
+	})
+	if limit >= 0 && len(related) > limit {
+		related = related[:limit]
+	}
+	return related, nil
+}
+
+// RelatedCache caches the related articles of each article, found by
+// Recommender, so they aren't scored on every view. It's invalidated by any
+// change of content or tags, as those change the scores of every article,
+// and entries expire after MaxAge, for changes made by other processes.
+type RelatedCache struct {
+	Recommender Recommender
+	MaxAge      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]relatedEntry
+}
+
+type relatedEntry struct {
+	related []Related
+	found   time.Time
+}
+
+func NewRelatedCache(r Recommender) *RelatedCache {
+	return &RelatedCache{Recommender: r, MaxAge: time.Hour,
+		entries: make(map[string]relatedEntry)}
+}
+
+func (c *RelatedCache) Related(a *Article, limit int, unPublished bool) ([]Related, error) {
+	key := fmt.Sprintf("%s|%d|%t", a.TitlePath, limit, unPublished)
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && (c.MaxAge <= 0 || time.Since(e.found) < c.MaxAge) {
+		return e.related, nil
+	}
+
+	related, err := c.Recommender.Related(a, limit, unPublished)
+	if err != nil {
+		return related, err
+	}
+	c.mu.Lock()
+	c.entries[key] = relatedEntry{related, time.Now()}
+	c.mu.Unlock()
+	return related, nil
+}
+
+// Invalidate has the related articles of every article found again.
+func (c *RelatedCache) Invalidate() {
+	c.mu.Lock()
+	c.entries = make(map[string]relatedEntry)
+	c.mu.Unlock()
+}
+
+// tagOverlap is the Jaccard index of two tag sets.
+func tagOverlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := make(map[string]bool, len(a))
+	for _, t := range a {
+		set[strings.ToLower(t)] = true
+	}
+	shared, union := 0, len(set)
+	for _, t := range b {
+		if t = strings.ToLower(t); set[t] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	return float64(shared) / float64(union)
+}
+
+// articleTerms weighs the terms of the title above those of the synopsis,
+// which weigh above those of the content.
+func articleTerms(a *Article) map[string]float64 {
+	terms := make(map[string]float64)
+	addTerms(terms, a.Title, 3)
+	addTerms(terms, a.Synopsis, 2)
+	addTerms(terms, StripTags(a.Content), 1)
+	return terms
+}
+
+func addTerms(terms map[string]float64, s string, weight float64) {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, w := range words {
+		if len(w) > 2 && !stopWords[w] {
+			terms[w] += weight
+		}
+	}
+}
+
+func cosine(a, b map[string]float64) float64 {
+	var dot, na, nb float64
+	for t, v := range a {
+		dot += v * b[t]
+		na += v * v
+	}
+	for _, v := range b {
+		nb += v * v
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+var blockTags = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "br": true, "dd": true,
+	"div": true, "dl": true, "dt": true, "figcaption": true, "figure": true,
+	"footer": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "header": true, "hr": true, "li": true, "ol": true, "p": true,
+	"pre": true, "section": true, "table": true, "td": true, "th": true,
+	"tr": true, "ul": true,
+}
+
+// StripTags returns `s` without any HTML tags, separating the text of block
+// elements with a space.
+func StripTags(s string) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '<' {
+			b = append(b, s[i])
+			continue
+		}
+		end := strings.IndexByte(s[i:], '>')
+		if end < 0 {
+			// Not a tag, so the rest is text
+			b = append(b, s[i:]...)
+			break
+		}
+		name := strings.TrimPrefix(s[i+1:i+end], "/")
+		if n := strings.IndexAny(name, " \t\n/"); n >= 0 {
+			name = name[:n]
+		}
+		if blockTags[strings.ToLower(name)] {
+			b = append(b, ' ')
+		}
+		i += end
+	}
+	return string(b)
+}
@@ -0,0 +1,171 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func relatedBackend() *memBackend {
+	t := time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)
+	return newMemBackend(
+		&Article{TitlePath: "2013/06/01/go-channels/", Title: "Go channels", IsPublished: true, Created: t,
+			Tags: []string{"go", "concurrency"}, Content: "<p>Channels and goroutines in Go.</p>"},
+		&Article{TitlePath: "2013/06/02/go-select/", Title: "Go select", IsPublished: true, Created: t.AddDate(0, 0, 1),
+			Tags: []string{"Go", "concurrency"}, Content: "<p>Select over channels.</p>"},
+		&Article{TitlePath: "2013/06/03/go-maps/", Title: "Go maps", IsPublished: true, Created: t.AddDate(0, 0, 2),
+			Tags: []string{"go"}, Content: "<p>Maps, hashing.</p>"},
+		&Article{TitlePath: "2013/06/04/baking/", Title: "Baking bread", IsPublished: true, Created: t.AddDate(0, 0, 3),
+			Tags: []string{"food"}, Content: "<p>Flour, water, salt.</p>"},
+		&Article{TitlePath: "2013/06/05/go-draft/", Title: "Go channels draft", Created: t.AddDate(0, 0, 4),
+			Tags: []string{"go", "concurrency"}, Content: "<p>Channels.</p>"},
+	)
+}
+
+func TestTagOverlap(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want float64
+	}{
+		{nil, []string{"go"}, 0},
+		{[]string{"go"}, []string{"Go"}, 1},
+		{[]string{"go", "concurrency"}, []string{"go"}, 0.5},
+		{[]string{"go", "concurrency"}, []string{"go", "food"}, 1.0 / 3},
+		{[]string{"go"}, []string{"food"}, 0},
+	}
+	for _, test := range tests {
+		if got := tagOverlap(test.a, test.b); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("tagOverlap(%v, %v) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestCosine(t *testing.T) {
+	a := map[string]float64{"go": 1, "channels": 1}
+	if got := cosine(a, a); math.Abs(got-1) > 1e-9 {
+		t.Errorf("cosine of identical terms = %v, want 1", got)
+	}
+	if got := cosine(a, map[string]float64{"bread": 1}); got != 0 {
+		t.Errorf("cosine of disjoint terms = %v, want 0", got)
+	}
+	if got := cosine(a, nil); got != 0 {
+		t.Errorf("cosine of no terms = %v, want 0", got)
+	}
+}
+
+func TestRelated(t *testing.T) {
+	b := relatedBackend()
+	r := NewRecommender(b)
+	a, _ := b.article("2013/06/01/go-channels/")
+
+	related, err := r.Related(a, 5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Most shared tags and terms first, without the article itself, drafts,
+	// or anything unrelated
+	want := []string{"2013/06/02/go-select/", "2013/06/03/go-maps/"}
+	if len(related) != len(want) {
+		t.Fatalf("got %d related articles, %+v, want %d", len(related), related, len(want))
+	}
+	for i, tp := range want {
+		if related[i].TitlePath != tp {
+			t.Errorf("related[%d] = %s, want %s", i, related[i].TitlePath, tp)
+		}
+	}
+	if related[0].Score <= related[1].Score {
+		t.Errorf("scores aren't descending: %v, %v", related[0].Score, related[1].Score)
+	}
+
+	// Limited
+	if related, _ = r.Related(a, 1, false); len(related) != 1 || related[0].TitlePath != want[0] {
+		t.Errorf("limited to 1, got %+v", related)
+	}
+
+	// Not limited
+	if related, err = r.Related(a, -1, false); err != nil || len(related) != len(want) {
+		t.Errorf("unlimited, got %+v, %v, want %d", related, err, len(want))
+	}
+
+	// Drafts, when asked for
+	related, _ = r.Related(a, 5, true)
+	if len(related) == 0 || related[0].TitlePath != "2013/06/05/go-draft/" {
+		t.Errorf("with unpublished, got %+v, want the draft first", related)
+	}
+}
+
+func TestStripTags(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`<p>a</p><p>b</p>`, ` a  b `},
+		{`a <b>bold</b> word`, `a bold word`},
+		{`a<br/>b`, `a b`},
+		{`<P CLASS="x">a</P>`, ` a `},
+		{`a < b and more`, `a < b and more`},
+		{`<p>a</p> < b`, ` a  < b`},
+	}
+	for _, test := range tests {
+		if got := StripTags(test.in); got != test.want {
+			t.Errorf("StripTags(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestRelatedCache(t *testing.T) {
+	b := relatedBackend()
+	c := NewRelatedCache(NewRecommender(b))
+	a, _ := b.article("2013/06/01/go-channels/")
+
+	first, _ := c.Related(a, 5, false)
+	second, _ := c.Related(a, 5, false)
+	if b.finds != 1 {
+		t.Errorf("candidates loaded %d times, want once", b.finds)
+	}
+	if len(first) != len(second) {
+		t.Errorf("cached %+v, want %+v", second, first)
+	}
+
+	// Other arguments are cached separately
+	c.Related(a, 1, false)
+	if b.finds != 2 {
+		t.Errorf("candidates loaded %d times, want twice", b.finds)
+	}
+
+	// Changes of tags invalidate it
+	maps, _ := b.article("2013/06/03/go-maps/")
+	p := &NotifyPrinter{Printer: b, OnChange: c.Invalidate}
+	maps.Printer = p
+	if err := maps.SetTags([]string{"food"}); err != nil {
+		t.Fatal(err)
+	}
+	related, _ := c.Related(a, 5, false)
+	if b.finds != 3 {
+		t.Errorf("candidates loaded %d times after invalidating, want 3", b.finds)
+	}
+	if score(related, maps.TitlePath) >= score(first, maps.TitlePath) {
+		t.Errorf("retagged article scored %v, before %v", score(related, maps.TitlePath), score(first, maps.TitlePath))
+	}
+
+	// and entries expire
+	c.MaxAge = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	c.Related(a, 5, false)
+	if b.finds != 4 {
+		t.Errorf("candidates loaded %d times after expiring, want 4", b.finds)
+	}
+}
+
+func score(related []Related, titlePath string) float64 {
+	for _, r := range related {
+		if r.TitlePath == titlePath {
+			return r.Score
+		}
+	}
+	return 0
+}
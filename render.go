@@ -0,0 +1,96 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"html"
+	"strings"
+)
+
+// ContentFormat is the markup an article's content is written in.
+type ContentFormat string
+
+const (
+	FormatHTML     ContentFormat = "html"
+	FormatMarkdown ContentFormat = "markdown"
+	FormatPlain    ContentFormat = "plain"
+)
+
+// FormatFunc converts content of a given format into HTML.
+type FormatFunc func(content string) string
+
+// Filter transforms the HTML rendered for an article.
+type Filter func(a *Article, html string) string
+
+// Pipeline renders an article's content into HTML, by converting it from its
+// format and then passing it through each of the filters, in order. What
+// depends on other articles, such as links to them, goes in `Views`, which
+// filter the cached rendering each time it's shown.
+type Pipeline struct {
+	Formats map[ContentFormat]FormatFunc
+	Filters []Filter
+	Views   []Filter
+}
+
+func NewPipeline() *Pipeline {
+	return &Pipeline{Formats: map[ContentFormat]FormatFunc{
+		FormatHTML:     func(s string) string { return s },
+		FormatMarkdown: Markdown,
+		FormatPlain:    Plain,
+	}}
+}
+
+// ValidFormat reports if `f` is one of the known content formats.
+func ValidFormat(f ContentFormat) bool {
+	switch f {
+	case FormatHTML, FormatMarkdown, FormatPlain:
+		return true
+	}
+	return false
+}
+
+// Plain converts plain text into HTML paragraphs, escaping it.
+func Plain(s string) string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	paragraphs := strings.Split(s, "\n\n")
+	out := make([]string, 0, len(paragraphs))
+	for _, p := range paragraphs {
+		if p = strings.TrimSpace(p); p != "" {
+			p = strings.Replace(html.EscapeString(p), "\n", "<br>\n", -1)
+			out = append(out, "<p>"+p+"</p>")
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// Convert converts the content of `a` from its format into HTML, without
+// any of the filters. Content without a format is HTML.
+func (p *Pipeline) Convert(a *Article) string {
+	format, ok := p.Formats[a.ContentFormat]
+	if !ok {
+		format, ok = p.Formats[FormatHTML]
+	}
+	if !ok {
+		return a.Content
+	}
+	return format(a.Content)
+}
+
+// Render converts the content of `a`, without using the cached rendering.
+func (p *Pipeline) Render(a *Article) string {
+	s := p.Convert(a)
+	for _, f := range p.Filters {
+		s = f(a, s)
+	}
+	return s
+}
+
+// View applies the view filters to the rendered content `s` of `a`.
+func (p *Pipeline) View(a *Article, s string) string {
+	for _, f := range p.Views {
+		s = f(a, s)
+	}
+	return s
+}
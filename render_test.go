@@ -0,0 +1,133 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import "testing"
+
+func TestPipelineConvert(t *testing.T) {
+	p := NewPipeline()
+	tests := []struct {
+		format  ContentFormat
+		content string
+		want    string
+	}{
+		{FormatHTML, "<p>a</p>", "<p>a</p>"},
+		{"", "<p>a</p>", "<p>a</p>"},
+		{"unknown", "<p>a</p>", "<p>a</p>"},
+		{FormatMarkdown, "*a*", "<p><em>a</em></p>\n"},
+		{FormatPlain, "<a>", "<p>&lt;a&gt;</p>"},
+	}
+	for _, test := range tests {
+		a := &Article{ContentFormat: test.format, Content: test.content}
+		if got := p.Convert(a); got != test.want {
+			t.Errorf("Convert(%q, %q) = %q, want %q", test.format, test.content, got, test.want)
+		}
+	}
+}
+
+func TestPipelineFilters(t *testing.T) {
+	p := NewPipeline()
+	p.Filters = []Filter{
+		func(a *Article, s string) string { return s + "1" },
+		func(a *Article, s string) string { return s + "2" },
+	}
+	if got := p.Render(&Article{Content: "x"}); got != "x12" {
+		t.Errorf("Render = %q, want the filters applied in order", got)
+	}
+}
+
+func TestArticleRender(t *testing.T) {
+	b := newMemBackend(&Article{TitlePath: "2013/06/01/a/", Content: "*a*", ContentFormat: FormatMarkdown})
+	a, _ := b.article("2013/06/01/a/")
+	p := NewPipeline()
+
+	rendered, err := a.Render(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := b.article(a.TitlePath); stored.Rendered != rendered {
+		t.Errorf("rendering cached as %q, want %q", stored.Rendered, rendered)
+	}
+
+	// Cached, until the content changes
+	p.Filters = []Filter{func(a *Article, s string) string { return "changed" }}
+	if got, _ := a.Render(p); got != rendered {
+		t.Errorf("Render = %q, want the cached %q", got, rendered)
+	}
+	a.SetContent("*b*")
+	if got, _ := a.Render(p); got != "changed" {
+		t.Errorf("Render after SetContent = %q, want it rendered again", got)
+	}
+}
+
+func TestArticleRenderViews(t *testing.T) {
+	b := newMemBackend(
+		&Article{TitlePath: "2013/06/01/a/", Content: `<p><a href="article:2013/06/02/b/#c">b</a></p>`},
+		&Article{TitlePath: "2013/06/02/b/"})
+	p := NewPipeline()
+	p.Views = []Filter{NewLinks(b, "/articles", "/media/", "").Filter}
+
+	// Links to an unpublished article are broken, but only when shown
+	a, _ := b.article("2013/06/01/a/")
+	if got, want := mustRender(t, a, p), `<p><a class="broken-link">b</a></p>`; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+	if stored, _ := b.article(a.TitlePath); stored.Rendered != a.Content {
+		t.Errorf("rendering cached as %q, want the link unresolved", stored.Rendered)
+	}
+
+	// so they're resolved once it's published, from the cache
+	b.Publish("2013/06/02/b/", true)
+	a, _ = b.article("2013/06/01/a/")
+	if got, want := mustRender(t, a, p), `<p><a href="/articles/2013/06/02/b/#c">b</a></p>`; got != want {
+		t.Errorf("Render after publishing = %q, want %q", got, want)
+	}
+
+	// Rendering again leaves them be, and views never replace the cache
+	if got, want := mustRender(t, a, p), `<p><a href="/articles/2013/06/02/b/#c">b</a></p>`; got != want {
+		t.Errorf("Render again = %q, want %q", got, want)
+	}
+	if a.Rendered != a.Content {
+		t.Errorf("rendering kept as %q, want the link unresolved", a.Rendered)
+	}
+}
+
+func mustRender(t *testing.T, a *Article, p *Pipeline) string {
+	s, err := a.Render(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestClearRendered(t *testing.T) {
+	changes := map[string]func(a *Article) error{
+		"SetContent":       func(a *Article) error { return a.SetContent("d") },
+		"SetContentFormat": func(a *Article) error { return a.SetContentFormat(FormatPlain) },
+		"SetImg":           func(a *Article) error { return a.SetImg(Img{Src: "a.png"}) },
+		"SetImgs":          func(a *Article) error { return a.SetImgs([]Img{{Src: "a.png"}}) },
+	}
+	for name, change := range changes {
+		b := newMemBackend(&Article{TitlePath: "2013/06/01/a/", Content: "a", Rendered: "<p>a</p>",
+			Translations: []Translation{
+				{Lang: "fr", Content: "b", Rendered: "<p>b</p>"},
+				{Lang: "de", Content: "c", Rendered: "<p>c</p>"}}})
+		a, _ := b.article("2013/06/01/a/")
+		if err := change(a); err != nil {
+			t.Fatal(err)
+		}
+		stored, _ := b.article(a.TitlePath)
+		for _, a := range []*Article{a, stored} {
+			if a.Rendered != "" {
+				t.Errorf("%s: rendering %q kept", name, a.Rendered)
+			}
+			for _, tr := range a.Translations {
+				if tr.Rendered != "" {
+					t.Errorf("%s: rendering %q of translation %s kept", name, tr.Rendered, tr.Lang)
+				}
+			}
+		}
+	}
+}
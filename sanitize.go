@@ -0,0 +1,211 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// Stripped describes something a Policy removed from content.
+type Stripped struct {
+	Tag   string `json:"tag"`
+	Attr  string `json:"attr,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// Policy is an allowlist of the HTML that content may contain. Tags not
+// allowed are removed, keeping their text, except for those in `Drop`
+// which are removed along with their content.
+type Policy struct {
+	Tags       map[string]bool
+	Attrs      map[string]map[string]bool // by tag, "*" for any tag
+	URLAttrs   map[string]bool
+	Schemes    map[string]bool
+	EmbedHosts map[string]bool // allowed hosts of iframe sources
+	Drop       map[string]bool
+}
+
+func NewPolicy() *Policy {
+	p := &Policy{Tags: make(map[string]bool),
+		Attrs:      make(map[string]map[string]bool),
+		URLAttrs:   map[string]bool{"href": true, "src": true, "cite": true, "poster": true},
+		Schemes:    make(map[string]bool),
+		EmbedHosts: make(map[string]bool),
+		Drop: map[string]bool{"script": true, "style": true, "iframe": true,
+			"object": true, "embed": true, "applet": true, "noscript": true,
+			"template": true, "title": true, "textarea": true, "select": true},
+	}
+	return p
+}
+
+// DefaultPolicy allows the formatting, structure, links, including internal
+// `article:` links, images and media of an article, embeds from well known
+// video hosts, and nothing else.
+func DefaultPolicy() *Policy {
+	p := NewPolicy()
+	p.AllowTags("a", "abbr", "b", "blockquote", "br", "caption", "cite", "code",
+		"col", "colgroup", "dd", "del", "details", "dfn", "div", "dl", "dt", "em",
+		"figcaption", "figure", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "i", "img",
+		"ins", "kbd", "li", "mark", "ol", "p", "pre", "q", "s", "samp", "small",
+		"span", "strong", "sub", "summary", "sup", "table", "tbody", "td", "tfoot",
+		"th", "thead", "tr", "u", "ul", "var", "audio", "video", "source", "iframe")
+	p.AllowAttrs("*", "class", "id", "title", "lang", "dir")
+	p.AllowAttrs("a", "href", "rel", "name")
+	p.AllowAttrs("img", "src", "alt", "width", "height")
+	p.AllowAttrs("blockquote", "cite")
+	p.AllowAttrs("q", "cite")
+	p.AllowAttrs("ol", "start", "reversed")
+	p.AllowAttrs("td", "colspan", "rowspan")
+	p.AllowAttrs("th", "colspan", "rowspan", "scope")
+	p.AllowAttrs("audio", "src", "controls")
+	p.AllowAttrs("video", "src", "controls", "poster", "width", "height")
+	p.AllowAttrs("source", "src", "type")
+	p.AllowAttrs("iframe", "src", "width", "height", "frameborder", "allowfullscreen")
+	p.AllowSchemes("http", "https", "mailto", "article")
+	p.AllowEmbeds("www.youtube.com", "www.youtube-nocookie.com", "player.vimeo.com")
+	return p
+}
+
+func (p *Policy) AllowTags(tags ...string) {
+	for _, t := range tags {
+		p.Tags[strings.ToLower(t)] = true
+	}
+}
+
+func (p *Policy) AllowAttrs(tag string, attrs ...string) {
+	tag = strings.ToLower(tag)
+	if p.Attrs[tag] == nil {
+		p.Attrs[tag] = make(map[string]bool)
+	}
+	for _, a := range attrs {
+		p.Attrs[tag][strings.ToLower(a)] = true
+	}
+}
+
+func (p *Policy) AllowSchemes(schemes ...string) {
+	for _, s := range schemes {
+		p.Schemes[strings.ToLower(s)] = true
+	}
+}
+
+func (p *Policy) AllowEmbeds(hosts ...string) {
+	for _, h := range hosts {
+		p.EmbedHosts[strings.ToLower(h)] = true
+	}
+}
+
+// allowedURL reports if `s` is relative, or uses an allowed scheme.
+func (p *Policy) allowedURL(s string) bool {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" || p.Schemes[strings.ToLower(u.Scheme)]
+}
+
+// allowedEmbed reports if the iframe source `s` is from an allowed host.
+func (p *Policy) allowedEmbed(s string) bool {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || !p.Schemes[strings.ToLower(u.Scheme)] {
+		return false
+	}
+	return p.EmbedHosts[strings.ToLower(u.Host)]
+}
+
+// Sanitize removes everything from the HTML `s` that isn't allowed by the
+// policy, balancing its tags, and reports what was removed.
+func (p *Policy) Sanitize(s string) (string, []Stripped) {
+	var stripped []Stripped
+	var out, open []token
+	drop := 0 // depth within dropped elements
+
+	for _, t := range tokenize(s) {
+		switch t.Type {
+		case textToken:
+			if drop == 0 {
+				t.Data = html.EscapeString(html.UnescapeString(t.Data))
+				out = append(out, t)
+			}
+
+		case commentToken:
+			// removed, quietly
+
+		case startToken:
+			if drop > 0 {
+				if p.Drop[t.Name] && !t.SelfClosing && !voidTags[t.Name] {
+					drop++
+				}
+				continue
+			}
+
+			allowed := p.Tags[t.Name]
+			if t.Name == "iframe" && allowed {
+				src, _ := t.Attr("src")
+				if allowed = p.allowedEmbed(src); !allowed {
+					stripped = append(stripped, Stripped{t.Name, "src", src})
+				}
+			} else if !allowed {
+				stripped = append(stripped, Stripped{Tag: t.Name})
+			}
+			if !allowed {
+				if p.Drop[t.Name] && !t.SelfClosing && !voidTags[t.Name] {
+					drop++
+				}
+				continue
+			}
+
+			// Keep the allowed attributes
+			var attrs []attr
+			for _, a := range t.Attrs {
+				switch {
+				case !p.Attrs["*"][a.Key] && !p.Attrs[t.Name][a.Key]:
+					stripped = append(stripped, Stripped{t.Name, a.Key, a.Val})
+				case p.URLAttrs[a.Key] && !p.allowedURL(a.Val):
+					stripped = append(stripped, Stripped{t.Name, a.Key, a.Val})
+				default:
+					attrs = append(attrs, a)
+				}
+			}
+			t.Attrs = attrs
+			out = append(out, t)
+			if !t.SelfClosing && !voidTags[t.Name] {
+				open = append(open, token{Type: endToken, Name: t.Name})
+			}
+
+		case endToken:
+			if drop > 0 {
+				if p.Drop[t.Name] {
+					drop--
+				}
+				continue
+			}
+
+			// Close the most recent matching tag, along with any left open within it
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i].Name == t.Name {
+					for j := len(open) - 1; j >= i; j-- {
+						out = append(out, open[j])
+					}
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+
+	// Close whatever was left open
+	for i := len(open) - 1; i >= 0; i-- {
+		out = append(out, open[i])
+	}
+	return render(out), stripped
+}
+
+// Filter sanitizes rendered content, as a step of a Pipeline.
+func (p *Policy) Filter(a *Article, s string) string {
+	s, _ = p.Sanitize(s)
+	return s
+}
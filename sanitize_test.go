@@ -0,0 +1,190 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var sanitizeTests = []struct {
+	name, in, want string
+}{
+	// Script and style, dropped along with their content
+	{"script", `<p>a<script>alert(1)</script>b</p>`, `<p>ab</p>`},
+	{"script upper", `<SCRIPT SRC=x></SCRIPT>x`, `x`},
+	{"script markup", `<script>if (a < b) { document.write("</p>") }</script>ok`, `ok`},
+	{"style", `<style>p { color: red }</style><p>x</p>`, `<p>x</p>`},
+	{"svg script", `<svg><script>x</script></svg>`, ``},
+	{"comment", `<!-- <script>x</script> -->ok`, `ok`},
+
+	// Event handlers
+	{"onclick", `<p onclick="x" class="c">t</p>`, `<p class="c">t</p>`},
+	{"on upper", `<p ONMOUSEOVER=y>t</p>`, `<p>t</p>`},
+	{"onerror", `<img src=x onerror=alert(1)//>`, `<img src="x">`},
+
+	// URL schemes
+	{"http", `<a href="http://example.com">x</a>`, `<a href="http://example.com">x</a>`},
+	{"relative", `<a href="/a/b?c=d">x</a>`, `<a href="/a/b?c=d">x</a>`},
+	{"mailto", `<a href="mailto:a@example.com">x</a>`, `<a href="mailto:a@example.com">x</a>`},
+	{"article", `<a href="article:2013/06/01/a/#b">x</a>`, `<a href="article:2013/06/01/a/#b">x</a>`},
+	{"javascript", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+	{"javascript case", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+	{"javascript spaced", `<a href=" javascript:alert(1)">x</a>`, `<a>x</a>`},
+	{"javascript tab", "<a href=\"java\tscript:alert(1)\">x</a>", `<a>x</a>`},
+	{"data href", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, `<a>x</a>`},
+	{"data src", `<img src="data:image/png;base64,AAA">`, `<img>`},
+	{"duplicate href", `<a href="x" href="javascript:y">d</a>`, `<a href="x">d</a>`},
+
+	// Entity encoded schemes are decoded before they're checked
+	{"decimal entity", `<a href="java&#115;cript:alert(1)">x</a>`, `<a>x</a>`},
+	{"hex entity", `<a href="&#x6A;avascript:alert(1)">x</a>`, `<a>x</a>`},
+	{"named entity", `<a href="javascript&colon;alert(1)">x</a>`, `<a>x</a>`},
+	{"newline entity", `<a href="java&#10;script:alert(1)">x</a>`, `<a>x</a>`},
+	{"control entity", `<a href="&#1;javascript:alert(1)">x</a>`, `<a>x</a>`},
+
+	// Unclosed and misnested tags are balanced
+	{"unclosed", `<p><b>unclosed`, `<p><b>unclosed</b></p>`},
+	{"stray end", `</b>stray<p>x`, `stray<p>x</p>`},
+	{"misnested", `<b><i>mis</b>nested</i>`, `<b><i>mis</i></b>nested`},
+	{"broken tag", `<p <script>x</p>`, `<p>x</p>`},
+	{"void", `<br/><hr>`, `<br /><hr>`},
+
+	// Attribute values are quoted and escaped
+	{"single quotes", `<a title='it"s' href=x>q</a>`, `<a title="it&#34;s" href="x">q</a>`},
+	{"unquoted", `<a title=unq'oted>q</a>`, `<a title="unq&#39;oted">q</a>`},
+	{"entities kept", `<a title="a&amp;b&lt;" href=x>q</a>`, `<a title="a&amp;b&lt;" href="x">q</a>`},
+	{"markup in value", `<img src=x alt="<script>">`, `<img src="x" alt="&lt;script&gt;">`},
+	{"text", `<p>1 < 2 & 3 > 2</p>`, `<p>1 &lt; 2 &amp; 3 &gt; 2</p>`},
+
+	// Embeds
+	{"embed allowed", `<iframe src="https://www.youtube.com/embed/x"></iframe>`, `<iframe src="https://www.youtube.com/embed/x"></iframe>`},
+	{"embed other host", `<iframe src="https://example.com/x">fallback<b>in</b></iframe>after`, `after`},
+}
+
+func TestSanitize(t *testing.T) {
+	p := DefaultPolicy()
+	for _, test := range sanitizeTests {
+		if got, _ := p.Sanitize(test.in); got != test.want {
+			t.Errorf("%s: Sanitize(%q)\n got %q\nwant %q", test.name, test.in, got, test.want)
+		}
+	}
+}
+
+func TestSanitizeStripped(t *testing.T) {
+	p := DefaultPolicy()
+	_, stripped := p.Sanitize(`<p onclick="x">a</p><a href="java&#115;cript:y">b</a><script>c</script>`)
+	want := []Stripped{
+		{Tag: "p", Attr: "onclick", Value: "x"},
+		{Tag: "a", Attr: "href", Value: "javascript:y"},
+		{Tag: "script"},
+	}
+	if len(stripped) != len(want) {
+		t.Fatalf("stripped %+v, want %+v", stripped, want)
+	}
+	for i := range want {
+		if stripped[i] != want[i] {
+			t.Errorf("stripped[%d] = %+v, want %+v", i, stripped[i], want[i])
+		}
+	}
+
+	if _, stripped = p.Sanitize(`<p class="c"><a href="/x">ok</a></p>`); len(stripped) != 0 {
+		t.Errorf("stripped %+v from allowed content", stripped)
+	}
+}
+
+func TestPolicyAllow(t *testing.T) {
+	p := NewPolicy()
+	p.AllowTags("a")
+	p.AllowAttrs("a", "href")
+	p.AllowSchemes("ftp")
+
+	tests := []struct {
+		in, want string
+	}{
+		{`<a href="ftp://example.com/f">x</a>`, `<a href="ftp://example.com/f">x</a>`},
+		{`<a href="http://example.com">x</a>`, `<a>x</a>`},
+		{`<a title="t" href="/f">x</a>`, `<a href="/f">x</a>`},
+		{`<p><a href="/f">x</a></p>`, `<a href="/f">x</a>`},
+	}
+	for _, test := range tests {
+		if got, _ := p.Sanitize(test.in); got != test.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in    string
+		types []tokenType
+		out   string
+	}{
+		{`a<b>c</b>`, []tokenType{textToken, startToken, textToken, endToken}, `a<b>c</b>`},
+		{`<a href='x' title=y data-x>`, []tokenType{startToken}, `<a href="x" title="y" data-x="">`},
+		{`<br/>`, []tokenType{startToken}, `<br />`},
+		{`<!-- c -->`, []tokenType{commentToken}, `<!-- c -->`},
+		{`1 < 2`, []tokenType{textToken}, `1 < 2`},
+		{`<script>a<b>c</script>`, []tokenType{startToken, textToken, endToken}, `<script>a<b>c</script>`},
+		{`<p`, []tokenType{textToken}, `<p`},
+		{`</ p>`, []tokenType{textToken}, `</ p>`},
+		{`<p>a<b c="d`, []tokenType{startToken, textToken}, `<p>a<b c="d`},
+		{`a</b c`, []tokenType{textToken}, `a</b c`},
+		{`<TITLE>a</b></Title>`, []tokenType{startToken, textToken, endToken}, `<title>a</b></title>`},
+	}
+	for _, test := range tests {
+		tokens := tokenize(test.in)
+		if len(tokens) != len(test.types) {
+			t.Errorf("tokenize(%q) = %+v, want %d tokens", test.in, tokens, len(test.types))
+			continue
+		}
+		for i, tok := range tokens {
+			if tok.Type != test.types[i] {
+				t.Errorf("tokenize(%q)[%d] is type %d, want %d", test.in, i, tok.Type, test.types[i])
+			}
+		}
+		if got := render(tokens); got != test.out {
+			t.Errorf("render(tokenize(%q)) = %q, want %q", test.in, got, test.out)
+		}
+	}
+}
+
+func TestTokenAttrs(t *testing.T) {
+	tok := tokenize(`<A HREF="x&amp;y" Title=t>`)[0]
+	if tok.Name != "a" {
+		t.Errorf("tag name %q, want it lower case", tok.Name)
+	}
+	if v, ok := tok.Attr("href"); !ok || v != "x&y" {
+		t.Errorf("Attr(href) = %q, %v, want it unescaped", v, ok)
+	}
+	tok.SetAttr("title", `"q"`)
+	tok.SetAttr("rel", "nofollow")
+	if got, want := tok.String(), `<a href="x&amp;y" title="&#34;q&#34;" rel="nofollow">`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestTokenizeUnterminated(t *testing.T) {
+	shortcodes := NewShortcodes("/media/")
+	for _, in := range []string{
+		strings.Repeat("<a ", 20000),
+		strings.Repeat(`<a b=`, 20000),
+		strings.Repeat(`<a b="`, 20000),
+		strings.Repeat("</a", 20000),
+	} {
+		start := time.Now()
+		if got := render(tokenize(in)); got != in {
+			t.Errorf("render(tokenize(%.12q...)) changed the input", in)
+		}
+		DefaultPolicy().Sanitize(in)
+		XHTML(in, nil)
+		shortcodes.Expand(&Article{}, in)
+		TOC(Anchors(&Article{}, in))
+		if d := time.Since(start); d > 2*time.Second {
+			t.Errorf("%.12q... took %v to tokenize", in, d)
+		}
+	}
+}
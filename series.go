@@ -0,0 +1,78 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"errors"
+	"regexp"
+)
+
+// Series is a named, ordered collection of articles.
+type Series struct {
+	Printer    `json:"-" bson:"-"`
+	Name       string   `json:"name" bson:"name"`
+	TitlePaths []string `json:"titlePaths" bson:"titlePaths"`
+}
+
+// SeriesNav is the position of an article within its series.
+type SeriesNav struct {
+	Name string `json:"name"`
+	Part int    `json:"part"`
+	Of   int    `json:"of"`
+	Prev string `json:"prev,omitempty"`
+	Next string `json:"next,omitempty"`
+}
+
+var ErrSeriesOrder = errors.New("Series order must contain exactly the articles of the series")
+
+var seriesNameRe = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+// ValidSeriesName reports if `name` is of the form of a series', which is
+// part of its URL.
+func ValidSeriesName(name string) bool {
+	return seriesNameRe.MatchString(name)
+}
+
+// Nav returns the position of `titlePath` within the series, and false when
+// it isn't part of it.
+func (s *Series) Nav(titlePath string) (n SeriesNav, ok bool) {
+	for i, tp := range s.TitlePaths {
+		if tp != titlePath {
+			continue
+		}
+		n = SeriesNav{Name: s.Name, Part: i + 1, Of: len(s.TitlePaths)}
+		if i > 0 {
+			n.Prev = s.TitlePaths[i-1]
+		}
+		if i < len(s.TitlePaths)-1 {
+			n.Next = s.TitlePaths[i+1]
+		}
+		return n, true
+	}
+	return
+}
+
+// Reorder persists a new order for the series, which must hold the same
+// articles as the current one.
+func (s *Series) Reorder(titlePaths []string) (err error) {
+	if len(titlePaths) != len(s.TitlePaths) {
+		return ErrSeriesOrder
+	}
+	set := make(map[string]bool, len(s.TitlePaths))
+	for _, tp := range s.TitlePaths {
+		set[tp] = true
+	}
+	for _, tp := range titlePaths {
+		if !set[tp] {
+			return ErrSeriesOrder
+		}
+		delete(set, tp)
+	}
+
+	if err = s.Printer.ReorderSeries(s.Name, titlePaths); err == nil {
+		s.TitlePaths = titlePaths
+	}
+	return
+}
@@ -0,0 +1,68 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSeriesNav(t *testing.T) {
+	s := &Series{Name: "go", TitlePaths: []string{"a/", "b/", "c/"}}
+	tests := []struct {
+		titlePath string
+		ok        bool
+		nav       SeriesNav
+	}{
+		{"a/", true, SeriesNav{Name: "go", Part: 1, Of: 3, Next: "b/"}},
+		{"b/", true, SeriesNav{Name: "go", Part: 2, Of: 3, Prev: "a/", Next: "c/"}},
+		{"c/", true, SeriesNav{Name: "go", Part: 3, Of: 3, Prev: "b/"}},
+		{"d/", false, SeriesNav{}},
+	}
+	for _, test := range tests {
+		if nav, ok := s.Nav(test.titlePath); ok != test.ok || nav != test.nav {
+			t.Errorf("Nav(%q) = %+v, %t, want %+v, %t", test.titlePath, nav, ok, test.nav, test.ok)
+		}
+	}
+
+	only := &Series{Name: "one", TitlePaths: []string{"a/"}}
+	if nav, ok := only.Nav("a/"); !ok || nav != (SeriesNav{Name: "one", Part: 1, Of: 1}) {
+		t.Errorf("Nav of the only article = %+v, %t", nav, ok)
+	}
+}
+
+func TestSeriesReorder(t *testing.T) {
+	tests := []struct {
+		name  string
+		order []string
+		err   error
+		want  string
+	}{
+		{"reversed", []string{"c/", "b/", "a/"}, nil, "c/ b/ a/"},
+		{"same", []string{"a/", "b/", "c/"}, nil, "a/ b/ c/"},
+		{"missing", []string{"c/", "a/"}, ErrSeriesOrder, "a/ b/ c/"},
+		{"extra", []string{"c/", "b/", "a/", "d/"}, ErrSeriesOrder, "a/ b/ c/"},
+		{"other", []string{"c/", "d/", "a/"}, ErrSeriesOrder, "a/ b/ c/"},
+		{"repeated", []string{"a/", "a/", "c/"}, ErrSeriesOrder, "a/ b/ c/"},
+		{"empty", nil, ErrSeriesOrder, "a/ b/ c/"},
+	}
+	for _, test := range tests {
+		b := newMemBackend()
+		b.WriteSeries(&Series{Name: "go", TitlePaths: []string{"a/", "b/", "c/"}})
+		s, _ := b.Series("go")
+		if err := s.Reorder(test.order); err != test.err {
+			t.Errorf("%s: Reorder(%v) = %v, want %v", test.name, test.order, err, test.err)
+		}
+
+		// Persisted, or left as it was
+		stored, _ := b.Series("go")
+		if got := strings.Join(stored.TitlePaths, " "); got != test.want {
+			t.Errorf("%s: stored order %s, want %s", test.name, got, test.want)
+		}
+		if got := strings.Join(s.TitlePaths, " "); got != test.want {
+			t.Errorf("%s: order %s, want %s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	shortcode   = regexp.MustCompile(`{{\s*([a-zA-Z]+)((?:\s+(?:"[^"]*"|[^\s"}]+))*)\s*}}`)
+	shortArg    = regexp.MustCompile(`"[^"]*"|[^\s"]+`)
+	youtubeID   = regexp.MustCompile(`^[a-zA-Z0-9_-]{6,}$`)
+	blockCodes  = map[string]bool{"img": true, "gallery": true, "youtube": true}
+	noCodesTags = map[string]bool{"code": true, "pre": true, "script": true, "style": true}
+)
+
+// ShortcodeFunc expands a shortcode of an article into HTML.
+type ShortcodeFunc func(s *Shortcodes, a *Article, args []string) (string, error)
+
+// Shortcodes expands `{{name args...}}` in content, where arguments with
+// spaces are quoted, such as `{{img "name" "a caption"}}`.
+type Shortcodes struct {
+	MediaURL string
+	Codes    map[string]ShortcodeFunc
+}
+
+func NewShortcodes(mediaURL string) *Shortcodes {
+	return &Shortcodes{mediaURL, map[string]ShortcodeFunc{
+		"img":     imgShortcode,
+		"gallery": galleryShortcode,
+		"youtube": youtubeShortcode,
+	}}
+}
+
+// ImgURL is the URL of an article's image.
+func (s *Shortcodes) ImgURL(a *Article, src string) string {
+	return s.MediaURL + a.TitlePath + src
+}
+
+func (s *Shortcodes) figure(a *Article, img Img, caption string) string {
+	alt := img.Alt
+	if alt == "" {
+		alt = caption
+	}
+	f := fmt.Sprintf(`<figure class="article-img"><img src="%s" alt="%s" width="%d" height="%d">`,
+		html.EscapeString(s.ImgURL(a, img.Src)), html.EscapeString(alt), img.W, img.H)
+	if caption != "" {
+		f += "<figcaption>" + html.EscapeString(caption) + "</figcaption>"
+	}
+	return f + "</figure>"
+}
+
+// `{{img "name" "caption"}}`, one of the article's images
+func imgShortcode(s *Shortcodes, a *Article, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("img: missing image name")
+	}
+	caption := strings.Join(args[1:], " ")
+	for _, img := range a.Imgs {
+		if img.Src == args[0] {
+			return s.figure(a, img, caption), nil
+		}
+	}
+	return "", fmt.Errorf("img: unknown image %q", args[0])
+}
+
+// `{{gallery}}`, all of the article's images
+func galleryShortcode(s *Shortcodes, a *Article, args []string) (string, error) {
+	if len(a.Imgs) == 0 {
+		return "", fmt.Errorf("gallery: the article has no images")
+	}
+	g := `<div class="gallery">`
+	for _, img := range a.Imgs {
+		g += s.figure(a, img, "")
+	}
+	return g + "</div>", nil
+}
+
+// `{{youtube id}}`, an embedded video
+func youtubeShortcode(s *Shortcodes, a *Article, args []string) (string, error) {
+	if len(args) == 0 || !youtubeID.MatchString(args[0]) {
+		return "", fmt.Errorf("youtube: missing, or invalid, video id")
+	}
+	return `<div class="video"><iframe src="https://www.youtube-nocookie.com/embed/` + args[0] +
+		`" width="560" height="315" frameborder="0" allowfullscreen></iframe></div>`, nil
+}
+
+// expand replaces the shortcodes within the text `text`, which is unescaped,
+// returning HTML.
+func (s *Shortcodes) expand(a *Article, text string, warnings *[]string) string {
+	var out []string
+	last := 0
+	for _, m := range shortcode.FindAllStringSubmatchIndex(text, -1) {
+		name := text[m[2]:m[3]]
+		fn, ok := s.Codes[name]
+		if !ok {
+			*warnings = append(*warnings, fmt.Sprintf("unknown shortcode %q", name))
+			continue
+		}
+
+		args := shortArg.FindAllString(text[m[4]:m[5]], -1)
+		for i, arg := range args {
+			if unquoted, err := strconv.Unquote(arg); err == nil {
+				args[i] = unquoted
+			}
+		}
+		expanded, err := fn(s, a, args)
+		if err != nil {
+			*warnings = append(*warnings, err.Error())
+		}
+		out = append(out, html.EscapeString(text[last:m[0]]), expanded)
+		last = m[1]
+	}
+	return strings.Join(out, "") + html.EscapeString(text[last:])
+}
+
+// Expand replaces the shortcodes of the rendered content `s`, except within
+// code, returning warnings for those that couldn't be expanded.
+func (s *Shortcodes) Expand(a *Article, content string) (string, []string) {
+	var warnings []string
+	tokens := tokenize(content)
+	out := make([]token, 0, len(tokens))
+	skip := 0
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.Type == startToken && noCodesTags[t.Name]:
+			skip++
+		case t.Type == endToken && noCodesTags[t.Name] && skip > 0:
+			skip--
+		case t.Type == textToken && skip == 0 && strings.Contains(t.Data, "{{"):
+			text := html.UnescapeString(t.Data)
+
+			// A paragraph of a single block shortcode is replaced by it
+			if m := shortcode.FindStringSubmatch(strings.TrimSpace(text)); m != nil &&
+				m[0] == strings.TrimSpace(text) && blockCodes[m[1]] && i+1 < len(tokens) &&
+				len(out) > 0 && out[len(out)-1].Type == startToken && out[len(out)-1].Name == "p" &&
+				tokens[i+1].Type == endToken && tokens[i+1].Name == "p" {
+				out[len(out)-1] = token{Type: textToken, Data: s.expand(a, text, &warnings)}
+				i++
+				continue
+			}
+			t.Data = s.expand(a, text, &warnings)
+		}
+		out = append(out, t)
+	}
+	return render(out), warnings
+}
+
+// Check returns the warnings of expanding the shortcodes of the unrendered
+// content `content` of `a`.
+func (s *Shortcodes) Check(a *Article, content string) []string {
+	var warnings []string
+	s.expand(a, html.UnescapeString(content), &warnings)
+	return warnings
+}
+
+// Filter expands shortcodes, logging any warnings, as a step of a Pipeline.
+func (s *Shortcodes) Filter(a *Article, content string) string {
+	content, warnings := s.Expand(a, content)
+	for _, w := range warnings {
+		log.Printf("Article %s: %s", a.TitlePath, w)
+	}
+	return content
+}
@@ -0,0 +1,97 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"strings"
+	"testing"
+)
+
+func shortcodesArticle() *Article {
+	return &Article{TitlePath: "2013/06/01/a/", Imgs: []Img{
+		{Src: "a.png", Alt: "Alt A", W: 10, H: 20},
+		{Src: "b.png", W: 30, H: 40},
+	}}
+}
+
+const (
+	figureA = `<figure class="article-img"><img src="/media/2013/06/01/a/a.png" alt="Alt A" width="10" height="20"></figure>`
+	figureB = `<figure class="article-img"><img src="/media/2013/06/01/a/b.png" alt="" width="30" height="40"></figure>`
+	video   = `<div class="video"><iframe src="https://www.youtube-nocookie.com/embed/abc_DEF-1" width="560" height="315" frameborder="0" allowfullscreen></iframe></div>`
+)
+
+func TestShortcodesExpand(t *testing.T) {
+	tests := []struct {
+		name, in, out, warnings string
+	}{
+		// A paragraph of only a block shortcode is replaced by it
+		{"img", `<p>{{img "a.png"}}</p>`, figureA, ""},
+		{"img spaced", `<p>{{ img a.png }}</p>`, figureA, ""},
+		{"img caption", `<p>{{img b.png "A &amp; B" &lt;i&gt;}}</p>`,
+			`<figure class="article-img"><img src="/media/2013/06/01/a/b.png" alt="A &amp; B &lt;i&gt;" width="30" height="40">` +
+				`<figcaption>A &amp; B &lt;i&gt;</figcaption></figure>`, ""},
+		{"gallery", `<p>{{gallery}}</p>`, `<div class="gallery">` + figureA + figureB + `</div>`, ""},
+		{"youtube", `<p>{{youtube abc_DEF-1}}</p>`, video, ""},
+
+		// Otherwise they're expanded in place, with the text around them escaped
+		{"inline", `<p>See {{img a.png}} &amp; {{youtube "abc_DEF-1"}}.</p>`,
+			`<p>See ` + figureA + ` &amp; ` + video + `.</p>`, ""},
+		{"in div", `<div>{{img a.png}}</div>`, `<div>` + figureA + `</div>`, ""},
+		{"escaped", `<p>a &lt; b &amp;&amp; {{img a.png}} &quot;</p>`, `<p>a &lt; b &amp;&amp; ` + figureA + ` &#34;</p>`, ""},
+
+		// but not within code
+		{"code", `<p><code>{{img a.png}}</code></p>`, `<p><code>{{img a.png}}</code></p>`, ""},
+		{"pre", `<pre><code>{{gallery}}</code></pre>{{gallery}}`,
+			`<pre><code>{{gallery}}</code></pre><div class="gallery">` + figureA + figureB + `</div>`, ""},
+
+		// Malformed
+		{"no name", `<p>{{img}}</p>`, ``, "img: missing image name"},
+		{"unknown img", `<p>{{img c.png}}</p>`, ``, `img: unknown image "c.png"`},
+		{"no id", `<p>{{youtube}}</p>`, ``, "youtube: missing, or invalid, video id"},
+		{"bad id", `<p>x {{youtube "a&gt;&lt;b"}}</p>`, `<p>x </p>`, "youtube: missing, or invalid, video id"},
+		{"unknown", `<p>{{unknown x}} &amp; {{img a.png}}</p>`, `<p>{{unknown x}} &amp; ` + figureA + `</p>`, `unknown shortcode "unknown"`},
+		{"unterminated quote", `<p>{{img "a.png}}</p>`, `<p>{{img &#34;a.png}}</p>`, ""},
+		{"unclosed", `<p>{{img a.png</p>`, `<p>{{img a.png</p>`, ""},
+		{"single braces", `<p>{img a.png}</p>`, `<p>{img a.png}</p>`, ""},
+	}
+	s := NewShortcodes("/media/")
+	for _, test := range tests {
+		got, warnings := s.Expand(shortcodesArticle(), test.in)
+		if got != test.out {
+			t.Errorf("%s: Expand(%q)\n got %q\nwant %q", test.name, test.in, got, test.out)
+		}
+		if w := strings.Join(warnings, "; "); w != test.warnings {
+			t.Errorf("%s: Expand(%q) warned %q, want %q", test.name, test.in, w, test.warnings)
+		}
+	}
+}
+
+func TestShortcodesCheck(t *testing.T) {
+	s := NewShortcodes("/media/")
+	tests := []struct {
+		a                 *Article
+		content, warnings string
+	}{
+		{shortcodesArticle(), `{{img a.png "caption"}} {{gallery}}`, ""},
+		{shortcodesArticle(), `{{img &quot;c.png&quot;}}`, `img: unknown image "c.png"`},
+		{&Article{}, `{{gallery}} {{nope}}`, `gallery: the article has no images; unknown shortcode "nope"`},
+	}
+	for _, test := range tests {
+		if w := strings.Join(s.Check(test.a, test.content), "; "); w != test.warnings {
+			t.Errorf("Check(%q) = %q, want %q", test.content, w, test.warnings)
+		}
+	}
+}
+
+func TestShortcodesCustom(t *testing.T) {
+	s := NewShortcodes("/media/")
+	s.Codes["title"] = func(s *Shortcodes, a *Article, args []string) (string, error) {
+		return "<b>" + a.Title + "</b>", nil
+	}
+	got, warnings := s.Expand(&Article{Title: "T"}, `<p>{{title}}</p>`)
+	if got != `<p><b>T</b></p>` || len(warnings) != 0 {
+		t.Errorf("Expand = %q, %v; custom codes aren't blocks", got, warnings)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"encoding/xml"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaxSitemapURLs is the most URLs of a sitemap, beyond which the sitemap
+// becomes an index of several.
+var MaxSitemapURLs = 50000
+
+// Sitemap lists every published article, along with its images, for search
+// engines. It's generated on demand, and cached until invalidated, or for
+// MaxAge, for changes made by other processes, such as imports and restores.
+type Sitemap struct {
+	Reader      Reader
+	ItoArticles func(o interface{}) []*Article
+	MaxAge      time.Duration
+
+	mu        sync.Mutex
+	stale     bool
+	siteURL   string
+	mediaURL  string
+	pages     [][]byte // the index, or only sitemap, then the sitemaps
+	generated time.Time
+}
+
+func NewSitemap(r Reader) *Sitemap {
+	return &Sitemap{Reader: r, ItoArticles: ToArticles, MaxAge: time.Hour, stale: true}
+}
+
+// Invalidate has the sitemap regenerated when next requested.
+func (s *Sitemap) Invalidate() {
+	s.mu.Lock()
+	s.stale = true
+	s.mu.Unlock()
+}
+
+// Page returns the sitemap, or sitemap index, for `n` 0, otherwise the `n`th
+// sitemap of the index, along with when it was generated. URLs are absolute,
+// from `siteURL`, the articles' base path included, and `mediaURL`.
+func (s *Sitemap) Page(n int, siteURL, mediaURL string) ([]byte, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := s.MaxAge > 0 && time.Since(s.generated) >= s.MaxAge
+	if s.stale || expired || siteURL != s.siteURL || mediaURL != s.mediaURL {
+		pages, err := s.generate(siteURL, mediaURL)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		s.pages, s.siteURL, s.mediaURL = pages, siteURL, mediaURL
+		s.generated, s.stale = time.Now(), false
+	}
+	if n < 0 || n >= len(s.pages) {
+		return nil, s.generated, nil
+	}
+	return s.pages[n], s.generated, nil
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	Image   string       `xml:"xmlns:image,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string         `xml:"loc"`
+	LastMod string         `xml:"lastmod,omitempty"`
+	Images  []sitemapImage `xml:"image:image"`
+}
+
+type sitemapImage struct {
+	Loc     string `xml:"image:loc"`
+	Caption string `xml:"image:caption,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name         `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapPointer `xml:"sitemap"`
+}
+
+type sitemapPointer struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// SitemapURL is the URL of the `n`th sitemap of the index.
+func SitemapURL(siteURL string, n int) string {
+	return siteURL + "/sitemap-" + strconv.Itoa(n) + ".xml"
+}
+
+// generate encodes the sitemaps of every published article.
+func (s *Sitemap) generate(siteURL, mediaURL string) ([][]byte, error) {
+	var urls []sitemapURL
+	var lastMod []time.Time
+	q := Query{Limit: 1000, Sort: "-" + SortCreated}
+	for ; ; q.Page++ {
+		o, err := s.Reader.Find(q)
+		if err != nil {
+			return nil, err
+		}
+		list := s.ItoArticles(o)
+		for _, a := range list {
+			u := sitemapURL{Loc: siteURL + "/" + a.TitlePath}
+			modified := a.Modified
+			if modified.IsZero() {
+				modified = a.Created
+			}
+			u.LastMod = modified.Format(time.RFC3339)
+			if a.Img.Src != "" {
+				u.Images = append(u.Images, sitemapImage{mediaURL + a.TitlePath + a.Img.Src, a.Img.Alt})
+			}
+			for _, img := range a.Imgs {
+				u.Images = append(u.Images, sitemapImage{mediaURL + a.TitlePath + img.Src, img.Alt})
+			}
+			urls = append(urls, u)
+			lastMod = append(lastMod, modified)
+		}
+		if len(list) < q.Limit {
+			break
+		}
+	}
+
+	// A single sitemap
+	if len(urls) <= MaxSitemapURLs {
+		b, err := encodeSitemap(urls)
+		return [][]byte{b}, err
+	}
+
+	// An index of sitemaps
+	pages := [][]byte{nil}
+	var index sitemapIndex
+	for i := 0; i < len(urls); i += MaxSitemapURLs {
+		end := i + MaxSitemapURLs
+		if end > len(urls) {
+			end = len(urls)
+		}
+		b, err := encodeSitemap(urls[i:end])
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, b)
+
+		var latest time.Time
+		for _, t := range lastMod[i:end] {
+			if t.After(latest) {
+				latest = t
+			}
+		}
+		index.Sitemaps = append(index.Sitemaps,
+			sitemapPointer{SitemapURL(siteURL, len(pages)-1), latest.Format(time.RFC3339)})
+	}
+	b, err := xml.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	pages[0] = append([]byte(xml.Header), b...)
+	return pages, nil
+}
+
+func encodeSitemap(urls []sitemapURL) ([]byte, error) {
+	set := sitemapURLSet{Image: "http://www.google.com/schemas/sitemap-image/1.1", URLs: urls}
+	b, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// NotifyPrinter is a Printer calling OnChange after each change of which
+// articles are published, when they were modified, their images, or their
+// tags, such as to invalidate a Sitemap, or a RelatedCache.
+type NotifyPrinter struct {
+	Printer
+	OnChange func()
+}
+
+func (p *NotifyPrinter) notify(err error) error {
+	if err == nil && p.OnChange != nil {
+		p.OnChange()
+	}
+	return err
+}
+
+func (p *NotifyPrinter) Print(article interface{}) error {
+	return p.notify(p.Printer.Print(article))
+}
+func (p *NotifyPrinter) Replace(titlePath string, article interface{}) error {
+	return p.notify(p.Printer.Replace(titlePath, article))
+}
+func (p *NotifyPrinter) UpdateSynopsis(titlePath, synopsis string, modified time.Time) error {
+	return p.notify(p.Printer.UpdateSynopsis(titlePath, synopsis, modified))
+}
+func (p *NotifyPrinter) UpdateContent(titlePath, content string, stats Stats, modified time.Time) error {
+	return p.notify(p.Printer.UpdateContent(titlePath, content, stats, modified))
+}
+func (p *NotifyPrinter) UpdateContentFormat(titlePath string, format ContentFormat, modified time.Time) error {
+	return p.notify(p.Printer.UpdateContentFormat(titlePath, format, modified))
+}
+func (p *NotifyPrinter) Delete(titlePath string) error {
+	return p.notify(p.Printer.Delete(titlePath))
+}
+func (p *NotifyPrinter) Publish(titlePath string, publish bool) error {
+	return p.notify(p.Printer.Publish(titlePath, publish))
+}
+func (p *NotifyPrinter) WriteImg(titlePath string, img interface{}) error {
+	return p.notify(p.Printer.WriteImg(titlePath, img))
+}
+func (p *NotifyPrinter) WriteImgs(titlePath string, imgs interface{}) error {
+	return p.notify(p.Printer.WriteImgs(titlePath, imgs))
+}
+func (p *NotifyPrinter) WriteTags(titlePath string, tags []string) error {
+	return p.notify(p.Printer.WriteTags(titlePath, tags))
+}
@@ -0,0 +1,128 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSitemapCache(t *testing.T) {
+	b := newMemBackend(&Article{TitlePath: "2013/06/01/a/", IsPublished: true})
+	s := NewSitemap(b)
+
+	page := func() []byte {
+		p, _, err := s.Page(0, "http://example.com/articles", "http://example.com/media/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	if p := page(); !bytes.Contains(p, []byte("<loc>http://example.com/articles/2013/06/01/a/</loc>")) {
+		t.Errorf("sitemap without the article:\n%s", p)
+	}
+	page()
+	if b.finds != 1 {
+		t.Errorf("generated %d times, want once", b.finds)
+	}
+
+	// Invalidated
+	s.Invalidate()
+	page()
+	if b.finds != 2 {
+		t.Errorf("generated %d times after invalidating, want twice", b.finds)
+	}
+
+	// or expired, for articles written by other processes
+	b.Print(&Article{TitlePath: "2013/06/02/b/", IsPublished: true})
+	s.MaxAge = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if p := page(); !bytes.Contains(p, []byte("2013/06/02/b/")) {
+		t.Errorf("expired sitemap without the new article:\n%s", p)
+	}
+}
+
+// sortedBackend finds from its list of published articles, already in order,
+// for more articles than are quickly sorted on every page.
+type sortedBackend struct {
+	*memBackend
+	list []*Article
+}
+
+func (b sortedBackend) Find(q Query) (interface{}, error) {
+	return page(b.list, q.Page*q.Limit, q.Limit), nil
+}
+
+func TestSitemapIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		articles int
+		sitemaps []int // URLs of each sitemap of the index, or none
+	}{
+		{"at most", MaxSitemapURLs, nil},
+		{"one over", MaxSitemapURLs + 1, []int{MaxSitemapURLs, 1}},
+	}
+	for _, test := range tests {
+		// An article a minute, newest first
+		b := sortedBackend{memBackend: newMemBackend()}
+		start := time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)
+		for i := test.articles - 1; i >= 0; i-- {
+			created := start.Add(time.Duration(i) * time.Minute)
+			b.list = append(b.list, &Article{TitlePath: fmt.Sprintf("%d/", i),
+				IsPublished: true, Created: created, Modified: created})
+		}
+		s := NewSitemap(b)
+		page := func(n int) []byte {
+			p, _, err := s.Page(n, "http://example.com/articles", "http://example.com/media/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return p
+		}
+
+		if test.sitemaps == nil {
+			var set sitemapURLSet
+			if err := xml.Unmarshal(page(0), &set); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if len(set.URLs) != test.articles || page(1) != nil {
+				t.Errorf("%s: a sitemap of %d URLs, and another of %d bytes", test.name, len(set.URLs), len(page(1)))
+			}
+			continue
+		}
+
+		// An index, of each sitemap, last modified by its newest article
+		var index sitemapIndex
+		if err := xml.Unmarshal(page(0), &index); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(index.Sitemaps) != len(test.sitemaps) {
+			t.Fatalf("%s: index of %d sitemaps, want %d", test.name, len(index.Sitemaps), len(test.sitemaps))
+		}
+		newest := test.articles - 1
+		for i, n := range test.sitemaps {
+			p := index.Sitemaps[i]
+			lastMod := start.Add(time.Duration(newest) * time.Minute).Format(time.RFC3339)
+			if p.Loc != SitemapURL("http://example.com/articles", i+1) || p.LastMod != lastMod {
+				t.Errorf("%s: sitemap %d at %s, modified %s, want %s", test.name, i+1, p.Loc, p.LastMod, lastMod)
+			}
+
+			var set sitemapURLSet
+			if err := xml.Unmarshal(page(i+1), &set); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if len(set.URLs) != n || set.URLs[0].Loc != fmt.Sprintf("http://example.com/articles/%d/", newest) {
+				t.Errorf("%s: sitemap %d of %d URLs, from %s", test.name, i+1, len(set.URLs), set.URLs[0].Loc)
+			}
+			newest -= n
+		}
+		if p := page(len(test.sitemaps) + 1); p != nil {
+			t.Errorf("%s: sitemap past the index", test.name)
+		}
+	}
+}
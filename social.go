@@ -0,0 +1,137 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SocialMeta is the Open Graph, and Twitter Card, metadata of an article,
+// for its page to be previewed when shared.
+type SocialMeta struct {
+	Title       string
+	Description string
+	URL         string
+	SiteName    string
+	Lang        string
+	Image       string
+	ImageAlt    string
+	ImageW      int
+	ImageH      int
+	Published   time.Time
+	Modified    time.Time
+	Author      string
+	Tags        []string
+	TwitterCard string // `summary_large_image` with an image, else `summary`
+	TwitterSite string // the site's @username
+}
+
+// MetaTag is a `<meta>` tag, of a `property`, or `name`, and its content.
+type MetaTag struct {
+	Attr    string
+	Name    string
+	Content string
+}
+
+// NewSocialMeta returns the metadata of `a`, at the absolute URL `url`, its
+// thumbnail being under the absolute `mediaURL`.
+func NewSocialMeta(a *Article, url, mediaURL string) *SocialMeta {
+	m := &SocialMeta{
+		Title:       a.Title,
+		Description: a.Summary(),
+		URL:         url,
+		Lang:        a.Localized(),
+		Published:   a.Created,
+		Modified:    a.Modified,
+		Author:      a.Author,
+		Tags:        a.Tags,
+		TwitterCard: "summary",
+	}
+	if a.Img.Src != "" {
+		m.Image = mediaURL + a.TitlePath + a.Img.Src
+		m.ImageAlt, m.ImageW, m.ImageH = a.Img.Alt, a.Img.W, a.Img.H
+		m.TwitterCard = "summary_large_image"
+	}
+	return m
+}
+
+// MetaTags returns the tags of the metadata, skipping those without a value.
+func (m *SocialMeta) MetaTags() []MetaTag {
+	var tags []MetaTag
+	add := func(attr, name, content string) {
+		if content != "" {
+			tags = append(tags, MetaTag{attr, name, content})
+		}
+	}
+	num := func(i int) string {
+		if i <= 0 {
+			return ""
+		}
+		return strconv.Itoa(i)
+	}
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	// Open Graph
+	add("property", "og:type", "article")
+	add("property", "og:title", m.Title)
+	add("property", "og:description", m.Description)
+	add("property", "og:url", m.URL)
+	add("property", "og:site_name", m.SiteName)
+	add("property", "og:locale", ogLocale(m.Lang))
+	add("property", "og:image", m.Image)
+	add("property", "og:image:alt", m.ImageAlt)
+	add("property", "og:image:width", num(m.ImageW))
+	add("property", "og:image:height", num(m.ImageH))
+	add("property", "article:published_time", date(m.Published))
+	add("property", "article:modified_time", date(m.Modified))
+	add("property", "article:author", m.Author)
+	for _, t := range m.Tags {
+		add("property", "article:tag", t)
+	}
+
+	// Twitter Card, falling back on Open Graph for the rest
+	add("name", "twitter:card", m.TwitterCard)
+	add("name", "twitter:site", m.TwitterSite)
+	add("name", "twitter:title", m.Title)
+	add("name", "twitter:description", m.Description)
+	add("name", "twitter:image", m.Image)
+	add("name", "twitter:image:alt", m.ImageAlt)
+	return tags
+}
+
+// ogLocale returns the Open Graph locale, `language_TERRITORY`, of the
+// language tag `lang`, or nothing when it doesn't name a territory.
+func ogLocale(lang string) string {
+	tags := strings.FieldsFunc(lang, func(r rune) bool { return r == '-' || r == '_' })
+	if len(tags) < 2 || len(tags[0]) < 2 || len(tags[0]) > 3 {
+		return ""
+	}
+	for _, t := range tags[1:] {
+		if len(t) == 2 && strings.Trim(t, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") == "" {
+			return strings.ToLower(tags[0]) + "_" + strings.ToUpper(t)
+		}
+	}
+	return ""
+}
+
+// HTML renders the metadata as `<meta>` tags, for a page's `<head>`.
+func (m *SocialMeta) HTML() template.HTML {
+	var b bytes.Buffer
+	for _, t := range m.MetaTags() {
+		b.WriteString(`<meta ` + t.Attr + `="` + html.EscapeString(t.Name) +
+			`" content="` + html.EscapeString(t.Content) + "\">\n")
+	}
+	return template.HTML(b.String())
+}
@@ -0,0 +1,119 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSocialMeta(t *testing.T) {
+	tests := []struct {
+		name string
+		a    *Article
+		tags []MetaTag
+	}{
+		{"minimal", &Article{TitlePath: "2013/06/01/a/", Title: "A"}, []MetaTag{
+			{"property", "og:type", "article"},
+			{"property", "og:title", "A"},
+			{"property", "og:url", "http://example.com/articles/2013/06/01/a/"},
+			{"property", "og:site_name", "Site"},
+			{"name", "twitter:card", "summary"},
+			{"name", "twitter:site", "@site"},
+			{"name", "twitter:title", "A"},
+		}},
+		{"full", &Article{TitlePath: "2013/06/01/a/", Title: "A", Excerpt: "excerpt", Author: "Ann", Lang: "en-us",
+			Tags:     []string{"x", "y"},
+			Img:      Img{Src: "a.png", Alt: "An A", W: 800, H: 600},
+			Created:  time.Date(2013, 6, 1, 10, 0, 0, 0, time.UTC),
+			Modified: time.Date(2013, 6, 2, 10, 0, 0, 0, time.FixedZone("", 2*60*60)),
+		}, []MetaTag{
+			{"property", "og:type", "article"},
+			{"property", "og:title", "A"},
+			{"property", "og:description", "excerpt"},
+			{"property", "og:url", "http://example.com/articles/2013/06/01/a/"},
+			{"property", "og:site_name", "Site"},
+			{"property", "og:locale", "en_US"},
+			{"property", "og:image", "http://example.com/media/2013/06/01/a/a.png"},
+			{"property", "og:image:alt", "An A"},
+			{"property", "og:image:width", "800"},
+			{"property", "og:image:height", "600"},
+			{"property", "article:published_time", "2013-06-01T10:00:00Z"},
+			{"property", "article:modified_time", "2013-06-02T10:00:00+02:00"},
+			{"property", "article:author", "Ann"},
+			{"property", "article:tag", "x"},
+			{"property", "article:tag", "y"},
+			{"name", "twitter:card", "summary_large_image"},
+			{"name", "twitter:site", "@site"},
+			{"name", "twitter:title", "A"},
+			{"name", "twitter:description", "excerpt"},
+			{"name", "twitter:image", "http://example.com/media/2013/06/01/a/a.png"},
+			{"name", "twitter:image:alt", "An A"},
+		}},
+		// The synopsis is preferred, and unknown image sizes left out
+		{"synopsis", &Article{TitlePath: "2013/06/01/a/", Title: "A", Synopsis: "synopsis", Excerpt: "excerpt",
+			Img: Img{Src: "a.png"}}, []MetaTag{
+			{"property", "og:type", "article"},
+			{"property", "og:title", "A"},
+			{"property", "og:description", "synopsis"},
+			{"property", "og:url", "http://example.com/articles/2013/06/01/a/"},
+			{"property", "og:site_name", "Site"},
+			{"property", "og:image", "http://example.com/media/2013/06/01/a/a.png"},
+			{"name", "twitter:card", "summary_large_image"},
+			{"name", "twitter:site", "@site"},
+			{"name", "twitter:title", "A"},
+			{"name", "twitter:description", "synopsis"},
+			{"name", "twitter:image", "http://example.com/media/2013/06/01/a/a.png"},
+		}},
+	}
+	for _, test := range tests {
+		m := NewSocialMeta(test.a, "http://example.com/articles/"+test.a.TitlePath, "http://example.com/media/")
+		m.SiteName, m.TwitterSite = "Site", "@site"
+		tags := m.MetaTags()
+		if len(tags) != len(test.tags) {
+			t.Errorf("%s: MetaTags()\n got %v\nwant %v", test.name, tags, test.tags)
+			continue
+		}
+		for i := range tags {
+			if tags[i] != test.tags[i] {
+				t.Errorf("%s: MetaTags()[%d] = %v, want %v", test.name, i, tags[i], test.tags[i])
+			}
+		}
+	}
+}
+
+func TestSocialMetaHTML(t *testing.T) {
+	m := &SocialMeta{Title: `"Fish" & <Chips>`, TwitterCard: "summary"}
+	want := `<meta property="og:type" content="article">` + "\n" +
+		`<meta property="og:title" content="&#34;Fish&#34; &amp; &lt;Chips&gt;">` + "\n" +
+		`<meta name="twitter:card" content="summary">` + "\n" +
+		`<meta name="twitter:title" content="&#34;Fish&#34; &amp; &lt;Chips&gt;">` + "\n"
+	if got := string(m.HTML()); got != want {
+		t.Errorf("HTML()\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestOGLocale(t *testing.T) {
+	tests := []struct {
+		lang, want string
+	}{
+		{"en-US", "en_US"},
+		{"pt_br", "pt_BR"},
+		{"zh-Hant-TW", "zh_TW"},
+		{"ast-ES", "ast_ES"},
+
+		// Without a territory, there's no locale to give
+		{"en", ""},
+		{"", ""},
+		{"sr-Latn", ""},
+		{"es-419", ""},
+		{"-US", ""},
+	}
+	for _, test := range tests {
+		if got := ogLocale(test.lang); got != test.want {
+			t.Errorf("ogLocale(%q) = %q, want %q", test.lang, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	WordsPerMinute = 200
+	ExcerptLength  = 300
+)
+
+// Stats are computed from an article's content whenever it's set, so that
+// listings can use them without loading the content.
+type Stats struct {
+	WordCount   int    `json:"wordCount" bson:"wordCount"`
+	ReadingTime int    `json:"readingTime" bson:"readingTime"` // minutes
+	Excerpt     string `json:"excerpt" bson:"excerpt"`
+}
+
+// NewStats computes the stats of the HTML `content`.
+func NewStats(content string) Stats {
+	text := PlainText(content)
+	words := len(strings.Fields(text))
+	minutes := (words + WordsPerMinute - 1) / WordsPerMinute
+	return Stats{words, minutes, Excerpt(text, ExcerptLength)}
+}
+
+// ContentStats computes the stats of `content` written in `format`, from its
+// text rather than its markup.
+func ContentStats(format ContentFormat, content string) Stats {
+	switch format {
+	case FormatMarkdown:
+		content = Markdown(content)
+	case FormatPlain:
+		content = Plain(content)
+	}
+	return NewStats(content)
+}
+
+// PlainText returns the text of the HTML `s`, with its whitespace collapsed.
+func PlainText(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(StripTags(s))), " ")
+}
+
+// Excerpt shortens the plain text `text` to at most `max` bytes, cutting at
+// the end of a sentence when possible, or else at a word.
+func Excerpt(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+
+	// Last sentence ending before `max`
+	cut := -1
+	for i := 0; i < max; i++ {
+		switch text[i] {
+		case '.', '!', '?':
+			if i+1 < len(text) && text[i+1] == ' ' {
+				cut = i + 1
+			}
+		}
+	}
+	if cut > max/3 {
+		return text[:cut]
+	}
+
+	// Last word ending before `max`
+	cut = strings.LastIndex(text[:max], " ")
+	if cut <= 0 {
+		for cut = max; cut > 0 && !utf8.RuneStart(text[cut]); cut-- {
+		}
+	}
+	return strings.TrimRight(text[:cut], " ,;:-") + "…"
+}
@@ -0,0 +1,73 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContentStats(t *testing.T) {
+	tests := []struct {
+		format  ContentFormat
+		content string
+		excerpt string
+		words   int
+	}{
+		{FormatHTML, "<h1>Title</h1><p>Some <b>bold</b> text.</p>", "Title Some bold text.", 4},
+		{FormatMarkdown, "# Title **bold** text with `code`", "Title bold text with code", 5},
+		{FormatMarkdown, "Some *emphasis*, and [a link](http://example.com).", "Some emphasis, and a link.", 5},
+		{FormatPlain, "Fish & chips <b>", "Fish & chips <b>", 4},
+		{"", "<p>No format is HTML</p>", "No format is HTML", 4},
+	}
+	for _, test := range tests {
+		s := ContentStats(test.format, test.content)
+		if s.Excerpt != test.excerpt {
+			t.Errorf("ContentStats(%q, %q).Excerpt = %q, want %q", test.format, test.content, s.Excerpt, test.excerpt)
+		}
+		if s.WordCount != test.words {
+			t.Errorf("ContentStats(%q, %q).WordCount = %d, want %d", test.format, test.content, s.WordCount, test.words)
+		}
+	}
+}
+
+func TestSetContentStats(t *testing.T) {
+	b := newMemBackend(&Article{TitlePath: "2013/06/01/a/", ContentFormat: FormatMarkdown})
+	a, _ := b.article("2013/06/01/a/")
+
+	if err := a.SetContent("## Heading\n\n**Bold** and `code`"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(a.Excerpt, "#*`") {
+		t.Errorf("excerpt of markdown has its markup: %q", a.Excerpt)
+	}
+	if stored, _ := b.article(a.TitlePath); stored.Excerpt != a.Excerpt {
+		t.Errorf("stored excerpt %q, want %q", stored.Excerpt, a.Excerpt)
+	}
+
+	// Changing the format recomputes them
+	if err := a.SetContentFormat(FormatPlain); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(a.Excerpt, "**Bold**") {
+		t.Errorf("excerpt of plain text = %q, want its asterisks kept", a.Excerpt)
+	}
+	if stored, _ := b.article(a.TitlePath); stored.Excerpt != a.Excerpt {
+		t.Errorf("stored excerpt %q, want %q", stored.Excerpt, a.Excerpt)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	text := "First sentence here. Second sentence that goes on for quite a while."
+	if got := Excerpt(text, 40); got != "First sentence here." {
+		t.Errorf("Excerpt at a sentence = %q", got)
+	}
+	if got := Excerpt("one two three four", 12); got != "one two…" {
+		t.Errorf("Excerpt at a word = %q", got)
+	}
+	if got := Excerpt("short", 40); got != "short" {
+		t.Errorf("Excerpt of short text = %q", got)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"bytes"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Heading is an entry of an article's table of contents.
+type Heading struct {
+	ID       string     `json:"id" bson:"id"`
+	Text     string     `json:"text" bson:"text"`
+	Level    int        `json:"level" bson:"level"`
+	Children []*Heading `json:"children,omitempty" bson:"children,omitempty"`
+}
+
+// headingLevel returns the level of a `h1`-`h6` tag, and 0 for any other.
+func headingLevel(name string) int {
+	if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+		return int(name[1] - '0')
+	}
+	return 0
+}
+
+// Slug converts `text` into an anchor ID, keeping its letters and numbers.
+func Slug(text string) string {
+	var b bytes.Buffer
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// Anchors gives every heading without one an ID derived from its text, so
+// links to it survive edits elsewhere in the content, as a step of a Pipeline.
+func Anchors(a *Article, s string) string {
+	tokens := tokenize(s)
+
+	// IDs already in use, and the last number suffixed to each slug
+	used := make(map[string]bool)
+	next := make(map[string]int)
+	for _, t := range tokens {
+		if id, ok := t.Attr("id"); ok && t.Type == startToken {
+			used[id] = true
+		}
+	}
+
+	for i, t := range tokens {
+		if t.Type != startToken || headingLevel(t.Name) == 0 {
+			continue
+		}
+		if _, ok := t.Attr("id"); ok {
+			continue
+		}
+
+		slug := Slug(headingText(tokens[i+1 : headingEnd(tokens, i)]))
+		if slug == "" {
+			slug = "section"
+		}
+		id, n := slug, next[slug]
+		if n == 0 {
+			n = 1
+		}
+		for used[id] {
+			n++
+			id = slug + "-" + strconv.Itoa(n)
+		}
+		used[id] = true
+		next[slug] = n
+		tokens[i].SetAttr("id", id)
+	}
+	return render(tokens)
+}
+
+// headingEnd returns the index of the end of the heading started by
+// tokens[i]. An unclosed heading ends at the next, as headings don't nest,
+// so no token is scanned for more than one heading.
+func headingEnd(tokens []token, i int) int {
+	name := tokens[i].Name
+	for j := i + 1; j < len(tokens); j++ {
+		if t := tokens[j]; t.Type == endToken && t.Name == name || t.Type == startToken && headingLevel(t.Name) > 0 {
+			return j
+		}
+	}
+	return len(tokens)
+}
+
+// headingText returns the text of the tokens of a heading.
+func headingText(tokens []token) string {
+	var b bytes.Buffer
+	for _, t := range tokens {
+		if t.Type == textToken {
+			b.WriteString(html.UnescapeString(t.Data))
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// TOC returns the headings of the rendered content `s` nested by level.
+// Only headings with an ID, which can be linked to, are included.
+func TOC(s string) []*Heading {
+	var toc, stack []*Heading
+	tokens := tokenize(s)
+	for i, t := range tokens {
+		level := headingLevel(t.Name)
+		if t.Type != startToken || level == 0 {
+			continue
+		}
+		id, ok := t.Attr("id")
+		if !ok {
+			continue
+		}
+
+		h := &Heading{ID: id, Text: headingText(tokens[i+1 : headingEnd(tokens, i)]), Level: level}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			toc = append(toc, h)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, h)
+		}
+		stack = append(stack, h)
+	}
+	return toc
+}
@@ -0,0 +1,114 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlug(t *testing.T) {
+	tests := map[string]string{
+		"Hello, World!":      "hello-world",
+		"  Leading & trail ": "leading-trail",
+		"Go 1.1":             "go-1-1",
+		"Über café":          "über-café",
+		"---":                "",
+	}
+	for text, want := range tests {
+		if got := Slug(text); got != want {
+			t.Errorf("Slug(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestAnchors(t *testing.T) {
+	tests := []struct {
+		name, in, out string
+	}{
+		{"heading", `<h2>Getting Started</h2>`, `<h2 id="getting-started">Getting Started</h2>`},
+		{"inline markup", `<h3>The <code>go</code> tool</h3>`, `<h3 id="the-go-tool">The <code>go</code> tool</h3>`},
+		{"entities", `<h2>Fish &amp; Chips</h2>`, `<h2 id="fish-chips">Fish &amp; Chips</h2>`},
+		{"no text", `<h2>!</h2>`, `<h2 id="section">!</h2>`},
+		{"kept", `<h2 id="mine">Title</h2>`, `<h2 id="mine">Title</h2>`},
+		{"not headings", `<p>Title</p>`, `<p>Title</p>`},
+		{"unclosed", `<h2>A<p>x<h3>B</h3>`, `<h2 id="ax">A<p>x<h3 id="b">B</h3>`},
+
+		// Duplicates are numbered, around IDs already in use
+		{"duplicates", `<h2>A</h2><h2>A</h2><h2>A</h2>`,
+			`<h2 id="a">A</h2><h2 id="a-2">A</h2><h2 id="a-3">A</h2>`},
+		{"used", `<h2>A</h2><p id="a">x</p>`, `<h2 id="a-2">A</h2><p id="a">x</p>`},
+		{"numbered used", `<h2>A</h2><h2>A 2</h2><h2>A</h2>`,
+			`<h2 id="a">A</h2><h2 id="a-2">A 2</h2><h2 id="a-3">A</h2>`},
+	}
+	for _, test := range tests {
+		if got := Anchors(nil, test.in); got != test.out {
+			t.Errorf("%s: Anchors(%q)\n got %q\nwant %q", test.name, test.in, got, test.out)
+		}
+	}
+}
+
+func TestAnchorsLinear(t *testing.T) {
+	for _, s := range []string{
+		strings.Repeat("<h2>Same</h2>", 20000),
+		strings.Repeat("<h2>Unclosed<p>text", 20000),
+	} {
+		start := time.Now()
+		out := Anchors(nil, s)
+		if d := time.Since(start); d > 2*time.Second {
+			t.Errorf("Anchors of %d bytes took %v", len(s), d)
+		}
+		if !strings.Contains(out, `-20000"`) {
+			t.Errorf("last heading not numbered 20000")
+		}
+		TOC(out)
+		if d := time.Since(start); d > 4*time.Second {
+			t.Errorf("TOC of %d bytes took %v", len(s), d)
+		}
+	}
+}
+
+// outline writes the headings as `id(children)`, for comparing them.
+func outline(headings []*Heading) string {
+	var parts []string
+	for _, h := range headings {
+		s := h.ID
+		if len(h.Children) > 0 {
+			s += "(" + outline(h.Children) + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestTOC(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"flat", `<h2 id="a">A</h2><h2 id="b">B</h2>`, "a b"},
+		{"nested", `<h2 id="a">A</h2><h3 id="a1">1</h3><h3 id="a2">2</h3><h2 id="b">B</h2>`, "a(a1 a2) b"},
+		{"deep", `<h2 id="a">A</h2><h3 id="b">B</h3><h4 id="c">C</h4><h3 id="d">D</h3>`, "a(b(c) d)"},
+		{"skipped level", `<h2 id="a">A</h2><h4 id="b">B</h4><h3 id="c">C</h3>`, "a(b c)"},
+		{"higher later", `<h3 id="a">A</h3><h2 id="b">B</h2><h1 id="c">C</h1>`, "a b c"},
+		{"without ids", `<h2>A</h2><h2 id="b">B</h2>`, "b"},
+		{"none", `<p>text</p>`, ""},
+	}
+	for _, test := range tests {
+		if got := outline(TOC(test.in)); got != test.want {
+			t.Errorf("%s: TOC(%q) = %q, want %q", test.name, test.in, got, test.want)
+		}
+	}
+
+	toc := TOC(`<h2 id="x">The <em>x</em>  &amp; y</h2>`)
+	if len(toc) != 1 || toc[0].Text != "The x & y" || toc[0].Level != 2 {
+		t.Errorf("TOC = %+v, want the heading's text, and level", toc[0])
+	}
+
+	// of the anchored headings, duplicates included
+	if got := outline(TOC(Anchors(nil, `<h2>A</h2><h3>A</h3><h2>A</h2>`))); got != "a(a-2) a-3" {
+		t.Errorf("TOC of anchors = %q", got)
+	}
+}
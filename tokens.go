@@ -0,0 +1,270 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+type tokenType int
+
+const (
+	textToken tokenType = iota
+	startToken
+	endToken
+	commentToken
+)
+
+type attr struct {
+	Key, Val string
+}
+
+// token is a piece of HTML, either text, a tag or a comment.
+type token struct {
+	Type        tokenType
+	Name        string // lower case tag name
+	Attrs       []attr
+	SelfClosing bool
+	Data        string // raw text, or comment
+}
+
+// voidTags never have an end tag.
+var voidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "param": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// rawTags contain text that isn't parsed as HTML.
+var rawTags = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true,
+}
+
+func isTagStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// tokenize splits the HTML `s` into tokens. It's lenient; anything that
+// doesn't parse as a tag is text.
+func tokenize(s string) []token {
+	var tokens []token
+	text := 0
+	flush := func(end int) {
+		if end > text {
+			tokens = append(tokens, token{Type: textToken, Data: s[text:end]})
+		}
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != '<' || i+1 >= len(s) {
+			i++
+			continue
+		}
+
+		switch c := s[i+1]; {
+		case strings.HasPrefix(s[i:], "<!--"):
+			end := strings.Index(s[i+4:], "-->")
+			if end < 0 {
+				end = len(s) - i - 4
+			}
+			flush(i)
+			tokens = append(tokens, token{Type: commentToken, Data: s[i+4 : i+4+end]})
+			if i += 7 + end; i > len(s) {
+				i = len(s)
+			}
+			text = i
+
+		case c == '!' || c == '?':
+			// Doctypes and processing instructions are kept as comments
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				end = len(s) - i
+			}
+			flush(i)
+			tokens = append(tokens, token{Type: commentToken, Data: s[i+2 : i+end]})
+			i += end + 1
+			text = i
+
+		case c == '/' && i+2 < len(s) && isTagStart(s[i+2]):
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				// Without a '>' nothing further is a tag, so the rest is text
+				i = len(s)
+				continue
+			}
+			flush(i)
+			name := s[i+2 : i+end]
+			if n := strings.IndexFunc(name, func(r rune) bool { return r < 128 && isSpace(byte(r)) }); n >= 0 {
+				name = name[:n]
+			}
+			tokens = append(tokens, token{Type: endToken, Name: strings.ToLower(name)})
+			i += end + 1
+			text = i
+
+		case isTagStart(c):
+			t, n, ok := parseTag(s[i:])
+			if !ok {
+				// parseTag only fails at the end of input, so rather than
+				// rescanning from every following '<', the rest is text
+				i = len(s)
+				continue
+			}
+			flush(i)
+			tokens = append(tokens, t)
+			i += n
+			text = i
+
+			// The content of raw text elements is a single text token
+			if rawTags[t.Name] && !t.SelfClosing {
+				end := indexEndTag(s[i:], t.Name)
+				if end < 0 {
+					end = len(s) - i
+				}
+				flush(i + end)
+				i += end
+				text = i
+			}
+
+		default:
+			i++
+		}
+	}
+	flush(len(s))
+	return tokens
+}
+
+// indexEndTag returns the index of the first, case insensitive, `</name` in
+// `s`, or -1.
+func indexEndTag(s, name string) int {
+	for i := 0; ; {
+		n := strings.Index(s[i:], "</")
+		if n < 0 {
+			return -1
+		}
+		i += n
+		if i+2+len(name) <= len(s) && strings.EqualFold(s[i+2:i+2+len(name)], name) {
+			return i
+		}
+		i += 2
+	}
+}
+
+// parseTag parses the start tag at the beginning of `s`, returning the number
+// of bytes it spans.
+func parseTag(s string) (t token, n int, ok bool) {
+	i := 1
+	for i < len(s) && !isSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	t = token{Type: startToken, Name: strings.ToLower(s[1:i])}
+
+	for i < len(s) {
+		for i < len(s) && (isSpace(s[i]) || s[i] == '/') {
+			if s[i] == '/' && i+1 < len(s) && s[i+1] == '>' {
+				t.SelfClosing = true
+			}
+			i++
+		}
+		if i >= len(s) {
+			return t, 0, false
+		}
+		if s[i] == '>' {
+			return t, i + 1, true
+		}
+
+		// Attribute name
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		a := attr{Key: strings.ToLower(s[start:i])}
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+
+		// Optional value, quoted or not
+		if i < len(s) && s[i] == '=' {
+			for i++; i < len(s) && isSpace(s[i]); i++ {
+			}
+			if i >= len(s) {
+				return t, 0, false
+			}
+			if q := s[i]; q == '"' || q == '\'' {
+				end := strings.IndexByte(s[i+1:], q)
+				if end < 0 {
+					return t, 0, false
+				}
+				a.Val = html.UnescapeString(s[i+1 : i+1+end])
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				a.Val = html.UnescapeString(s[start:i])
+			}
+		}
+		t.Attrs = append(t.Attrs, a)
+	}
+	return t, 0, false
+}
+
+// Attr returns the value of the attribute `key`.
+func (t *token) Attr(key string) (string, bool) {
+	for _, a := range t.Attrs {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// SetAttr replaces, or adds, the attribute `key`.
+func (t *token) SetAttr(key, val string) {
+	for i, a := range t.Attrs {
+		if a.Key == key {
+			t.Attrs[i].Val = val
+			return
+		}
+	}
+	t.Attrs = append(t.Attrs, attr{key, val})
+}
+
+func (t token) String() string {
+	switch t.Type {
+	case textToken:
+		return t.Data
+	case endToken:
+		return "</" + t.Name + ">"
+	case commentToken:
+		return "<!--" + t.Data + "-->"
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<" + t.Name)
+	for _, a := range t.Attrs {
+		b.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	if t.SelfClosing {
+		b.WriteString(" /")
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// render joins the tokens back into HTML.
+func render(tokens []token) string {
+	var b bytes.Buffer
+	for _, t := range tokens {
+		b.WriteString(t.String())
+	}
+	return b.String()
+}
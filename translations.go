@@ -0,0 +1,106 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultLang is the language of articles that don't have one.
+var DefaultLang = "en"
+
+// Translation is the Title, Synopsis and Content of an article in another
+// language than its own.
+type Translation struct {
+	Lang     string    `json:"lang" bson:"lang"`
+	Title    string    `json:"title" bson:"title"`
+	Synopsis string    `json:"synopsis" bson:"synopsis"`
+	Content  string    `json:"content" bson:"content"`
+	Rendered string    `json:"rendered,omitempty" bson:"rendered"`
+	Modified time.Time `json:"modified" bson:"modified"`
+}
+
+// Language returns the article's own language.
+func (a *Article) Language() string {
+	if a.Lang == "" {
+		return DefaultLang
+	}
+	return a.Lang
+}
+
+// Langs returns the languages the article is available in, its own first.
+func (a *Article) Langs() []string {
+	langs := []string{a.Language()}
+	for _, t := range a.Translations {
+		langs = append(langs, t.Lang)
+	}
+	return langs
+}
+
+// Translation returns the translation of the article into `lang`.
+func (a *Article) Translation(lang string) (*Translation, bool) {
+	for i, t := range a.Translations {
+		if strings.EqualFold(t.Lang, lang) {
+			return &a.Translations[i], true
+		}
+	}
+	return nil, false
+}
+
+// Localize replaces the Title, Synopsis and Content of the article by those
+// of its translation into `lang`, returning false when there's none.
+func (a *Article) Localize(lang string) bool {
+	t, ok := a.Translation(lang)
+	if !ok {
+		return false
+	}
+	a.Title, a.Synopsis, a.Content = t.Title, t.Synopsis, t.Content
+	a.Rendered = t.Rendered
+	a.Stats = ContentStats(a.ContentFormat, t.Content)
+	a.translation = t.Lang
+	return true
+}
+
+// Localized returns the language the article was localized to, or its own.
+func (a *Article) Localized() string {
+	if a.translation != "" {
+		return a.translation
+	}
+	return a.Language()
+}
+
+func (a *Article) SetLang(lang string) (err error) {
+	if err = a.Printer.WriteLang(a.TitlePath, lang); err == nil {
+		a.Lang = lang
+	}
+	return
+}
+
+// SetTranslation adds, or replaces, the translation into `t.Lang`.
+func (a *Article) SetTranslation(t Translation) (err error) {
+	t.Modified = time.Now()
+	t.Rendered = ""
+	if err = a.Printer.WriteTranslation(a.TitlePath, t); err == nil {
+		if existing, ok := a.Translation(t.Lang); ok {
+			*existing = t
+		} else {
+			a.Translations = append(a.Translations, t)
+		}
+	}
+	return
+}
+
+func (a *Article) RemoveTranslation(lang string) (err error) {
+	if err = a.Printer.RemoveTranslation(a.TitlePath, lang); err == nil {
+		for i, t := range a.Translations {
+			if strings.EqualFold(t.Lang, lang) {
+				a.Translations = append(a.Translations[:i], a.Translations[i+1:]...)
+				break
+			}
+		}
+	}
+	return
+}
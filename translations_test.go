@@ -0,0 +1,65 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package articles
+
+import "testing"
+
+func TestLocalize(t *testing.T) {
+	tests := []struct {
+		name, lang string
+		ok         bool
+		title      string
+		rendered   string
+		localized  string
+	}{
+		{"translation", "fr", true, "Un", "<p>un</p>", "fr"},
+		{"case", "FR", true, "Un", "<p>un</p>", "fr"},
+		{"not rendered", "de", true, "Ein", "", "de"},
+		{"none", "es", false, "One", "<p>one</p>", "en"},
+	}
+	for _, test := range tests {
+		a := &Article{Title: "One", Synopsis: "1", Content: "one", Rendered: "<p>one</p>", Lang: "en",
+			Translations: []Translation{
+				{Lang: "fr", Title: "Un", Synopsis: "1", Content: "un deux trois", Rendered: "<p>un</p>"},
+				{Lang: "de", Title: "Ein", Content: "ein"}}}
+		if ok := a.Localize(test.lang); ok != test.ok {
+			t.Errorf("%s: Localize(%q) = %t", test.name, test.lang, ok)
+		}
+		if a.Title != test.title || a.Rendered != test.rendered || a.Localized() != test.localized {
+			t.Errorf("%s: title %q, rendered %q, localized to %q", test.name, a.Title, a.Rendered, a.Localized())
+		}
+		if test.ok && a.Stats != ContentStats(a.ContentFormat, a.Content) {
+			t.Errorf("%s: stats %+v of the article's own content", test.name, a.Stats)
+		}
+		if a.Language() != "en" {
+			t.Errorf("%s: own language %q", test.name, a.Language())
+		}
+	}
+}
+
+func TestLocalizeRender(t *testing.T) {
+	b := newMemBackend(&Article{TitlePath: "2013/06/01/a/", Title: "One", Content: "one", Lang: "en",
+		Translations: []Translation{{Lang: "fr", Title: "Un", Content: "un"}}})
+	o, _ := b.ByTitlePath("2013/06/01/a/", true)
+	a := o.(*Article)
+	p := NewPipeline()
+
+	// The translation's rendering is cached with it, not the article
+	a.Localize("fr")
+	if html, err := a.Render(p); err != nil || html != "un" {
+		t.Fatalf("Render() = %q, %v", html, err)
+	}
+	stored := b.articles[a.TitlePath]
+	if stored.Rendered != "" || stored.Translations[0].Rendered != "un" {
+		t.Errorf("cached %q, and %q of the translation", stored.Rendered, stored.Translations[0].Rendered)
+	}
+
+	// and swapped in when localized again
+	o, _ = b.ByTitlePath("2013/06/01/a/", true)
+	a = o.(*Article)
+	if a.Localize("fr"); a.Rendered != "un" {
+		t.Errorf("localized rendering %q", a.Rendered)
+	}
+}